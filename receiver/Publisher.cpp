@@ -1,33 +1,183 @@
 #include <iostream>
+#include <algorithm>
+#include <cctype>
+#include <cerrno>
+#include <unistd.h>
 #include <zmq.h>
+#include <nlohmann/json.hpp>
 #include "Publisher.hpp"
 #include "RadioMessage.hpp"
 
-Publisher::Publisher(const std::string& endpoint) {
+// the endpoint libzmq sends ZAP requests to
+static const char *zap_endpoint = "inproc://zeromq.zap.01";
+
+// stable across the restarts, so the subscribers detect them
+static std::string defaultReceiverId(const std::string& endpoint) {
+    char hostname[256] = {0};
+
+    if (gethostname(hostname, sizeof(hostname) - 1))
+        throw std::runtime_error("Unable to get the host name for the receiver id");
+
+    return std::string(hostname) + "/" + endpoint;
+}
+
+Publisher::Publisher(const std::string& endpoint, bool topic_envelope,
+                        const std::string& curve_secret_key,
+                        const std::vector<std::string>& curve_allowed_clients,
+                        const std::string& receiver_id) {
     endpoint_ = endpoint;
+    topic_envelope_ = topic_envelope;
+    receiver_id_ = receiver_id.empty() ? defaultReceiverId(endpoint) : receiver_id;
 
     ctx_ = zmq_ctx_new();
     if (ctx_ == NULL)
         throw std::runtime_error("Unable to create a ZMQ context");
 
+    if (!curve_allowed_clients.empty()) {
+        if (curve_secret_key.empty())
+            throw std::runtime_error("The allowed CURVE clients require the CURVE secret key");
+
+        for (const auto& key : curve_allowed_clients) {
+            uint8_t public_key[32];
+
+            if ((key.size() != 40) || (zmq_z85_decode(public_key, key.c_str()) == NULL))
+                throw std::runtime_error("Invalid CURVE public key '" + key + "'");
+
+            allowed_keys_.insert(std::string(reinterpret_cast<char*>(public_key),
+                                                sizeof(public_key)));
+        }
+
+        // must be bound before the connections are accepted,
+        // otherwise they are not authenticated
+        zap_sock_ = zmq_socket(ctx_, ZMQ_REP);
+        if (zap_sock_ == NULL)
+            throw std::runtime_error("Unable to create the ZAP socket");
+
+        if (zmq_bind(zap_sock_, zap_endpoint))
+            throw std::runtime_error("Unable to bind the ZAP socket");
+    }
+
     sock_ = zmq_socket(ctx_, ZMQ_PUB);
     if (sock_ == NULL)
         throw std::runtime_error("Unable to create a ZMQ socket");
 
+    if (!curve_secret_key.empty()) {
+        int curve_server = 1;
+
+        if (zmq_setsockopt(sock_, ZMQ_CURVE_SERVER, &curve_server, sizeof(curve_server)))
+            throw std::runtime_error("Unable to enable CURVE on the ZMQ socket");
+
+        if (zmq_setsockopt(sock_, ZMQ_CURVE_SECRETKEY,
+                            curve_secret_key.c_str(), curve_secret_key.size()))
+            throw std::runtime_error("Unable to set the CURVE secret key");
+    }
+
     if (zmq_bind(sock_, endpoint_.c_str()))
         throw std::runtime_error("Unable to bind the ZMQ socket");
+
+    if (zap_sock_ != NULL)
+        zap_thread_ = std::thread(&Publisher::runZapHandler, this);
 }
 
 Publisher::~Publisher() {
     if ((sock_ != NULL) && (zmq_close(sock_)))
         std::cerr << "Unable to close the ZMQ socket" << std::endl;
 
+    // interrupts the ZAP handler, which closes its socket
     if ((ctx_ != NULL) && (zmq_ctx_term(ctx_)))
         std::cerr << "Unable to terminate the ZMQ context" << std::endl;
+
+    if (zap_thread_.joinable())
+        zap_thread_.join();
+}
+
+// returns false when the context is terminated
+static bool recvMultipart(void *sock, std::vector<std::string>& frames) {
+    frames.clear();
+
+    while (true) {
+        zmq_msg_t msg;
+        zmq_msg_init(&msg);
+
+        if (zmq_msg_recv(&msg, sock, 0) == -1) {
+            zmq_msg_close(&msg);
+
+            if (errno == EINTR)
+                continue;
+
+            if (errno != ETERM)
+                std::cerr << "Unable to receive a ZAP request: " << zmq_strerror(errno) << std::endl;
+
+            return false;
+        }
+
+        frames.emplace_back(static_cast<char*>(zmq_msg_data(&msg)), zmq_msg_size(&msg));
+
+        bool more = zmq_msg_more(&msg);
+        zmq_msg_close(&msg);
+
+        if (!more)
+            return true;
+    }
+}
+
+static bool sendMultipart(void *sock, const std::vector<std::string>& frames) {
+    for (size_t i = 0; i < frames.size(); i++) {
+        int flags = (i + 1 < frames.size()) ? ZMQ_SNDMORE : 0;
+
+        if (zmq_send(sock, frames[i].data(), frames[i].size(), flags) == -1)
+            return false;
+    }
+
+    return true;
+}
+
+void Publisher::runZapHandler() {
+    std::vector<std::string> request;
+
+    while (recvMultipart(zap_sock_, request)) {
+        // version, request id, domain, address, identity, mechanism, credentials
+        std::string request_id = (request.size() > 1) ? request[1] : "";
+        std::string status = "400";
+        std::string text = "Not allowed";
+
+        if ((request.size() < 6) || (request[0] != "1.0")) {
+            status = "500";
+            text = "Invalid request";
+        } else if ((request[5] == "CURVE") && (request.size() == 7) &&
+                    (allowed_keys_.count(request[6]) != 0)) {
+            status = "200";
+            text = "OK";
+        }
+
+        if (!sendMultipart(zap_sock_, {"1.0", request_id, status, text, "", ""}))
+            std::cerr << "Unable to send a ZAP reply: " << zmq_strerror(errno) << std::endl;
+    }
+
+    zmq_close(zap_sock_);
+}
+
+static std::string topicForMessage(const struct RadioMessage& msg) {
+    std::string type = RadioMessageTypeToString(msg.type);
+    std::transform(type.begin(), type.end(), type.begin(),
+                    [](unsigned char c) { return std::tolower(c); });
+
+    return "sensors/" + std::to_string(+msg.device_id) + "/" + type;
 }
 
 bool Publisher::publishMessage(const struct RadioMessage& msg) {
-    std::string s = msg.toJsonString();
+    json j = msg;
+    j["seq"] = ++seq_;
+    j["receiver_id"] = receiver_id_;
+
+    std::string s = j.dump();
+
+    if (topic_envelope_) {
+        std::string topic = topicForMessage(msg);
+
+        if (zmq_send(sock_, topic.c_str(), topic.size(), ZMQ_SNDMORE) == -1)
+            return false;
+    }
 
     return (zmq_send(sock_, s.c_str(), s.size(), 0) != -1);
 }
@@ -35,3 +185,7 @@ bool Publisher::publishMessage(const struct RadioMessage& msg) {
 std::string Publisher::endpoint() const {
     return endpoint_;
 }
+
+std::string Publisher::receiverId() const {
+    return receiver_id_;
+}
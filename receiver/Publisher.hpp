@@ -1,18 +1,46 @@
 #pragma once
+#include <cstdint>
+#include <set>
 #include <string>
+#include <thread>
+#include <vector>
 #include "RadioMessage.hpp"
 
 class Publisher {
 public:
-    Publisher(const std::string& endpoint);
+    // if topic_envelope is set, each message is preceded by
+    // the "sensors/<device_id>/<type>" topic frame
+    // if curve_secret_key (Z85 encoded) is not empty, the connections
+    // are encrypted with CURVE
+    // if curve_allowed_clients (Z85 encoded public keys) is not empty,
+    // only subscribers with these keys may connect
+    // the messages are numbered starting from 1 and stamped with
+    // receiver_id, '<hostname>/<endpoint>' if it is empty, so that the
+    // subscribers detect lost messages and restarts
+    Publisher(const std::string& endpoint, bool topic_envelope = false,
+                const std::string& curve_secret_key = "",
+                const std::vector<std::string>& curve_allowed_clients = {},
+                const std::string& receiver_id = "");
     ~Publisher();
 
     bool publishMessage(const struct RadioMessage& msg);
 
     std::string endpoint() const;
+    std::string receiverId() const;
 
 private:
+    // answers the ZAP (RFC 27) requests until the context is terminated
+    void runZapHandler();
+
     std::string endpoint_;
+    bool topic_envelope_;
+    std::string receiver_id_;
+    uint64_t seq_ = 0;
     void *ctx_ = NULL;
     void *sock_ = NULL;
+
+    // the binary public keys of the allowed subscribers
+    std::set<std::string> allowed_keys_;
+    void *zap_sock_ = NULL;
+    std::thread zap_thread_;
 };
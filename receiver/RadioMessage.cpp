@@ -5,6 +5,9 @@
 
 using nlohmann::json;
 
+// see SchemaVersion in zmq_api/measurement.go
+static const int schema_version = 2;
+
 std::string RadioMessageTypeToString(const RadioMessage::Type t) {
     switch(t) {
         case RadioMessage::Type::Temperature: return "Temperature";
@@ -33,7 +36,10 @@ std::ostream& operator<<(std::ostream& os, const struct RadioMessage& msg) {
 }
 
 void to_json(json& j, const struct RadioMessage& msg) {
+    // 'timestamp' in seconds is kept for the version 1 consumers
     j = json{
+            { "version", schema_version },
+            { "timestamp_ms", msg.timestamp * 1000 },
             { "timestamp", msg.timestamp },
             { "device_id", msg.device_id },
             { "type", RadioMessageTypeToString(msg.type) },
@@ -40,6 +40,14 @@ int main(int argc, char** argv) {
             cxxopts::value<rf24_gpio_pin_t>()->default_value("0"))
         ("p,publish", "ZMQ Publish interface",
             cxxopts::value<std::string>()->default_value("tcp://127.0.0.1:5555"))
+        ("t,topic", "Prepend the 'sensors/<device_id>/<type>' topic frame to each message",
+            cxxopts::value<bool>()->default_value("false"))
+        ("k,curve-secret-key", "Encrypt the ZMQ connections with CURVE using this Z85 encoded secret key",
+            cxxopts::value<std::string>()->default_value(""))
+        ("a,curve-allowed-clients", "Only accept the subscribers with these Z85 encoded CURVE public keys",
+            cxxopts::value<std::vector<std::string>>())
+        ("r,receiver-id", "Id stamped on the published messages, <hostname>/<endpoint> by default",
+            cxxopts::value<std::string>()->default_value(""))
         ("d,debug", "Enable debugging",
             cxxopts::value<bool>()->default_value("false"))
         ("h,help", "Print usage")
@@ -60,7 +68,13 @@ int main(int argc, char** argv) {
                         opts["listen"].as<std::vector<std::string>>());
     receiver.init();
 
-    Publisher publisher(opts["publish"].as<std::string>());
+    std::vector<std::string> allowed_clients;
+    if (opts.count("curve-allowed-clients"))
+        allowed_clients = opts["curve-allowed-clients"].as<std::vector<std::string>>();
+
+    Publisher publisher(opts["publish"].as<std::string>(), opts["topic"].as<bool>(),
+                        opts["curve-secret-key"].as<std::string>(), allowed_clients,
+                        opts["receiver-id"].as<std::string>());
 
     std::cout << "Radio chip: Chip Enable pin = " << +receiver.cepin() <<
         " , Chip Select SPI pin = " << +receiver.cspin() << std::endl;
@@ -70,7 +84,7 @@ int main(int argc, char** argv) {
         join(", ", addr.begin(), addr.end()) << std::endl;
 
     std::cout << "Publishing JSON messages to ZMQ socket: " << 
-        publisher.endpoint() << std::endl;
+        publisher.endpoint() << " (receiver id: " << publisher.receiverId() << ")" << std::endl;
 
     while (true) {
         if (!receiver.messageAvailable()) {
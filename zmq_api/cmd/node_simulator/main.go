@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+	"github.com/kholmanskikh/home_sensors/zmq_api/zmq"
+)
+
+func parseDeviceIds(s string) ([]int, error) {
+	ret := make([]int, 0)
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		id, err := strconv.Atoi(item)
+		if (err != nil) || (id < 0) || (id > 255) {
+			return nil, fmt.Errorf("invalid device id '%s'", item)
+		}
+
+		ret = append(ret, id)
+	}
+
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("empty device id list")
+	}
+
+	return ret, nil
+}
+
+func checkProbability(name string, p float64) error {
+	if (p < 0) || (p > 1) {
+		return fmt.Errorf("invalid value for -%s: %v", name, p)
+	}
+
+	return nil
+}
+
+func main() {
+	ret := 1
+	defer func() {
+		os.Exit(ret)
+	}()
+
+	endpoint := flag.String("p", "tcp://127.0.0.1:5555", "ZMQ endpoint to bind the PUB socket to")
+	devices := flag.String("d", "1,2", "Comma separated list of simulated device ids")
+	interval := flag.Duration("i", 10*time.Second, "How often each device reports")
+	noise := flag.Float64("noise", 0.2, "Amplitude of the random noise added to the values")
+	dropout := flag.Float64("dropout", 0.05, "Probability that a message is lost")
+	duplicate := flag.Float64("duplicate", 0.02, "Probability that a message is sent twice")
+	errorRate := flag.Float64("error", 0.01, "Probability that a device reports an error instead of a value")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "Random seed")
+	encodingName := flag.String("encoding", zmq_api.EncodingJSON.String(),
+		"Message encoding: 'json' (as radio_receiver) or 'compact'")
+	debug := flag.Bool("debug", false, "Print each sent message")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			`Impersonates radio_receiver: publishes simulated Temperature/Humidity
+measurements and node errors to a ZMQ PUB socket.
+
+Usage: %s [options]
+
+`, os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	deviceIds, err := parseDeviceIds(*devices)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	encoding, err := zmq_api.ParseEncoding(*encodingName)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if *interval <= 0 {
+		log.Printf("invalid value for -i: %v", *interval)
+		return
+	}
+
+	for name, p := range map[string]float64{"dropout": *dropout,
+		"duplicate": *duplicate, "error": *errorRate} {
+		if err := checkProbability(name, p); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+
+	sim := newSimulator(simulatorConfig{Dropout: *dropout,
+		Duplicate: *duplicate,
+		Error:     *errorRate,
+		Noise:     *noise}, deviceIds, *seed)
+
+	ctx, err := zmq.NewContext()
+	if err != nil {
+		log.Printf("NewContext() failed: %v", err)
+		return
+	}
+	defer ctx.Terminate()
+
+	sock, err := zmq.NewSocket(ctx, zmq.SocketPUB)
+	if err != nil {
+		log.Printf("NewSocket() failed: %v", err)
+		return
+	}
+	defer sock.Close()
+
+	if err = sock.Bind(*endpoint); err != nil {
+		log.Printf("Bind('%s') failed: %v", *endpoint, err)
+		return
+	}
+
+	log.Printf("Publishing simulated messages for devices %v to '%s' (encoding: %v)",
+		deviceIds, *endpoint, encoding)
+
+	stopChan := make(chan os.Signal, 1)
+	signal.Notify(stopChan, os.Interrupt)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	ret = 0
+	for {
+		select {
+		case <-stopChan:
+			log.Printf("Exiting")
+			return
+		case now := <-ticker.C:
+			for _, m := range sim.generate(now) {
+				data, err := m.marshal(encoding)
+				if err != nil {
+					log.Printf("marshal() failed: %v", err)
+					continue
+				}
+
+				if *debug {
+					log.Printf("Sending %q", data)
+				}
+
+				if err = sock.Send(data); err != nil {
+					log.Printf("Send(%q) failed: %v", data, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+)
+
+// Error codes from radio_protocol.h and the strings radio_receiver
+// publishes for them.
+const (
+	errTempFailure  = 0xfc
+	errHumidFailure = 0xfd
+	errLowPower     = 0xfe
+	errOther        = 0xff
+)
+
+var errorCodes = []int{errTempFailure, errHumidFailure, errLowPower, errOther}
+
+func errorString(code int) string {
+	switch code {
+	case errTempFailure:
+		return "Temperature measurement error"
+	case errHumidFailure:
+		return "Humidity measurement error"
+	case errLowPower:
+		return "Low power"
+	case errOther:
+		return "Other error"
+	default:
+		return "Unknown error"
+	}
+}
+
+// radioMessage is what radio_receiver publishes: 'value' is set for
+// measurements, 'error' for errors.
+type radioMessage struct {
+	Version     int   `json:"version"`
+	TimestampMs int64 `json:"timestamp_ms"`
+
+	Timestamp int64    `json:"timestamp"`
+	DeviceId  int      `json:"device_id"`
+	Type      string   `json:"type"`
+	Value     *float64 `json:"value,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// the JSON encoding is the radio_receiver one, the compact one is
+// what zmq_api.Publisher sends
+func (m radioMessage) marshal(encoding zmq_api.Encoding) ([]byte, error) {
+	if encoding == zmq_api.EncodingJSON {
+		return json.Marshal(m)
+	}
+
+	measurement := zmq_api.Measurement{DeviceId: m.DeviceId,
+		Type:      zmq_api.Kind(m.Type),
+		Timestamp: int(m.Timestamp),
+		Error:     m.Error}
+
+	if m.Value != nil {
+		measurement.Value = *m.Value
+	}
+
+	return zmq_api.EncodeMeasurement(measurement, encoding)
+}
+
+type simulatorConfig struct {
+	// probabilities per generated message
+	Dropout   float64
+	Duplicate float64
+	Error     float64
+
+	// amplitude of the random noise added to the values
+	Noise float64
+}
+
+type node struct {
+	deviceId int
+
+	// each node is placed in a slightly different environment
+	tempOffset  float64
+	humidOffset float64
+}
+
+type simulator struct {
+	config simulatorConfig
+	rand   *rand.Rand
+	nodes  []node
+}
+
+func newSimulator(config simulatorConfig, deviceIds []int, seed int64) *simulator {
+	s := simulator{config: config, rand: rand.New(rand.NewSource(seed))}
+
+	for _, id := range deviceIds {
+		s.nodes = append(s.nodes, node{deviceId: id,
+			tempOffset:  s.rand.Float64()*4 - 2,
+			humidOffset: s.rand.Float64()*10 - 5})
+	}
+
+	return &s
+}
+
+// the fraction of the day, shifted so the minimum is at 03:00
+// and the maximum at 15:00
+func diurnalPhase(t time.Time) float64 {
+	secs := t.Hour()*3600 + t.Minute()*60 + t.Second()
+	return 2 * math.Pi * (float64(secs)/86400 - 0.375)
+}
+
+// the radio protocol transfers two fractional digits
+func roundValue(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func clamp(v, min, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
+}
+
+func (s *simulator) temperature(n node, t time.Time) float64 {
+	v := 21 + n.tempOffset + 3*math.Sin(diurnalPhase(t)) + s.rand.NormFloat64()*s.config.Noise
+	return roundValue(clamp(v, -40, 80))
+}
+
+// relative humidity goes down when the temperature goes up
+func (s *simulator) humidity(n node, t time.Time) float64 {
+	v := 45 + n.humidOffset - 10*math.Sin(diurnalPhase(t)) + s.rand.NormFloat64()*s.config.Noise*3
+	return roundValue(clamp(v, 1.01, 99.99))
+}
+
+func (s *simulator) chance(p float64) bool {
+	return s.rand.Float64() < p
+}
+
+// generate returns the messages the nodes send at time t
+func (s *simulator) generate(t time.Time) []radioMessage {
+	ret := make([]radioMessage, 0)
+
+	for _, n := range s.nodes {
+		for _, tp := range []string{"Temperature", "Humidity"} {
+			if s.chance(s.config.Dropout) {
+				continue
+			}
+
+			m := radioMessage{Version: zmq_api.SchemaVersion,
+				TimestampMs: t.Unix() * 1000,
+				Timestamp:   t.Unix(),
+				DeviceId:    n.deviceId,
+				Type:        tp}
+
+			if s.chance(s.config.Error) {
+				m.Type = "Error"
+				m.Error = errorString(errorCodes[s.rand.Intn(len(errorCodes))])
+			} else {
+				var v float64
+				if tp == "Temperature" {
+					v = s.temperature(n, t)
+				} else {
+					v = s.humidity(n, t)
+				}
+
+				m.Value = &v
+			}
+
+			ret = append(ret, m)
+
+			if s.chance(s.config.Duplicate) {
+				ret = append(ret, m)
+			}
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+)
+
+func TestRadioMessageMarshal(t *testing.T) {
+	v := 21.5
+	m := radioMessage{Version: 2, TimestampMs: 10000, Timestamp: 10, DeviceId: 1, Type: "Temperature", Value: &v}
+
+	data, err := m.marshal(zmq_api.EncodingJSON)
+	if err != nil {
+		t.Fatalf("marshal() failed: %v", err)
+	}
+
+	expected := `{"version":2,"timestamp_ms":10000,"timestamp":10,"device_id":1,"type":"Temperature","value":21.5}`
+	if string(data) != expected {
+		t.Fatalf("Got '%s', expected '%s'", data, expected)
+	}
+
+	m = radioMessage{Version: 2, TimestampMs: 10000, Timestamp: 10, DeviceId: 1, Type: "Error",
+		Error: errorString(errLowPower)}
+
+	data, err = m.marshal(zmq_api.EncodingJSON)
+	if err != nil {
+		t.Fatalf("marshal() failed: %v", err)
+	}
+
+	expected = `{"version":2,"timestamp_ms":10000,"timestamp":10,"device_id":1,"type":"Error","error":"Low power"}`
+	if string(data) != expected {
+		t.Fatalf("Got '%s', expected '%s'", data, expected)
+	}
+
+	data, err = m.marshal(zmq_api.EncodingCompact)
+	if err != nil {
+		t.Fatalf("marshal() failed: %v", err)
+	}
+
+	if (len(data) == 0) || (data[0] == '{') {
+		t.Fatalf("Got %q, expected a compact message", data)
+	}
+}
+
+func TestGenerateValues(t *testing.T) {
+	sim := newSimulator(simulatorConfig{Noise: 0.2}, []int{1, 2}, 1)
+
+	start := time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)
+	for h := 0; h < 24; h++ {
+		msgs := sim.generate(start.Add(time.Duration(h) * time.Hour))
+		if len(msgs) != 4 {
+			t.Fatalf("Generated %d messages, expected 4", len(msgs))
+		}
+
+		for _, m := range msgs {
+			data, _ := json.Marshal(m)
+
+			if m.Value == nil {
+				t.Fatalf("No value in '%s'", data)
+			}
+
+			if (m.Type == "Temperature") && ((*m.Value < 10) || (*m.Value > 30)) {
+				t.Fatalf("Unrealistic temperature in '%s'", data)
+			}
+
+			if (m.Type == "Humidity") && ((*m.Value < 20) || (*m.Value > 70)) {
+				t.Fatalf("Unrealistic humidity in '%s'", data)
+			}
+		}
+	}
+
+	// it is warmer in the afternoon than at night
+	night := sim.temperature(sim.nodes[0], start.Add(3*time.Hour))
+	afternoon := sim.temperature(sim.nodes[0], start.Add(15*time.Hour))
+	if night >= afternoon {
+		t.Fatalf("Temperature at night %v is not lower than in the afternoon %v", night, afternoon)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	sim := newSimulator(simulatorConfig{Error: 1}, []int{1}, 1)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		for _, m := range sim.generate(time.Now()) {
+			if (m.Type != "Error") || (m.Value != nil) {
+				t.Fatalf("Unexpected message %#v", m)
+			}
+
+			seen[m.Error] = true
+		}
+	}
+
+	for _, code := range errorCodes {
+		if !seen[errorString(code)] {
+			t.Fatalf("Error '%s' was never generated", errorString(code))
+		}
+	}
+}
+
+func TestGenerateDropoutAndDuplicates(t *testing.T) {
+	sim := newSimulator(simulatorConfig{Dropout: 1}, []int{1, 2}, 1)
+	if msgs := sim.generate(time.Now()); len(msgs) != 0 {
+		t.Fatalf("Generated %d messages with dropout 1", len(msgs))
+	}
+
+	sim = newSimulator(simulatorConfig{Duplicate: 1}, []int{1}, 1)
+	msgs := sim.generate(time.Now())
+	if (len(msgs) != 4) || (*msgs[0].Value != *msgs[1].Value) {
+		t.Fatalf("Unexpected messages with duplicate 1: %#v", msgs)
+	}
+}
+
+func TestParseDeviceIds(t *testing.T) {
+	ids, err := parseDeviceIds("1, 2,3")
+	if (err != nil) || (len(ids) != 3) || (ids[2] != 3) {
+		t.Fatalf("parseDeviceIds() returned %v, %v", ids, err)
+	}
+
+	for _, s := range []string{"", "a", "256", "-1"} {
+		if _, err := parseDeviceIds(s); err == nil {
+			t.Fatalf("parseDeviceIds('%s') did not fail", s)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api/zmq"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			`Generates a Z85 encoded CURVE keypair.
+
+The secret key of the receiver is passed via its --curve-secret-key option,
+its public key is the gateway's zmq_curve_server_key. The gateway's own keys
+are its zmq_curve_public_key and zmq_curve_secret_key.
+
+Usage: %s
+`, os.Args[0])
+	}
+
+	flag.Parse()
+
+	publicKey, secretKey, err := zmq.NewCurveKeypair()
+	if err != nil {
+		log.Printf("NewCurveKeypair() failed: %v", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("public: %s\nsecret: %s\n", publicKey, secretKey)
+}
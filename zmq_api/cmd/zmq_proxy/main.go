@@ -0,0 +1,109 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api/zmq"
+)
+
+func main() {
+	ret := 1
+	defer func() {
+		os.Exit(ret)
+	}()
+
+	frontend := flag.String("f", "tcp://127.0.0.1:5555",
+		"Comma separated list of the ZMQ endpoints of the receivers to subscribe to")
+	backend := flag.String("b", "tcp://*:5556", "ZMQ endpoint to bind for the subscribers")
+	capture := flag.String("c", "",
+		"ZMQ endpoint to bind a PUB socket receiving a copy of all forwarded messages (optional)")
+	statsInterval := flag.Duration("stats", time.Minute,
+		"How often to log the forwarding statistics, 0 disables it")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			`Forwards the measurements of the receivers to any number of subscribers.
+
+Usage: %s [options]
+
+`, os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if *statsInterval < 0 {
+		log.Printf("-stats must not be negative")
+		return
+	}
+
+	frontendEndpoints := make([]string, 0)
+	for _, endpoint := range strings.Split(*frontend, ",") {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			frontendEndpoints = append(frontendEndpoints, endpoint)
+		}
+	}
+
+	if len(frontendEndpoints) == 0 {
+		log.Printf("-f must be set")
+		return
+	}
+
+	ctx, err := zmq.NewContext()
+	if err != nil {
+		log.Printf("NewContext() failed: %v", err)
+		return
+	}
+	defer ctx.Terminate()
+
+	p, err := newProxy(ctx, frontendEndpoints, *backend, *capture)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer p.destroy()
+
+	var stopFlag int32
+
+	stopChan := make(chan os.Signal, 1)
+	signal.Notify(stopChan, os.Interrupt)
+
+	go func() {
+		<-stopChan
+		atomic.StoreInt32(&stopFlag, 1)
+		p.wakeup()
+	}()
+
+	if *statsInterval != 0 {
+		go func() {
+			for range time.Tick(*statsInterval) {
+				log.Printf("Stats: %v", p.getStats())
+			}
+		}()
+	}
+
+	log.Printf("Forwarding from %s to %s", strings.Join(frontendEndpoints, ", "), *backend)
+	if *capture != "" {
+		log.Printf("Capturing to %s", *capture)
+	}
+
+	for atomic.LoadInt32(&stopFlag) == 0 {
+		if _, err := p.poll(); err != nil {
+			log.Printf("Forwarding failed: %v", err)
+			return
+		}
+	}
+
+	log.Printf("Exiting. Stats: %v", p.getStats())
+	ret = 0
+}
@@ -0,0 +1,240 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api/zmq"
+)
+
+// at most this number of messages is forwarded in one direction
+// before the other one gets its turn
+const forwardBatchSize = 1000
+
+type directionStats struct {
+	Messages int
+	Bytes    int
+}
+
+type proxyStats struct {
+	// publishers -> subscribers
+	Downstream directionStats
+	// subscribers -> publishers, i.e. (un)subscriptions
+	Upstream directionStats
+	// messages not delivered to the capture socket
+	CaptureErrors int
+}
+
+func (s proxyStats) String() string {
+	return fmt.Sprintf("forwarded %d messages (%d bytes), %d subscription messages, %d capture errors",
+		s.Downstream.Messages, s.Downstream.Bytes, s.Upstream.Messages, s.CaptureErrors)
+}
+
+// proxy forwards messages between the XSUB socket connected to
+// the publishers and the XPUB socket the subscribers connect to.
+// Downstream messages are also sent to the optional capture socket.
+type proxy struct {
+	frontend *zmq.Socket
+	backend  *zmq.Socket
+	capture  *zmq.Socket
+
+	poller *zmq.Poller
+	msg    *zmq.Message
+
+	stats    proxyStats
+	statsMux sync.Mutex
+}
+
+func newProxy(ctx *zmq.Context, frontendEndpoints []string, backendEndpoint string,
+	captureEndpoint string) (*proxy, error) {
+	var err error
+	p := proxy{}
+
+	defer func() {
+		if err != nil {
+			p.destroy()
+		}
+	}()
+
+	p.frontend, err = zmq.NewSocket(ctx, zmq.SocketXSUB)
+	if err != nil {
+		err = fmt.Errorf("NewSocket(XSUB) failed: %v", err)
+		return nil, err
+	}
+
+	for _, endpoint := range frontendEndpoints {
+		if err = p.frontend.Connect(endpoint); err != nil {
+			err = fmt.Errorf("Connect('%s') failed: %v", endpoint, err)
+			return nil, err
+		}
+	}
+
+	p.backend, err = zmq.NewSocket(ctx, zmq.SocketXPUB)
+	if err != nil {
+		err = fmt.Errorf("NewSocket(XPUB) failed: %v", err)
+		return nil, err
+	}
+
+	if err = p.backend.Bind(backendEndpoint); err != nil {
+		err = fmt.Errorf("Bind('%s') failed: %v", backendEndpoint, err)
+		return nil, err
+	}
+
+	if captureEndpoint != "" {
+		p.capture, err = zmq.NewSocket(ctx, zmq.SocketPUB)
+		if err != nil {
+			err = fmt.Errorf("NewSocket(PUB) failed: %v", err)
+			return nil, err
+		}
+
+		if err = p.capture.Bind(captureEndpoint); err != nil {
+			err = fmt.Errorf("Bind('%s') failed: %v", captureEndpoint, err)
+			return nil, err
+		}
+	}
+
+	p.msg, err = zmq.NewMessage()
+	if err != nil {
+		err = fmt.Errorf("NewMessage() failed: %v", err)
+		return nil, err
+	}
+
+	p.poller, err = zmq.NewPoller()
+	if err != nil {
+		err = fmt.Errorf("NewPoller() failed: %v", err)
+		return nil, err
+	}
+
+	for _, sock := range []*zmq.Socket{p.frontend, p.backend} {
+		if err = p.poller.AddSocket(sock, zmq.PollIn); err != nil {
+			err = fmt.Errorf("AddSocket() failed: %v", err)
+			return nil, err
+		}
+	}
+
+	return &p, nil
+}
+
+func (p *proxy) destroy() {
+	if p.poller != nil {
+		p.poller.Close()
+	}
+
+	if p.msg != nil {
+		p.msg.Close()
+	}
+
+	for _, sock := range []*zmq.Socket{p.frontend, p.backend, p.capture} {
+		if sock != nil {
+			sock.SetLinger(0)
+			sock.Close()
+		}
+	}
+}
+
+// forward moves up to forwardBatchSize whole messages from src to dst
+// without copying them
+func (p *proxy) forward(src *zmq.Socket, dst *zmq.Socket, capture *zmq.Socket,
+	stats *directionStats) error {
+	for i := 0; i < forwardBatchSize; i++ {
+		err, received := src.RecvMessageNonBlocking(p.msg)
+		if err != nil {
+			return fmt.Errorf("RecvMessageNonBlocking() failed: %v", err)
+		}
+
+		if !received {
+			return nil
+		}
+
+		// the rest of a multipart message is already there
+		for {
+			more := p.msg.More()
+			size := p.msg.Size()
+
+			if capture != nil {
+				if err := p.sendCapture(more); err != nil {
+					return err
+				}
+			}
+
+			if err := dst.SendMessage(p.msg, more); err != nil {
+				return fmt.Errorf("SendMessage() failed: %v", err)
+			}
+
+			p.statsMux.Lock()
+			stats.Bytes += size
+			if !more {
+				stats.Messages += 1
+			}
+			p.statsMux.Unlock()
+
+			if !more {
+				break
+			}
+
+			if err := src.RecvMessage(p.msg); err != nil {
+				return fmt.Errorf("RecvMessage() failed: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// the capture socket gets a reference to the same data, not a copy
+func (p *proxy) sendCapture(more bool) error {
+	captured, err := zmq.NewMessage()
+	if err != nil {
+		return fmt.Errorf("NewMessage() failed: %v", err)
+	}
+	defer captured.Close()
+
+	if err := captured.Copy(p.msg); err != nil {
+		return err
+	}
+
+	if err := p.capture.SendMessage(captured, more); err != nil {
+		p.statsMux.Lock()
+		p.stats.CaptureErrors += 1
+		p.statsMux.Unlock()
+	}
+
+	return nil
+}
+
+// poll waits for messages and forwards them, it returns false if
+// nothing was forwarded, e.g. because of wakeup()
+func (p *proxy) poll() (bool, error) {
+	items, err := p.poller.Poll(-1)
+	if err != nil {
+		return false, fmt.Errorf("Poll() failed: %v", err)
+	}
+
+	for _, item := range items {
+		if item.Socket == p.frontend {
+			err = p.forward(p.frontend, p.backend, p.capture, &p.stats.Downstream)
+		} else {
+			err = p.forward(p.backend, p.frontend, nil, &p.stats.Upstream)
+		}
+
+		if err != nil {
+			return true, err
+		}
+	}
+
+	return len(items) != 0, nil
+}
+
+func (p *proxy) wakeup() error {
+	return p.poller.Wakeup()
+}
+
+func (p *proxy) getStats() proxyStats {
+	p.statsMux.Lock()
+	defer p.statsMux.Unlock()
+
+	return p.stats
+}
@@ -0,0 +1,131 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package main
+
+import (
+	"bytes"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api/zmq"
+)
+
+func TestProxyStatsString(t *testing.T) {
+	stats := proxyStats{
+		Downstream:    directionStats{Messages: 10, Bytes: 1024},
+		Upstream:      directionStats{Messages: 2, Bytes: 4},
+		CaptureErrors: 1,
+	}
+
+	expected := "forwarded 10 messages (1024 bytes), 2 subscription messages, 1 capture errors"
+	if s := stats.String(); s != expected {
+		t.Fatalf("String() returned '%s', expected '%s'", s, expected)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	ctx, err := zmq.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext() failed: %v", err)
+	}
+	defer ctx.Terminate()
+
+	pub, err := zmq.NewSocket(ctx, zmq.SocketPUB)
+	if err != nil {
+		t.Fatalf("NewSocket() failed: %v", err)
+	}
+	defer pub.Close()
+
+	if err := pub.Bind("inproc://proxy_frontend"); err != nil {
+		t.Fatalf("Bind() failed: %v", err)
+	}
+
+	p, err := newProxy(ctx, []string{"inproc://proxy_frontend"}, "inproc://proxy_backend",
+		"inproc://proxy_capture")
+	if err != nil {
+		t.Fatalf("newProxy() failed: %v", err)
+	}
+	defer p.destroy()
+
+	var stopFlag int32
+	doneChan := make(chan error)
+	go func() {
+		for atomic.LoadInt32(&stopFlag) == 0 {
+			if _, err := p.poll(); err != nil {
+				doneChan <- err
+				return
+			}
+		}
+		doneChan <- nil
+	}()
+
+	sockets := make([]*zmq.Socket, 0)
+	for _, endpoint := range []string{"inproc://proxy_backend", "inproc://proxy_capture"} {
+		sock, err := zmq.NewSocket(ctx, zmq.SocketSUB)
+		if err != nil {
+			t.Fatalf("NewSocket() failed: %v", err)
+		}
+		defer sock.Close()
+
+		if err := sock.Connect(endpoint); err != nil {
+			t.Fatalf("Connect() failed: %v", err)
+		}
+
+		if err := sock.AddSubscribeFilter([]byte{}); err != nil {
+			t.Fatalf("AddSubscribeFilter() failed: %v", err)
+		}
+
+		sockets = append(sockets, sock)
+	}
+
+	expected := [][]byte{[]byte("topic"), []byte("data")}
+
+	// the subscriptions have to reach the publisher through the proxy first,
+	// so both subscribers may miss the first messages
+	received := make([][][]byte, len(sockets))
+	for i := 0; (i < 100) && ((received[0] == nil) || (received[1] == nil)); i++ {
+		if err := pub.SendMultipart(expected); err != nil {
+			t.Fatalf("SendMultipart() failed: %v", err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+
+		for j, sock := range sockets {
+			frames, err, ok := sock.RecvMultipartNonBlocking()
+			if err != nil {
+				t.Fatalf("RecvMultipartNonBlocking() failed: %v", err)
+			}
+
+			if ok {
+				received[j] = frames
+			}
+		}
+	}
+
+	for j, frames := range received {
+		if frames == nil {
+			t.Fatalf("No message received by subscriber %d", j)
+		}
+
+		if (len(frames) != len(expected)) || (!bytes.Equal(frames[0], expected[0])) ||
+			(!bytes.Equal(frames[1], expected[1])) {
+			t.Fatalf("Subscriber %d received '%q', expected '%q'", j, frames, expected)
+		}
+	}
+
+	atomic.StoreInt32(&stopFlag, 1)
+	if err := p.wakeup(); err != nil {
+		t.Fatalf("wakeup() failed: %v", err)
+	}
+
+	if err := <-doneChan; err != nil {
+		t.Fatalf("poll() failed: %v", err)
+	}
+
+	stats := p.getStats()
+	if stats.Downstream.Messages == 0 || stats.Upstream.Messages == 0 {
+		t.Fatalf("Unexpected stats: %v", stats)
+	}
+}
@@ -0,0 +1,300 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api/zmq"
+)
+
+var zmqPollTimeout = time.Second
+
+func usage() {
+	fmt.Fprintf(os.Stderr,
+		`Records raw ZMQ messages to a file and replays them.
+
+Usage:
+  %[1]s record -e "<endpoint_to_subscribe_to>" -o "<recording_file>"
+  %[1]s replay -i "<recording_file>" -b "<endpoint_to_bind>" [-speed <speed>] [-loop]
+
+Run '%[1]s <mode> -h' for the mode options.
+`, os.Args[0])
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "record":
+		err = runRecord(os.Args[2:])
+	case "replay":
+		err = runReplay(os.Args[2:])
+	case "-h", "-help", "--help":
+		usage()
+		return
+	default:
+		usage()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
+
+// notifyOnInterrupt returns a channel which is closed on the first
+// interrupt, so any number of waits can select on it
+func notifyOnInterrupt() <-chan struct{} {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt)
+
+	stopChan := make(chan struct{})
+	go func() {
+		<-sigChan
+		close(stopChan)
+	}()
+
+	return stopChan
+}
+
+func isStopped(stopChan <-chan struct{}) bool {
+	select {
+	case <-stopChan:
+		return true
+	default:
+		return false
+	}
+}
+
+func runRecord(args []string) error {
+	flags := flag.NewFlagSet("record", flag.ExitOnError)
+	endpoint := flags.String("e", "", "ZMQ endpoint to subscribe to")
+	output := flags.String("o", "", "Recording file ('-' for stdout)")
+	flags.Parse(args)
+
+	if (*endpoint == "") || (*output == "") {
+		flags.Usage()
+		return fmt.Errorf("both -e and -o must be set")
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		out = file
+	}
+
+	bufOut := bufio.NewWriter(out)
+	defer bufOut.Flush()
+
+	writer := newRecordWriter(bufOut)
+
+	ctx, err := zmq.NewContext()
+	if err != nil {
+		return fmt.Errorf("NewContext() failed: %v", err)
+	}
+	defer ctx.Terminate()
+
+	sock, err := zmq.NewSocket(ctx, zmq.SocketSUB)
+	if err != nil {
+		return fmt.Errorf("NewSocket() failed: %v", err)
+	}
+	defer sock.Close()
+
+	if err = sock.Connect(*endpoint); err != nil {
+		return fmt.Errorf("Connect('%s') failed: %v", *endpoint, err)
+	}
+
+	if err = sock.AddSubscribeFilter(nil); err != nil {
+		return fmt.Errorf("AddSubscribeFilter('') failed: %v", err)
+	}
+
+	poller, err := zmq.NewReadPoller(sock)
+	if err != nil {
+		return fmt.Errorf("NewReadPoller() failed: %v", err)
+	}
+
+	stopChan := notifyOnInterrupt()
+
+	log.Printf("Recording '%s' to '%s'", *endpoint, *output)
+
+	n := 0
+	for !isStopped(stopChan) {
+		// ReadPoller is edge-triggered, so everything available is
+		// received no matter what Poll() returns
+		if _, err := poller.Poll(zmqPollTimeout); err != nil {
+			return fmt.Errorf("Poll() failed: %v", err)
+		}
+
+		recorded, err := recordAvailable(sock, writer)
+		n += recorded
+		if err != nil {
+			return err
+		}
+
+		if err = bufOut.Flush(); err != nil {
+			return fmt.Errorf("unable to write the records: %v", err)
+		}
+	}
+
+	log.Printf("Recorded %d messages", n)
+
+	return nil
+}
+
+// records the messages available on the socket without blocking
+func recordAvailable(sock *zmq.Socket, writer *recordWriter) (int, error) {
+	n := 0
+	for {
+		frames, err, received := sock.RecvMultipartNonBlocking()
+		if err != nil {
+			log.Printf("RecvMultipartNonBlocking() failed: %v", err)
+			return n, nil
+		}
+
+		if !received {
+			return n, nil
+		}
+
+		if err = writer.Write(record{ReceivedAt: time.Now(), Frames: frames}); err != nil {
+			return n, fmt.Errorf("unable to write the record: %v", err)
+		}
+
+		n += 1
+	}
+}
+
+func runReplay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	input := flags.String("i", "", "Recording file ('-' for stdin)")
+	endpoint := flags.String("b", "", "ZMQ endpoint to bind the PUB socket to")
+	speedStr := flags.String("speed", "1",
+		"Replay speed: 1 is the original pace, 10 is 10 times faster, 'max' is as fast as possible")
+	loop := flags.Bool("loop", false, "Replay the recording in a loop")
+	wait := flags.Duration("wait", time.Second,
+		"How long to wait after binding to let subscribers connect")
+	flags.Parse(args)
+
+	if (*input == "") || (*endpoint == "") {
+		flags.Usage()
+		return fmt.Errorf("both -i and -b must be set")
+	}
+
+	speed, err := parseSpeed(*speedStr)
+	if err != nil {
+		return err
+	}
+
+	if (*loop) && (*input == "-") {
+		return fmt.Errorf("-loop cannot be used with stdin")
+	}
+
+	ctx, err := zmq.NewContext()
+	if err != nil {
+		return fmt.Errorf("NewContext() failed: %v", err)
+	}
+	defer ctx.Terminate()
+
+	sock, err := zmq.NewSocket(ctx, zmq.SocketPUB)
+	if err != nil {
+		return fmt.Errorf("NewSocket() failed: %v", err)
+	}
+	defer sock.Close()
+
+	if err = sock.Bind(*endpoint); err != nil {
+		return fmt.Errorf("Bind('%s') failed: %v", *endpoint, err)
+	}
+
+	stopChan := notifyOnInterrupt()
+
+	// PUB drops everything sent before subscribers are connected
+	select {
+	case <-time.After(*wait):
+	case <-stopChan:
+		return nil
+	}
+
+	log.Printf("Replaying '%s' to '%s'", *input, *endpoint)
+
+	n := 0
+	for {
+		sent, err := replayOnce(*input, sock, speed, stopChan)
+		n += sent
+		if err != nil {
+			return err
+		}
+
+		if (!*loop) || (isStopped(stopChan)) {
+			break
+		}
+	}
+
+	log.Printf("Replayed %d messages", n)
+
+	return nil
+}
+
+func replayOnce(input string, sock *zmq.Socket, speed float64, stopChan <-chan struct{}) (int, error) {
+	var in io.Reader = os.Stdin
+	if input != "-" {
+		file, err := os.Open(input)
+		if err != nil {
+			return 0, err
+		}
+		defer file.Close()
+
+		in = file
+	}
+
+	return replayRecords(newRecordReader(bufio.NewReader(in)), sock, speed, stopChan)
+}
+
+func replayRecords(reader *recordReader, sock *zmq.Socket, speed float64, stopChan <-chan struct{}) (int, error) {
+	n := 0
+	var prev time.Time
+	for !isStopped(stopChan) {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return n, err
+		}
+
+		if n != 0 {
+			select {
+			case <-time.After(replayDelay(prev, rec.ReceivedAt, speed)):
+			case <-stopChan:
+				return n, nil
+			}
+		}
+		prev = rec.ReceivedAt
+
+		if err = sock.SendMultipart(rec.Frames); err != nil {
+			return n, fmt.Errorf("SendMultipart() failed: %v", err)
+		}
+
+		n += 1
+	}
+
+	return n, nil
+}
@@ -0,0 +1,130 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+	"github.com/kholmanskikh/home_sensors/zmq_api/zmq"
+)
+
+func TestRecordReplayTopicEnvelope(t *testing.T) {
+	const publisherEndpoint = "tcp://127.0.0.1:9020"
+	const replayEndpoint = "tcp://127.0.0.1:9021"
+
+	m1 := zmq_api.Measurement{DeviceId: 1, Type: zmq_api.KindTemperature, Value: 20.5, Timestamp: 1}
+	m2 := zmq_api.Measurement{DeviceId: 2, Type: zmq_api.KindHumidity, Value: 45, Timestamp: 2}
+
+	p, err := zmq_api.NewPublisher(publisherEndpoint, zmq_api.WithTopicEnvelope())
+	if err != nil {
+		t.Fatalf("NewPublisher() failed: %v", err)
+	}
+	defer p.Destroy()
+
+	ctx, err := zmq.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext() failed: %v", err)
+	}
+	defer ctx.Terminate()
+
+	sub, err := zmq.NewSocket(ctx, zmq.SocketSUB)
+	if err != nil {
+		t.Fatalf("NewSocket() failed: %v", err)
+	}
+	defer sub.Close()
+
+	if err = sub.Connect(publisherEndpoint); err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+
+	if err = sub.AddSubscribeFilter(nil); err != nil {
+		t.Fatalf("AddSubscribeFilter() failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	writer := newRecordWriter(&buf)
+
+	// PUB drops the messages until the subscriber is connected
+	recorded := 0
+	for i := 0; (i < 50) && (recorded < 2); i++ {
+		for _, m := range []zmq_api.Measurement{m1, m2} {
+			if err := p.PublishMeasurement(m); err != nil {
+				t.Fatalf("PublishMeasurement() failed: %v", err)
+			}
+		}
+
+		time.Sleep(100 * time.Millisecond)
+
+		n, err := recordAvailable(sub, writer)
+		if err != nil {
+			t.Fatalf("recordAvailable() failed: %v", err)
+		}
+		recorded += n
+	}
+
+	if recorded < 2 {
+		t.Fatalf("Recorded %d messages, expected at least 2", recorded)
+	}
+
+	recording := buf.String()
+
+	reader := newRecordReader(strings.NewReader(recording))
+	for i := 0; i < recorded; i++ {
+		rec, err := reader.Read()
+		if err != nil {
+			t.Fatalf("Read() failed: %v", err)
+		}
+
+		if (len(rec.Frames) != 2) || (!strings.HasPrefix(string(rec.Frames[0]), "sensors/")) {
+			t.Fatalf("Record %d is not a topic envelope: %q", i, rec.Frames)
+		}
+	}
+
+	pub, err := zmq.NewSocket(ctx, zmq.SocketPUB)
+	if err != nil {
+		t.Fatalf("NewSocket() failed: %v", err)
+	}
+	defer pub.Close()
+
+	if err = pub.Bind(replayEndpoint); err != nil {
+		t.Fatalf("Bind() failed: %v", err)
+	}
+
+	s, err := zmq_api.NewSubscriber(replayEndpoint,
+		zmq_api.WithTopicFilters(zmq_api.DeviceTopicFilter(2)))
+	if err != nil {
+		t.Fatalf("NewSubscriber() failed: %v", err)
+	}
+	defer s.Destroy()
+
+	stopChan := make(chan struct{})
+
+	received := 0
+	for i := 0; (i < 50) && (received == 0); i++ {
+		if _, err := replayRecords(newRecordReader(strings.NewReader(recording)), pub, 0, stopChan); err != nil {
+			t.Fatalf("replayRecords() failed: %v", err)
+		}
+
+		measurements, err := s.RecvMeasurement(time.Millisecond * 100)
+		if err != nil {
+			t.Fatalf("RecvMeasurement() failed: %v", err)
+		}
+
+		for _, m := range measurements {
+			if (m.DeviceId != m2.DeviceId) || (m.Type != m2.Type) || (m.Value != m2.Value) {
+				t.Fatalf("Got '%#v', expected '%#v'", *m, m2)
+			}
+
+			received += 1
+		}
+	}
+
+	if received == 0 {
+		t.Fatalf("No replayed measurement received")
+	}
+}
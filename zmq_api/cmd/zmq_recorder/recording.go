@@ -0,0 +1,100 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// A recording is a sequence of JSON objects, one per line. Frames are
+// the raw frames of a ZMQ message (base64 encoded by encoding/json), so
+// the topic envelope is kept and messages which are not valid JSON or
+// UTF-8 are recorded as is.
+type record struct {
+	ReceivedAt time.Time `json:"received_at"`
+	Frames     [][]byte  `json:"frames"`
+
+	// single frame messages in the older recordings
+	Data []byte `json:"data,omitempty"`
+}
+
+type recordWriter struct {
+	encoder *json.Encoder
+}
+
+func newRecordWriter(w io.Writer) *recordWriter {
+	return &recordWriter{encoder: json.NewEncoder(w)}
+}
+
+func (w *recordWriter) Write(r record) error {
+	return w.encoder.Encode(r)
+}
+
+type recordReader struct {
+	decoder *json.Decoder
+	n       int
+}
+
+func newRecordReader(r io.Reader) *recordReader {
+	return &recordReader{decoder: json.NewDecoder(r)}
+}
+
+// returns io.EOF when there are no more records
+func (r *recordReader) Read() (record, error) {
+	var rec record
+
+	err := r.decoder.Decode(&rec)
+	if err == io.EOF {
+		return rec, err
+	}
+
+	r.n += 1
+	if err != nil {
+		return rec, fmt.Errorf("unable to decode record %d: %v", r.n, err)
+	}
+
+	if (len(rec.Frames) == 0) && (rec.Data != nil) {
+		rec.Frames = [][]byte{rec.Data}
+		rec.Data = nil
+	}
+
+	if len(rec.Frames) == 0 {
+		return rec, fmt.Errorf("record %d has no frames", r.n)
+	}
+
+	return rec, nil
+}
+
+// speed is a multiplier of the original pace, 0 means 'as fast as possible'
+func parseSpeed(s string) (float64, error) {
+	if s == "max" {
+		return 0, nil
+	}
+
+	speed, err := strconv.ParseFloat(s, 64)
+	if (err != nil) || (speed <= 0) {
+		return 0, fmt.Errorf("invalid speed '%s'", s)
+	}
+
+	return speed, nil
+}
+
+// returns how long to wait before sending the record received at 'cur'
+// if the previous one was received at 'prev'
+func replayDelay(prev, cur time.Time, speed float64) time.Duration {
+	if speed == 0 {
+		return 0
+	}
+
+	d := cur.Sub(prev)
+	if d <= 0 {
+		return 0
+	}
+
+	return time.Duration(float64(d) / speed)
+}
@@ -0,0 +1,124 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordWriteRead(t *testing.T) {
+	now := time.Now()
+	records := []record{
+		{ReceivedAt: now, Frames: [][]byte{[]byte(`{"device_id": 1, "type": "Temperature"}`)}},
+		{ReceivedAt: now.Add(time.Second), Frames: [][]byte{[]byte("sensors/1/"), {0x0, 0xff, '\n'}}},
+	}
+
+	var buf bytes.Buffer
+	writer := newRecordWriter(&buf)
+	for _, r := range records {
+		if err := writer.Write(r); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+	}
+
+	reader := newRecordReader(&buf)
+	for i, expected := range records {
+		r, err := reader.Read()
+		if err != nil {
+			t.Fatalf("Read() of record %d failed: %v", i, err)
+		}
+
+		if (!r.ReceivedAt.Equal(expected.ReceivedAt)) || (len(r.Frames) != len(expected.Frames)) {
+			t.Fatalf("Read '%#v', expected '%#v'", r, expected)
+		}
+
+		for j := range r.Frames {
+			if !bytes.Equal(r.Frames[j], expected.Frames[j]) {
+				t.Fatalf("Read '%#v', expected '%#v'", r, expected)
+			}
+		}
+	}
+
+	if _, err := reader.Read(); err != io.EOF {
+		t.Fatalf("Unexpected error at the end of the recording: %v", err)
+	}
+}
+
+func TestRecordReadSingleFrame(t *testing.T) {
+	reader := newRecordReader(strings.NewReader(`{"received_at": "2024-01-01T00:00:00Z", "data": "YWJj"}`))
+
+	r, err := reader.Read()
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+
+	if (len(r.Frames) != 1) || (string(r.Frames[0]) != "abc") {
+		t.Fatalf("Read '%#v', expected a single 'abc' frame", r)
+	}
+}
+
+func TestRecordReadInvalid(t *testing.T) {
+	reader := newRecordReader(strings.NewReader("not a record"))
+
+	_, err := reader.Read()
+	if (err == nil) || (!strings.Contains(err.Error(), "record 1")) {
+		t.Fatalf("Unexpected error from Read(): %v", err)
+	}
+
+	reader = newRecordReader(strings.NewReader(`{"received_at": "2024-01-01T00:00:00Z"}`))
+	if _, err = reader.Read(); (err == nil) || (!strings.Contains(err.Error(), "no frames")) {
+		t.Fatalf("Unexpected error from Read(): %v", err)
+	}
+}
+
+func TestParseSpeed(t *testing.T) {
+	checks := []struct {
+		s        string
+		expected float64
+		valid    bool
+	}{
+		{"max", 0, true},
+		{"1", 1, true},
+		{"2.5", 2.5, true},
+		{"0", 0, false},
+		{"-1", 0, false},
+		{"fast", 0, false},
+	}
+
+	for _, check := range checks {
+		speed, err := parseSpeed(check.s)
+		if (err == nil) != check.valid {
+			t.Fatalf("Unexpected error from parseSpeed('%s'): %v", check.s, err)
+		}
+
+		if speed != check.expected {
+			t.Fatalf("parseSpeed('%s') returned %v, expected %v", check.s, speed, check.expected)
+		}
+	}
+}
+
+func TestReplayDelay(t *testing.T) {
+	prev := time.Now()
+	cur := prev.Add(10 * time.Second)
+
+	if d := replayDelay(prev, cur, 1); d != 10*time.Second {
+		t.Fatalf("Unexpected delay at the original speed: %v", d)
+	}
+
+	if d := replayDelay(prev, cur, 10); d != time.Second {
+		t.Fatalf("Unexpected delay at 10x speed: %v", d)
+	}
+
+	if d := replayDelay(prev, cur, 0); d != 0 {
+		t.Fatalf("Unexpected delay at max speed: %v", d)
+	}
+
+	if d := replayDelay(cur, prev, 1); d != 0 {
+		t.Fatalf("Unexpected delay for records out of order: %v", d)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+)
+
+func main() {
+	output := flag.String("o", "", "Write the schema to this file instead of stdout")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			`Prints the JSON Schema of the measurement messages, version %d.
+
+Usage: %s [options]
+`, zmq_api.SchemaVersion, os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	schema, err := zmq_api.MeasurementSchema()
+	if err != nil {
+		log.Printf("MeasurementSchema() failed: %v", err)
+		os.Exit(1)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(schema)
+		return
+	}
+
+	if err := ioutil.WriteFile(*output, schema, 0644); err != nil {
+		log.Printf("WriteFile('%s') failed: %v", *output, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+)
+
+type filter struct {
+	deviceIds map[int]bool
+	types     map[string]bool
+}
+
+func newFilter(deviceIds string, types string) (*filter, error) {
+	f := filter{}
+
+	for _, item := range strings.Split(deviceIds, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		id, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid device id '%s'", item)
+		}
+
+		if f.deviceIds == nil {
+			f.deviceIds = make(map[int]bool)
+		}
+		f.deviceIds[id] = true
+	}
+
+	for _, item := range strings.Split(types, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if f.types == nil {
+			f.types = make(map[string]bool)
+		}
+		f.types[zmq_api.ParseKind(item).Name()] = true
+	}
+
+	return &f, nil
+}
+
+func (f *filter) matches(m zmq_api.Measurement) bool {
+	if (f.deviceIds != nil) && (!f.deviceIds[m.DeviceId]) {
+		return false
+	}
+
+	if (f.types != nil) && (!f.types[m.Type.Name()]) {
+		return false
+	}
+
+	return true
+}
+
+type formatter interface {
+	Format(m zmq_api.Measurement) error
+	Flush() error
+}
+
+func newFormatter(name string, w io.Writer) (formatter, error) {
+	switch name {
+	case "human":
+		return &humanFormatter{w: w}, nil
+	case "json":
+		return &jsonFormatter{encoder: json.NewEncoder(w)}, nil
+	case "csv":
+		return &csvFormatter{w: csv.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown format '%s'", name)
+	}
+}
+
+type humanFormatter struct {
+	w io.Writer
+}
+
+func (f *humanFormatter) Format(m zmq_api.Measurement) error {
+	ts := m.Time().Format("2006-01-02 15:04:05")
+
+	var err error
+	if m.Error != "" {
+		_, err = fmt.Fprintf(f.w, "%s device %d %s: %s\n", ts, m.DeviceId, m.Type, m.Error)
+	} else {
+		_, err = fmt.Fprintf(f.w, "%s device %d %s: %.2f\n", ts, m.DeviceId, m.Type, m.Value)
+	}
+
+	return err
+}
+
+func (f *humanFormatter) Flush() error {
+	return nil
+}
+
+type jsonFormatter struct {
+	encoder *json.Encoder
+}
+
+func (f *jsonFormatter) Format(m zmq_api.Measurement) error {
+	return f.encoder.Encode(m)
+}
+
+func (f *jsonFormatter) Flush() error {
+	return nil
+}
+
+type csvFormatter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (f *csvFormatter) Format(m zmq_api.Measurement) error {
+	if !f.headerWritten {
+		if err := f.w.Write([]string{"timestamp", "device_id", "type", "value", "error"}); err != nil {
+			return err
+		}
+
+		f.headerWritten = true
+	}
+
+	return f.w.Write([]string{strconv.Itoa(m.Timestamp),
+		strconv.Itoa(m.DeviceId),
+		string(m.Type),
+		strconv.FormatFloat(m.Value, 'f', -1, 64),
+		m.Error})
+}
+
+func (f *csvFormatter) Flush() error {
+	f.w.Flush()
+	return f.w.Error()
+}
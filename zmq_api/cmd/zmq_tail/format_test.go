@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+)
+
+func TestFilter(t *testing.T) {
+	f, err := newFilter("1, 2", "temperature")
+	if err != nil {
+		t.Fatalf("newFilter() failed: %v", err)
+	}
+
+	checks := []struct {
+		m        zmq_api.Measurement
+		expected bool
+	}{
+		{zmq_api.Measurement{DeviceId: 1, Type: "Temperature"}, true},
+		{zmq_api.Measurement{DeviceId: 3, Type: "Temperature"}, false},
+		{zmq_api.Measurement{DeviceId: 2, Type: "Humidity"}, false},
+	}
+
+	for _, check := range checks {
+		if got := f.matches(check.m); got != check.expected {
+			t.Fatalf("matches(%#v) returned %v, expected %v", check.m, got, check.expected)
+		}
+	}
+
+	f, err = newFilter("", "")
+	if (err != nil) || (!f.matches(zmq_api.Measurement{DeviceId: 99, Type: "Any"})) {
+		t.Fatalf("An empty filter must match everything")
+	}
+
+	if _, err = newFilter("x", ""); err == nil {
+		t.Fatalf("newFilter() did not fail for an invalid device id")
+	}
+}
+
+func formatOrFail(t *testing.T, name string, measurements ...zmq_api.Measurement) string {
+	var buf bytes.Buffer
+
+	f, err := newFormatter(name, &buf)
+	if err != nil {
+		t.Fatalf("newFormatter('%s') failed: %v", name, err)
+	}
+
+	for _, m := range measurements {
+		if err := f.Format(m); err != nil {
+			t.Fatalf("Format() failed: %v", err)
+		}
+	}
+
+	if err := f.Flush(); err != nil {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	return buf.String()
+}
+
+func TestFormatters(t *testing.T) {
+	m1 := zmq_api.Measurement{DeviceId: 1, Type: "Temperature", Value: 21.5, Timestamp: 10}
+	m2 := zmq_api.Measurement{DeviceId: 2, Type: "Error", Timestamp: 11, Error: "Low power"}
+
+	got := formatOrFail(t, "json", m1, m2)
+	expected := `{"version":2,"timestamp_ms":10000,"timestamp":10,"device_id":1,"type":"Temperature","value":21.5}
+{"version":2,"timestamp_ms":11000,"timestamp":11,"device_id":2,"type":"Error","error":"Low power"}
+`
+	if got != expected {
+		t.Fatalf("Got '%s', expected '%s'", got, expected)
+	}
+
+	got = formatOrFail(t, "csv", m1, m2)
+	expected = `timestamp,device_id,type,value,error
+10,1,Temperature,21.5,
+11,2,Error,0,Low power
+`
+	if got != expected {
+		t.Fatalf("Got '%s', expected '%s'", got, expected)
+	}
+
+	got = formatOrFail(t, "human", m1, m2)
+	ts := time.Unix(10, 0).Format("2006-01-02 15:04:05")
+	if !strings.HasPrefix(got, ts+" device 1 Temperature: 21.50\n") ||
+		!strings.HasSuffix(got, " device 2 Error: Low power\n") {
+		t.Fatalf("Unexpected output '%s'", got)
+	}
+
+	if _, err := newFormatter("xml", &bytes.Buffer{}); err == nil {
+		t.Fatalf("newFormatter() did not fail for an unknown format")
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+)
+
+// exit codes
+const (
+	exitOk      = 0
+	exitError   = 1
+	exitTimeout = 2
+)
+
+var zmqPollTimeout = time.Second
+
+func main() {
+	ret := exitError
+	defer func() {
+		os.Exit(ret)
+	}()
+
+	endpoint := flag.String("e", "tcp://127.0.0.1:5555", "ZMQ endpoint to subscribe to")
+	format := flag.String("f", "human", "Output format: human, json or csv")
+	deviceIds := flag.String("d", "", "Comma separated list of device ids to show (default all)")
+	types := flag.String("t", "", "Comma separated list of measurement types to show (default all)")
+	count := flag.Int("n", 0, "Exit after receiving this number of matching measurements")
+	timeout := flag.Duration("timeout", 0,
+		fmt.Sprintf("Exit with code %d if no matching measurement is received within this time", exitTimeout))
+	statsInterval := flag.Duration("stats", 0,
+		"Instead of the measurements, print per-device statistics with this interval")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			`Prints measurements received from the ZMQ endpoint.
+
+Usage: %s [options]
+
+Exit codes:
+  %d - success (interrupted or -n measurements received)
+  %d - error
+  %d - no matching measurement received within -timeout
+
+`, os.Args[0], exitOk, exitError, exitTimeout)
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	f, err := newFilter(*deviceIds, *types)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	formatter, err := newFormatter(*format, os.Stdout)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if (*count < 0) || (*timeout < 0) || (*statsInterval < 0) {
+		log.Printf("-n, -timeout and -stats must not be negative")
+		return
+	}
+
+	subscriber, err := zmq_api.NewSubscriber(*endpoint)
+	if err != nil {
+		log.Printf("NewSubscriber() failed: %v", err)
+		return
+	}
+	defer subscriber.Destroy()
+
+	stopChan := make(chan os.Signal, 1)
+	signal.Notify(stopChan, os.Interrupt)
+
+	st := newStats(time.Now())
+	lastStatsPrinted := time.Now()
+	lastMatched := time.Now()
+	matched := 0
+
+	for len(stopChan) == 0 {
+		measurements, err := subscriber.RecvMeasurement(zmqPollTimeout)
+		if err != nil {
+			log.Printf("RecvMeasurement() failed: %v", err)
+		}
+
+		now := time.Now()
+		for _, m := range measurements {
+			if !f.matches(*m) {
+				continue
+			}
+
+			matched += 1
+			lastMatched = now
+
+			if *statsInterval != 0 {
+				st.Add(*m, now)
+			} else if err := formatter.Format(*m); err != nil {
+				log.Printf("Unable to print the measurement: %v", err)
+				return
+			}
+
+			if (*count != 0) && (matched >= *count) {
+				break
+			}
+		}
+
+		if err := formatter.Flush(); err != nil {
+			log.Printf("Unable to print the measurements: %v", err)
+			return
+		}
+
+		if (*statsInterval != 0) && (now.Sub(lastStatsPrinted) >= *statsInterval) {
+			st.Print(os.Stdout, now)
+			lastStatsPrinted = now
+		}
+
+		if (*count != 0) && (matched >= *count) {
+			break
+		}
+
+		if (*timeout != 0) && (now.Sub(lastMatched) > *timeout) {
+			log.Printf("No matching measurement received within %v", *timeout)
+			ret = exitTimeout
+			return
+		}
+	}
+
+	if *statsInterval != 0 {
+		st.Print(os.Stdout, time.Now())
+	}
+
+	ret = exitOk
+}
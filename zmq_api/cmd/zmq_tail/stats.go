@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+)
+
+type deviceStats struct {
+	messages int
+	errors   int
+	lastSeen time.Time
+}
+
+type stats struct {
+	started time.Time
+	devices map[int]*deviceStats
+}
+
+func newStats(now time.Time) *stats {
+	return &stats{started: now, devices: make(map[int]*deviceStats)}
+}
+
+func (s *stats) Add(m zmq_api.Measurement, now time.Time) {
+	d, found := s.devices[m.DeviceId]
+	if !found {
+		d = &deviceStats{}
+		s.devices[m.DeviceId] = d
+	}
+
+	d.messages += 1
+	if m.Error != "" {
+		d.errors += 1
+	}
+	d.lastSeen = now
+}
+
+// Print writes a table with a line per device sorted by the device id
+func (s *stats) Print(w io.Writer, now time.Time) error {
+	ids := make([]int, 0, len(s.devices))
+	for id := range s.devices {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	elapsed := now.Sub(s.started).Minutes()
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "DEVICE\tMESSAGES\tERRORS\tRATE/MIN\tLAST SEEN\n")
+
+	for _, id := range ids {
+		d := s.devices[id]
+
+		rate := 0.0
+		if elapsed > 0 {
+			rate = float64(d.messages) / elapsed
+		}
+
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%.2f\t%s ago\n", id, d.messages, d.errors, rate,
+			now.Sub(d.lastSeen).Round(time.Second))
+	}
+
+	if _, err := fmt.Fprintln(tw); err != nil {
+		return err
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+)
+
+func TestStats(t *testing.T) {
+	start := time.Now()
+	st := newStats(start)
+
+	st.Add(zmq_api.Measurement{DeviceId: 2, Type: "Temperature"}, start.Add(10*time.Second))
+	st.Add(zmq_api.Measurement{DeviceId: 1, Type: "Temperature"}, start.Add(20*time.Second))
+	st.Add(zmq_api.Measurement{DeviceId: 1, Type: "Error", Error: "Low power"}, start.Add(30*time.Second))
+
+	var buf bytes.Buffer
+	if err := st.Print(&buf, start.Add(time.Minute)); err != nil {
+		t.Fatalf("Print() failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Unexpected output '%s'", buf.String())
+	}
+
+	expected := [][]string{
+		{"DEVICE", "MESSAGES", "ERRORS", "RATE/MIN", "LAST", "SEEN"},
+		{"1", "2", "1", "2.00", "30s", "ago"},
+		{"2", "1", "0", "1.00", "50s", "ago"},
+	}
+
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if strings.Join(fields, " ") != strings.Join(expected[i], " ") {
+			t.Fatalf("Line %d is '%s', expected '%v'", i, line, expected[i])
+		}
+	}
+}
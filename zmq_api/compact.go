@@ -0,0 +1,300 @@
+package zmq_api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Encoding is the wire format of the published measurements. Subscribers
+// detect it automatically, so publishers may use any of them.
+type Encoding int
+
+const (
+	// the same JSON as radio_receiver publishes
+	EncodingJSON Encoding = iota
+	// a binary format several times smaller than JSON
+	EncodingCompact
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingJSON:
+		return "json"
+	case EncodingCompact:
+		return "compact"
+	}
+
+	return fmt.Sprintf("unknown encoding %d", int(e))
+}
+
+// ParseEncoding is the inverse of Encoding.String()
+func ParseEncoding(s string) (Encoding, error) {
+	for _, e := range []Encoding{EncodingJSON, EncodingCompact} {
+		if s == e.String() {
+			return e, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown encoding '%s'", s)
+}
+
+// A compact message is:
+//
+//	version       1 byte, compactVersion
+//	flags         1 byte, see compactFlagError and others
+//	timestamp     signed varint, milliseconds (seconds in version 1)
+//	device id     signed varint
+//	type          1 byte, one of compactTypes or 0 followed by the type string
+//	value         float64, 8 bytes big endian, if it is not an error
+//	error         string, if it is an error
+//	unit          string, if compactFlagUnit is set
+//	seq           unsigned varint, if compactFlagSeq is set
+//	receiver id   string, if compactFlagReceiverId is set
+//	rssi          signed varint, if compactFlagRSSI is set
+//	link quality  1 byte, if compactFlagLinkQuality is set
+//
+// where a string is its length as an unsigned varint followed by the bytes.
+// Version 1 messages have none of the optional fields.
+const compactVersion = 2
+
+const (
+	compactFlagError = 1 << iota
+	compactFlagUnit
+	compactFlagSeq
+	compactFlagReceiverId
+	compactFlagRSSI
+	compactFlagLinkQuality
+)
+
+var compactTypes = []Kind{"", KindTemperature, KindHumidity, KindError}
+
+// JSON messages start with '{' or whitespace, so a first byte below
+// any of them is the version of the compact encoding
+func isCompact(data []byte) bool {
+	return (len(data) != 0) && (data[0] < '\t')
+}
+
+func encodeCompactMeasurement(m Measurement) ([]byte, error) {
+	buf := make([]byte, 0, 32)
+	varint := make([]byte, binary.MaxVarintLen64)
+
+	var flags byte
+	if m.Error != "" {
+		flags |= compactFlagError
+	}
+	if m.Unit != "" {
+		flags |= compactFlagUnit
+	}
+	if m.Seq != 0 {
+		flags |= compactFlagSeq
+	}
+	if m.ReceiverId != "" {
+		flags |= compactFlagReceiverId
+	}
+	if m.RSSI != 0 {
+		flags |= compactFlagRSSI
+	}
+	if m.LinkQuality != 0 {
+		flags |= compactFlagLinkQuality
+	}
+	buf = append(buf, compactVersion, flags)
+
+	n := binary.PutVarint(varint, int64(m.Timestamp)*1000+int64(m.Millis))
+	buf = append(buf, varint[:n]...)
+
+	n = binary.PutVarint(varint, int64(m.DeviceId))
+	buf = append(buf, varint[:n]...)
+
+	typeCode := 0
+	for i, name := range compactTypes {
+		if (i != 0) && (name == m.Type) {
+			typeCode = i
+			break
+		}
+	}
+
+	buf = append(buf, byte(typeCode))
+	if typeCode == 0 {
+		buf = appendCompactString(buf, string(m.Type))
+	}
+
+	if m.Error != "" {
+		buf = appendCompactString(buf, m.Error)
+	} else {
+		value := make([]byte, 8)
+		binary.BigEndian.PutUint64(value, math.Float64bits(m.Value))
+		buf = append(buf, value...)
+	}
+
+	if flags&compactFlagUnit != 0 {
+		buf = appendCompactString(buf, string(m.Unit))
+	}
+
+	if flags&compactFlagSeq != 0 {
+		n = binary.PutUvarint(varint, m.Seq)
+		buf = append(buf, varint[:n]...)
+	}
+
+	if flags&compactFlagReceiverId != 0 {
+		buf = appendCompactString(buf, m.ReceiverId)
+	}
+
+	if flags&compactFlagRSSI != 0 {
+		n = binary.PutVarint(varint, int64(m.RSSI))
+		buf = append(buf, varint[:n]...)
+	}
+
+	if flags&compactFlagLinkQuality != 0 {
+		if (m.LinkQuality < 0) || (m.LinkQuality > 255) {
+			return nil, fmt.Errorf("link quality %d does not fit in a byte", m.LinkQuality)
+		}
+		buf = append(buf, byte(m.LinkQuality))
+	}
+
+	return buf, nil
+}
+
+func appendCompactString(buf []byte, s string) []byte {
+	varint := make([]byte, binary.MaxVarintLen64)
+
+	n := binary.PutUvarint(varint, uint64(len(s)))
+	buf = append(buf, varint[:n]...)
+
+	return append(buf, s...)
+}
+
+func readCompactString(r *bytes.Reader) (string, error) {
+	l, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+
+	if l > uint64(r.Len()) {
+		return "", io.ErrUnexpectedEOF
+	}
+
+	s := make([]byte, l)
+	r.Read(s)
+
+	return string(s), nil
+}
+
+func decodeCompactMeasurement(data []byte) (*Measurement, error) {
+	if !isCompact(data) {
+		return nil, fmt.Errorf("not a compact message")
+	}
+
+	version := data[0]
+	if (version != 1) && (version != compactVersion) {
+		return nil, fmt.Errorf("unsupported compact encoding version %d", version)
+	}
+
+	r := bytes.NewReader(data[1:])
+	m := Measurement{}
+
+	flags, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("truncated message")
+	}
+
+	// version 1 has no optional fields
+	knownFlags := byte(compactFlagLinkQuality<<1 - 1)
+	if version == 1 {
+		knownFlags = compactFlagError
+	}
+
+	if flags&^knownFlags != 0 {
+		return nil, fmt.Errorf("unknown flags %#x", flags)
+	}
+
+	timestamp, err := binary.ReadVarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp: %v", err)
+	}
+	if version == 1 {
+		m.Timestamp = int(timestamp)
+	} else {
+		m.Timestamp, m.Millis = splitMillis(timestamp)
+	}
+
+	deviceId, err := binary.ReadVarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid device id: %v", err)
+	}
+	m.DeviceId = int(deviceId)
+
+	typeCode, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("truncated message")
+	}
+
+	switch {
+	case typeCode == 0:
+		name, err := readCompactString(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid type: %v", err)
+		}
+		m.Type = ParseKind(name)
+	case int(typeCode) < len(compactTypes):
+		m.Type = compactTypes[typeCode]
+	default:
+		return nil, fmt.Errorf("unknown type code %d", typeCode)
+	}
+
+	if flags&compactFlagError != 0 {
+		if m.Error, err = readCompactString(r); err != nil {
+			return nil, fmt.Errorf("invalid error: %v", err)
+		}
+	} else {
+		value := make([]byte, 8)
+		if _, err := io.ReadFull(r, value); err != nil {
+			return nil, fmt.Errorf("invalid value: %v", err)
+		}
+		m.Value = math.Float64frombits(binary.BigEndian.Uint64(value))
+	}
+
+	if flags&compactFlagUnit != 0 {
+		unit, err := readCompactString(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unit: %v", err)
+		}
+		m.Unit = Unit(unit)
+	}
+
+	if flags&compactFlagSeq != 0 {
+		if m.Seq, err = binary.ReadUvarint(r); err != nil {
+			return nil, fmt.Errorf("invalid seq: %v", err)
+		}
+	}
+
+	if flags&compactFlagReceiverId != 0 {
+		if m.ReceiverId, err = readCompactString(r); err != nil {
+			return nil, fmt.Errorf("invalid receiver id: %v", err)
+		}
+	}
+
+	if flags&compactFlagRSSI != 0 {
+		rssi, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rssi: %v", err)
+		}
+		m.RSSI = int(rssi)
+	}
+
+	if flags&compactFlagLinkQuality != 0 {
+		linkQuality, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("truncated message")
+		}
+		m.LinkQuality = int(linkQuality)
+	}
+
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes", r.Len())
+	}
+
+	return &m, nil
+}
@@ -0,0 +1,96 @@
+package zmq_api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCompactMeasurement(t *testing.T) {
+	measurements := []Measurement{
+		{DeviceId: 1, Type: "Temperature", Value: -12.25, Timestamp: 1600000000},
+		{DeviceId: 255, Type: "Humidity", Value: 45.5, Timestamp: 0},
+		{DeviceId: 2, Type: "Error", Timestamp: 11, Error: "Low power"},
+		{DeviceId: -1, Type: "Pressure", Value: 1013.25, Timestamp: -5, Millis: 250},
+		{DeviceId: 3, Type: "Humidity", Value: 45.5, Timestamp: 12, Millis: 345, Unit: "%",
+			Seq: 7, ReceiverId: "pi", RSSI: -60, LinkQuality: 90},
+	}
+
+	for _, m := range measurements {
+		data, err := EncodeMeasurement(m, EncodingCompact)
+		if err != nil {
+			t.Fatalf("EncodeMeasurement() failed: %v", err)
+		}
+
+		jsonData, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("Marshal() failed: %v", err)
+		}
+
+		if len(data) >= len(jsonData)/2 {
+			t.Fatalf("Compact message of %d bytes, the JSON one is %d bytes", len(data), len(jsonData))
+		}
+
+		decoded, err := decodeMeasurement(data)
+		if err != nil {
+			t.Fatalf("decodeMeasurement(%x) failed: %v", data, err)
+		}
+
+		if *decoded != m {
+			t.Fatalf("Decoded '%#v', expected '%#v'", *decoded, m)
+		}
+
+		// every truncation is detected
+		for i := 1; i < len(data); i++ {
+			if _, err := decodeMeasurement(data[:i]); err == nil {
+				t.Fatalf("decodeMeasurement() did not fail for %x", data[:i])
+			}
+		}
+	}
+}
+
+func TestCompactMeasurementInvalid(t *testing.T) {
+	checks := []struct {
+		data   []byte
+		reason string
+	}{
+		{[]byte{3, 0}, "unsupported compact encoding version 3"},
+		{[]byte{2, 0x80}, "unknown flags 0x80"},
+		{[]byte{1, 0x02}, "unknown flags 0x2"},
+		{[]byte{2, 0, 2, 2, 9}, "unknown type code 9"},
+		{[]byte{2, 1, 2, 2, 3, 1, 'x', 0}, "1 trailing bytes"},
+		{[]byte{2, 0x21, 2, 2, 3, 1, 'x'}, "truncated message"},
+	}
+
+	for _, check := range checks {
+		_, err := decodeMeasurement(check.data)
+		if (err == nil) || (!strings.Contains(err.Error(), check.reason)) {
+			t.Fatalf("Unexpected error for %x: %v", check.data, err)
+		}
+	}
+
+	// version 1, the timestamp is in seconds
+	m, err := decodeMeasurement([]byte{1, 1, 10, 2, 3, 1, 'x'})
+	expected := Measurement{DeviceId: 1, Type: "Error", Timestamp: 5, Error: "x"}
+	if (err != nil) || (*m != expected) {
+		t.Fatalf("decodeMeasurement() returned '%v', %v", m, err)
+	}
+
+	// JSON is still detected, even with leading whitespace
+	m, err = decodeMeasurement([]byte("\n {\"device_id\": 3, \"type\": \"Humidity\"}"))
+	if (err != nil) || (m.DeviceId != 3) {
+		t.Fatalf("decodeMeasurement() returned '%v', %v", m, err)
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	for _, e := range []Encoding{EncodingJSON, EncodingCompact} {
+		if parsed, err := ParseEncoding(e.String()); (err != nil) || (parsed != e) {
+			t.Fatalf("ParseEncoding('%v') returned %v (%v)", e, parsed, err)
+		}
+	}
+
+	if _, err := ParseEncoding("cbor"); err == nil {
+		t.Fatalf("ParseEncoding() did not fail for an unknown encoding")
+	}
+}
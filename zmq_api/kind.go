@@ -0,0 +1,66 @@
+package zmq_api
+
+import (
+	"strings"
+)
+
+// Kind is the type of a measurement. Kinds unknown to this package
+// are passed through as they are.
+type Kind string
+
+const (
+	KindTemperature Kind = "Temperature"
+	KindHumidity    Kind = "Humidity"
+	// the node failed, see Measurement.Error
+	KindError Kind = "Error"
+)
+
+var knownKinds = []Kind{KindTemperature, KindHumidity, KindError}
+
+// Unit is the unit of a measurement value
+type Unit string
+
+const (
+	UnitCelsius Unit = "C"
+	UnitPercent Unit = "%"
+)
+
+// ParseKind matches the known kinds case insensitively,
+// other kinds are returned as is
+func ParseKind(s string) Kind {
+	for _, k := range knownKinds {
+		if strings.EqualFold(s, string(k)) {
+			return k
+		}
+	}
+
+	return Kind(s)
+}
+
+func (k Kind) Known() bool {
+	for _, known := range knownKinds {
+		if k == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Name is the lower case kind used in topics and filters
+func (k Kind) Name() string {
+	return strings.ToLower(string(k))
+}
+
+// DefaultUnit returns the unit the nodes measure the kind in,
+// empty for errors and unknown kinds
+func (k Kind) DefaultUnit() Unit {
+	switch k {
+	case KindTemperature:
+		return UnitCelsius
+	case KindHumidity:
+		return UnitPercent
+	}
+
+	return ""
+}
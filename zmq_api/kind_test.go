@@ -0,0 +1,42 @@
+package zmq_api
+
+import (
+	"testing"
+)
+
+func TestKind(t *testing.T) {
+	checks := []struct {
+		name  string
+		kind  Kind
+		known bool
+		unit  Unit
+	}{
+		{"Temperature", KindTemperature, true, UnitCelsius},
+		{"humidity", KindHumidity, true, UnitPercent},
+		{"ERROR", KindError, true, ""},
+		{"Pressure", Kind("Pressure"), false, ""},
+	}
+
+	for _, check := range checks {
+		k := ParseKind(check.name)
+		if (k != check.kind) || (k.Known() != check.known) || (k.DefaultUnit() != check.unit) {
+			t.Fatalf("ParseKind('%s') returned '%s', known %v, unit '%s'",
+				check.name, k, k.Known(), k.DefaultUnit())
+		}
+	}
+
+	if name := Kind("Pressure").Name(); name != "pressure" {
+		t.Fatalf("Unexpected name '%s'", name)
+	}
+
+	// the kinds are normalized, unknown ones are passed through
+	m, err := decodeMeasurement([]byte(`{"device_id": 1, "type": "temperature", "value": 20}`))
+	if (err != nil) || (m.Type != KindTemperature) || (m.ValueUnit() != UnitCelsius) {
+		t.Fatalf("decodeMeasurement() returned '%v', %v", m, err)
+	}
+
+	m, err = decodeMeasurement([]byte(`{"device_id": 1, "type": "Pressure", "value": 1000, "unit": "hPa"}`))
+	if (err != nil) || (m.Type != "Pressure") || (m.ValueUnit() != "hPa") {
+		t.Fatalf("decodeMeasurement() returned '%v', %v", m, err)
+	}
+}
@@ -1,8 +1,223 @@
 package zmq_api
 
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 type Measurement struct {
-	DeviceId  int
-	Type      string
-	Value     float64
+	DeviceId int
+	Type     Kind
+	Value    float64
+
+	// Unix time in seconds, as set by the receiver, see Time()
 	Timestamp int
+	// milliseconds past Timestamp, 0-999, only version 2 messages
+	// carry them
+	Millis int
+
+	// set only for measurements of type "Error"
+	Error string
+
+	// the unit of Value, empty if the message does not specify it,
+	// see ValueUnit()
+	Unit Unit
+
+	// the per-publisher sequence number starting from 1,
+	// 0 if the publisher does not number the messages
+	Seq uint64
+
+	// the id of the receiver the measurement came through, and the
+	// quality of the radio link, all zero values mean 'unknown'
+	ReceiverId  string
+	RSSI        int
+	LinkQuality int
+
+	// the name of the source the measurement was received from,
+	// see Source. It is set by the Subscriber and not encoded.
+	Source string
+}
+
+// Time returns the zero time if the timestamp is not set
+func (m Measurement) Time() time.Time {
+	if (m.Timestamp == 0) && (m.Millis == 0) {
+		return time.Time{}
+	}
+
+	return time.Unix(int64(m.Timestamp), int64(m.Millis)*int64(time.Millisecond))
+}
+
+// SetTime sets the timestamp truncating t to milliseconds
+func (m *Measurement) SetTime(t time.Time) {
+	m.Timestamp, m.Millis = splitMillis(t.UnixNano() / int64(time.Millisecond))
+}
+
+// ValueUnit returns Unit, or the default unit of the kind
+// if the message does not specify it
+func (m Measurement) ValueUnit() Unit {
+	if m.Unit != "" {
+		return m.Unit
+	}
+
+	return m.Type.DefaultUnit()
+}
+
+// SchemaVersion is the version of the JSON messages produced by
+// the publishers, see measurement.schema.json.
+//
+// Version 1 messages are the ones radio_receiver has been publishing
+// from the start: they have no 'version' field and the 'timestamp' is
+// in seconds. Version 2 adds 'version', the timestamp in milliseconds
+// and the optional link and sequence fields. 'timestamp' is still set
+// for version 1 consumers.
+const SchemaVersion = 2
+
+// jsonMeasurement is the JSON message of any version, the
+// description and schema tags are used by MeasurementSchema()
+type jsonMeasurement struct {
+	Version     int      `json:"version" description:"Version of the message schema" schema:"const=2"`
+	TimestampMs *int64   `json:"timestamp_ms" description:"Unix time in milliseconds"`
+	Timestamp   *int64   `json:"timestamp,omitempty" description:"Unix time in seconds, deprecated, only for version 1 consumers"`
+	DeviceId    int      `json:"device_id" description:"Id of the node which sent the measurement"`
+	Type        string   `json:"type" description:"Type of the measurement, e.g. Temperature, Humidity or Error"`
+	Value       *float64 `json:"value,omitempty" description:"Measured value, absent for errors"`
+	Unit        string   `json:"unit,omitempty" description:"Unit of the value, e.g. C or %"`
+	Error       string   `json:"error,omitempty" description:"Error description, only for errors"`
+	Seq         uint64   `json:"seq,omitempty" description:"Per-publisher sequence number starting from 1" schema:"minimum=1"`
+	ReceiverId  string   `json:"receiver_id,omitempty" description:"Id of the receiver the measurement came through"`
+	RSSI        int      `json:"rssi,omitempty" description:"Received signal strength, dBm"`
+	LinkQuality int      `json:"link_quality,omitempty" description:"Quality of the radio link, percent" schema:"minimum=1,maximum=100"`
+}
+
+// splitMillis converts Unix milliseconds to seconds and milliseconds
+// past them, rounding down for times before 1970
+func splitMillis(ms int64) (int, int) {
+	seconds := ms / 1000
+	millis := ms % 1000
+	if millis < 0 {
+		seconds -= 1
+		millis += 1000
+	}
+
+	return int(seconds), int(millis)
+}
+
+// decodeMeasurement accepts any of the encodings and schema versions
+func decodeMeasurement(data []byte) (*Measurement, error) {
+	if isCompact(data) {
+		return decodeCompactMeasurement(data)
+	}
+
+	var m Measurement
+
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// UnmarshalJSON accepts messages of any schema version
+func (m *Measurement) UnmarshalJSON(data []byte) error {
+	var recvM jsonMeasurement
+
+	if err := json.Unmarshal(data, &recvM); err != nil {
+		return err
+	}
+
+	decoded := Measurement{DeviceId: recvM.DeviceId,
+		Type:        ParseKind(recvM.Type),
+		Error:       recvM.Error,
+		Unit:        Unit(recvM.Unit),
+		Seq:         recvM.Seq,
+		ReceiverId:  recvM.ReceiverId,
+		RSSI:        recvM.RSSI,
+		LinkQuality: recvM.LinkQuality}
+
+	if recvM.Value != nil {
+		decoded.Value = *recvM.Value
+	}
+
+	switch recvM.Version {
+	case 0, 1:
+		if recvM.Timestamp != nil {
+			decoded.Timestamp = int(*recvM.Timestamp)
+		}
+	case 2:
+		if recvM.TimestampMs == nil {
+			return fmt.Errorf("no timestamp_ms in a version 2 message")
+		}
+		decoded.Timestamp, decoded.Millis = splitMillis(*recvM.TimestampMs)
+	default:
+		return fmt.Errorf("unsupported schema version %d", recvM.Version)
+	}
+
+	*m = decoded
+
+	return nil
+}
+
+// MarshalJSON produces a SchemaVersion message: 'value'
+// is set for measurements, 'error' for errors
+func (m Measurement) MarshalJSON() ([]byte, error) {
+	timestamp := int64(m.Timestamp)
+	timestampMs := timestamp*1000 + int64(m.Millis)
+
+	toSend := jsonMeasurement{Version: SchemaVersion,
+		TimestampMs: &timestampMs,
+		Timestamp:   &timestamp,
+		DeviceId:    m.DeviceId,
+		Type:        string(m.Type),
+		Unit:        string(m.Unit),
+		Error:       m.Error,
+		Seq:         m.Seq,
+		ReceiverId:  m.ReceiverId,
+		RSSI:        m.RSSI,
+		LinkQuality: m.LinkQuality}
+
+	if m.Error == "" {
+		toSend.Value = &m.Value
+	}
+
+	return json.Marshal(toSend)
+}
+
+// EncodeMeasurement encodes the measurement as the Publisher does
+func EncodeMeasurement(m Measurement, encoding Encoding) ([]byte, error) {
+	switch encoding {
+	case EncodingJSON:
+		return json.Marshal(m)
+	case EncodingCompact:
+		return encodeCompactMeasurement(m)
+	}
+
+	return nil, fmt.Errorf("unknown encoding %d", int(encoding))
+}
+
+// DecodeError describes a received message which could not be
+// turned into a Measurement.
+type DecodeError struct {
+	Payload []byte
+	Reason  string
+
+	// the name of the source the message was received from
+	Source string
+}
+
+// the payload is quoted and truncated, it may be large or binary
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("unable to decode %.64q: %s", e.Payload, e.Reason)
+}
+
+// DecodeErrors is returned by the Subscriber receive methods along
+// with the successfully decoded measurements.
+type DecodeErrors []*DecodeError
+
+func (e DecodeErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+
+	return fmt.Sprintf("%d messages could not be decoded, the first one: %v", len(e), e[0])
 }
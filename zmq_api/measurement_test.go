@@ -0,0 +1,140 @@
+package zmq_api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeMeasurement(t *testing.T) {
+	m, err := decodeMeasurement([]byte(`{"timestamp": 12, "device_id": 3, "type": "Error", "error": "Low power"}`))
+	if err != nil {
+		t.Fatalf("decodeMeasurement() failed: %v", err)
+	}
+
+	expected := Measurement{DeviceId: 3, Type: "Error", Timestamp: 12, Error: "Low power"}
+	if *m != expected {
+		t.Fatalf("Got '%#v', expected '%#v'", *m, expected)
+	}
+
+	if _, err = decodeMeasurement([]byte("not a json")); err == nil {
+		t.Fatalf("decodeMeasurement() did not fail for an invalid message")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	errs := DecodeErrors{&DecodeError{Payload: []byte("abc"), Reason: "some reason"}}
+	if msg := errs.Error(); msg != `unable to decode "abc": some reason` {
+		t.Fatalf("Unexpected message '%s'", msg)
+	}
+
+	long := DecodeError{Payload: []byte(strings.Repeat("x", 1000) + "\x00"), Reason: "some reason"}
+	if msg := long.Error(); msg != `unable to decode "`+strings.Repeat("x", 64)+`": some reason` {
+		t.Fatalf("Unexpected message '%s'", msg)
+	}
+
+	errs = append(errs, &DecodeError{Payload: []byte("def"), Reason: "other reason"})
+	if msg := errs.Error(); !strings.HasPrefix(msg, "2 messages could not be decoded") {
+		t.Fatalf("Unexpected message '%s'", msg)
+	}
+}
+
+func TestEncodeMeasurement(t *testing.T) {
+	measurements := []Measurement{
+		{DeviceId: 1, Type: "Temperature", Value: 0, Timestamp: 10},
+		{DeviceId: 2, Type: "Error", Timestamp: 11, Error: "Low power"},
+		{DeviceId: 3, Type: "Humidity", Value: 45.5, Timestamp: 12, Millis: 345, Unit: "%",
+			Seq: 7, ReceiverId: "pi", RSSI: -60, LinkQuality: 90},
+	}
+	expected := []string{
+		`{"version":2,"timestamp_ms":10000,"timestamp":10,"device_id":1,"type":"Temperature","value":0}`,
+		`{"version":2,"timestamp_ms":11000,"timestamp":11,"device_id":2,"type":"Error","error":"Low power"}`,
+		`{"version":2,"timestamp_ms":12345,"timestamp":12,"device_id":3,"type":"Humidity","value":45.5,"unit":"%",` +
+			`"seq":7,"receiver_id":"pi","rssi":-60,"link_quality":90}`,
+	}
+
+	for i, m := range measurements {
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("Marshal() failed: %v", err)
+		}
+
+		if string(data) != expected[i] {
+			t.Fatalf("Got '%s', expected '%s'", data, expected[i])
+		}
+
+		decoded, err := decodeMeasurement(data)
+		if err != nil {
+			t.Fatalf("decodeMeasurement('%s') failed: %v", data, err)
+		}
+
+		if *decoded != m {
+			t.Fatalf("Decoded '%#v', expected '%#v'", *decoded, m)
+		}
+	}
+}
+
+func TestDecodeMeasurementVersions(t *testing.T) {
+	checks := []struct {
+		data     string
+		expected Measurement
+	}{
+		// radio_receiver
+		{`{"timestamp": 1600000000, "device_id": 1, "type": "Temperature", "value": 21.5}`,
+			Measurement{DeviceId: 1, Type: "Temperature", Value: 21.5, Timestamp: 1600000000}},
+		{`{"version": 1, "timestamp": 5, "device_id": 1, "type": "Humidity", "value": 40}`,
+			Measurement{DeviceId: 1, Type: "Humidity", Value: 40, Timestamp: 5}},
+		// timestamp_ms wins
+		{`{"version": 2, "timestamp_ms": 1500, "timestamp": 9, "device_id": 1, "type": "Humidity", "value": 40}`,
+			Measurement{DeviceId: 1, Type: "Humidity", Value: 40, Timestamp: 1, Millis: 500}},
+		{`{"version": 2, "timestamp_ms": -1, "device_id": 1, "type": "Humidity", "value": 40}`,
+			Measurement{DeviceId: 1, Type: "Humidity", Value: 40, Timestamp: -1, Millis: 999}},
+	}
+
+	for _, check := range checks {
+		m, err := decodeMeasurement([]byte(check.data))
+		if err != nil {
+			t.Fatalf("decodeMeasurement('%s') failed: %v", check.data, err)
+		}
+
+		if *m != check.expected {
+			t.Fatalf("Decoded '%#v', expected '%#v'", *m, check.expected)
+		}
+	}
+
+	invalid := map[string]string{
+		`{"version": 2, "timestamp": 5, "device_id": 1, "type": "Humidity", "value": 40}`: "no timestamp_ms",
+		`{"version": 3, "timestamp_ms": 5, "device_id": 1, "type": "Humidity"}`:           "unsupported schema version 3",
+	}
+
+	for data, reason := range invalid {
+		_, err := decodeMeasurement([]byte(data))
+		if (err == nil) || (!strings.Contains(err.Error(), reason)) {
+			t.Fatalf("Unexpected error for '%s': %v", data, err)
+		}
+	}
+}
+
+func TestMeasurementTime(t *testing.T) {
+	var m Measurement
+	if !m.Time().IsZero() {
+		t.Fatalf("Time() of an unset timestamp is %v", m.Time())
+	}
+
+	// receivers send seconds
+	m = Measurement{Timestamp: 1600000000}
+	if expected := time.Unix(1600000000, 0); !m.Time().Equal(expected) {
+		t.Fatalf("Time() returned %v, expected %v", m.Time(), expected)
+	}
+
+	now := time.Unix(1600000000, 123456789)
+	m.SetTime(now)
+	if (m.Timestamp != 1600000000) || (m.Millis != 123) {
+		t.Fatalf("SetTime() set %d, %d", m.Timestamp, m.Millis)
+	}
+
+	if expected := now.Truncate(time.Millisecond); !m.Time().Equal(expected) {
+		t.Fatalf("Time() returned %v, expected %v", m.Time(), expected)
+	}
+}
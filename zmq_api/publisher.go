@@ -0,0 +1,244 @@
+package zmq_api
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api/zmq"
+)
+
+// Publisher publishes measurements in the same format as radio_receiver,
+// or in the compact one, see WithEncoding().
+type Publisher struct {
+	ctx        *zmq.Context
+	sock       *zmq.Socket
+	zapHandler *zmq.ZAPHandler
+
+	Endpoint      string
+	TopicEnvelope bool
+	Encoding      Encoding
+
+	// number the published measurements, see WithSequenceNumbers()
+	Sequenced bool
+	// stamped on the published measurements if not empty, see WithReceiverId()
+	ReceiverId string
+
+	// CURVE server secret key, empty if the connections are not encrypted
+	curveSecretKey string
+	// public keys of the allowed subscribers, empty means 'any'
+	curveAllowedKeys []string
+
+	// guards the socket and the sequence, so the measurements are
+	// sent in the order of their sequence numbers
+	mux     sync.Mutex
+	lastSeq uint64
+}
+
+type PublisherOption func(p *Publisher) error
+
+// WithTopicEnvelope makes the publisher prepend the topic frame,
+// see MeasurementTopic()
+func WithTopicEnvelope() PublisherOption {
+	return func(p *Publisher) error {
+		p.TopicEnvelope = true
+		return nil
+	}
+}
+
+// WithEncoding sets the encoding of the published measurements,
+// EncodingJSON is the default
+func WithEncoding(encoding Encoding) PublisherOption {
+	return func(p *Publisher) error {
+		if (encoding != EncodingJSON) && (encoding != EncodingCompact) {
+			return fmt.Errorf("unknown encoding %d", int(encoding))
+		}
+
+		p.Encoding = encoding
+		return nil
+	}
+}
+
+// WithSequenceNumbers makes the publisher number the measurements
+// starting from 1, so the subscribers detect lost ones, see
+// Subscriber.SequenceStats(). The sequence is per ReceiverId, unless
+// WithReceiverId() is used it is derived from the host name and the
+// endpoint, so the subscribers detect the restarts of the publisher.
+func WithSequenceNumbers() PublisherOption {
+	return func(p *Publisher) error {
+		p.Sequenced = true
+		return nil
+	}
+}
+
+// WithReceiverId sets the id stamped on the published measurements,
+// it must be unique among the publishers behind the same endpoint
+func WithReceiverId(id string) PublisherOption {
+	return func(p *Publisher) error {
+		if id == "" {
+			return fmt.Errorf("empty receiver id")
+		}
+
+		p.ReceiverId = id
+		return nil
+	}
+}
+
+// WithCurveServer encrypts the connections with CURVE. If allowedClientKeys
+// are given, only subscribers with these public keys may connect.
+// All keys are Z85 encoded, see zmq.NewCurveKeypair().
+func WithCurveServer(secretKey string, allowedClientKeys ...string) PublisherOption {
+	return func(p *Publisher) error {
+		if _, err := zmq.CurvePublicKey(secretKey); err != nil {
+			return fmt.Errorf("invalid secret key: %v", err)
+		}
+
+		p.curveSecretKey = secretKey
+		p.curveAllowedKeys = append(p.curveAllowedKeys, allowedClientKeys...)
+		return nil
+	}
+}
+
+func NewPublisher(endpoint string, opts ...PublisherOption) (*Publisher, error) {
+	var err error
+	p := Publisher{Endpoint: endpoint}
+
+	defer func() {
+		if err != nil {
+			p.cleanupResources()
+		}
+	}()
+
+	for _, opt := range opts {
+		if err = opt(&p); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.Sequenced && (p.ReceiverId == "") {
+		if p.ReceiverId, err = defaultReceiverId(p.Endpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	p.ctx, err = zmq.NewContext()
+	if err != nil {
+		err = fmt.Errorf("NewContext() failed: %v", err)
+		return nil, err
+	}
+
+	p.sock, err = zmq.NewSocket(p.ctx, zmq.SocketPUB)
+	if err != nil {
+		err = fmt.Errorf("NewSocket() failed: %v", err)
+		return nil, err
+	}
+
+	if p.curveSecretKey != "" {
+		if err = p.setupCurve(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = p.sock.Bind(p.Endpoint); err != nil {
+		err = fmt.Errorf("Bind('%s') failed: %v", p.Endpoint, err)
+		return nil, err
+	}
+
+	return &p, err
+}
+
+func (p *Publisher) setupCurve() error {
+	if len(p.curveAllowedKeys) != 0 {
+		var err error
+
+		p.zapHandler, err = zmq.NewZAPHandler(p.ctx, zmq.CurveAllowList(p.curveAllowedKeys...))
+		if err != nil {
+			return fmt.Errorf("NewZAPHandler() failed: %v", err)
+		}
+	}
+
+	if err := p.sock.SetCurveServer(true); err != nil {
+		return fmt.Errorf("SetCurveServer() failed: %v", err)
+	}
+
+	if err := p.sock.SetCurveSecretKey(p.curveSecretKey); err != nil {
+		return fmt.Errorf("SetCurveSecretKey() failed: %v", err)
+	}
+
+	return nil
+}
+
+func (p *Publisher) cleanupResources() error {
+	var err error
+
+	if p.sock != nil {
+		sockErr := p.sock.Close()
+		if (err == nil) && (sockErr != nil) {
+			err = fmt.Errorf("socket Close() failed: %v", sockErr)
+		}
+
+		p.sock = nil
+	}
+
+	if p.zapHandler != nil {
+		zapErr := p.zapHandler.Close()
+		if (err == nil) && (zapErr != nil) {
+			err = fmt.Errorf("ZAP handler Close() failed: %v", zapErr)
+		}
+
+		p.zapHandler = nil
+	}
+
+	if p.ctx != nil {
+		ctxErr := p.ctx.Terminate()
+		if (err == nil) && (ctxErr != nil) {
+			err = fmt.Errorf("ctx Terminate() failed: %v", ctxErr)
+		}
+
+		p.ctx = nil
+	}
+
+	return err
+}
+
+// defaultReceiverId is stable across the restarts of the publisher
+func defaultReceiverId(endpoint string) (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("unable to get the host name for the receiver id: %v", err)
+	}
+
+	return hostname + "/" + endpoint, nil
+}
+
+func (p *Publisher) Destroy() error {
+	return p.cleanupResources()
+}
+
+// PublishMeasurement overrides m.Seq if the publisher is Sequenced,
+// and m.ReceiverId if the publisher has one
+func (p *Publisher) PublishMeasurement(m Measurement) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.Sequenced {
+		p.lastSeq += 1
+		m.Seq = p.lastSeq
+	}
+
+	if p.ReceiverId != "" {
+		m.ReceiverId = p.ReceiverId
+	}
+
+	data, err := EncodeMeasurement(m, p.Encoding)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the data: %v", err)
+	}
+
+	if !p.TopicEnvelope {
+		return p.sock.Send(data)
+	}
+
+	topic := MeasurementTopic(m.DeviceId, m.Type)
+	return p.sock.SendMultipart([][]byte{[]byte(topic), data})
+}
@@ -0,0 +1,315 @@
+package zmq_api
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api/zmq"
+)
+
+// publishes the measurements every 100ms until the returned function is called
+func publishInBackground(t *testing.T, p *Publisher, measurements ...Measurement) func() {
+	var stopFlag int32
+	stopChan := make(chan struct{})
+
+	go func() {
+		defer close(stopChan)
+
+		for atomic.LoadInt32(&stopFlag) == 0 {
+			for _, m := range measurements {
+				if err := p.PublishMeasurement(m); err != nil {
+					t.Errorf("PublishMeasurement() failed: %v", err)
+				}
+			}
+
+			time.Sleep(100 * time.Millisecond)
+		}
+	}()
+
+	return func() {
+		atomic.StoreInt32(&stopFlag, 1)
+		<-stopChan
+	}
+}
+
+func TestPublisherTopicEnvelope(t *testing.T) {
+	const endpoint = "tcp://127.0.0.1:9005"
+
+	p, err := NewPublisher(endpoint, WithTopicEnvelope())
+	if err != nil {
+		t.Fatalf("NewPublisher() failed: %v", err)
+	}
+	defer p.Destroy()
+
+	m1 := Measurement{DeviceId: 1, Type: "Temperature", Value: 20.5, Timestamp: 1}
+	m2 := Measurement{DeviceId: 2, Type: "Error", Timestamp: 2, Error: "Low power", Source: endpoint}
+
+	stop := publishInBackground(t, p, m1, m2)
+	defer stop()
+
+	s, err := NewSubscriber(endpoint, WithTopicFilters(DeviceTopicFilter(2)))
+	if err != nil {
+		t.Fatalf("NewSubscriber() failed: %v", err)
+	}
+	defer s.Destroy()
+
+	received := 0
+	for i := 0; (i < 20) && (received < 3); i++ {
+		measurements, err := s.RecvMeasurement(time.Millisecond * 100)
+		if err != nil {
+			t.Fatalf("RecvMeasurement() failed: %v", err)
+		}
+
+		for _, m := range measurements {
+			if *m != m2 {
+				t.Fatalf("Got '%#v', expected '%#v'", *m, m2)
+			}
+
+			received += 1
+		}
+	}
+
+	if received == 0 {
+		t.Fatalf("No measurement received")
+	}
+}
+
+func TestPublisherWithoutEnvelope(t *testing.T) {
+	const endpoint = "tcp://127.0.0.1:9006"
+
+	p, err := NewPublisher(endpoint)
+	if err != nil {
+		t.Fatalf("NewPublisher() failed: %v", err)
+	}
+	defer p.Destroy()
+
+	m := Measurement{DeviceId: 1, Type: "Temperature", Value: 20.5, Timestamp: 1, Source: endpoint}
+
+	stop := publishInBackground(t, p, m)
+	defer stop()
+
+	s, err := NewSubscriber(endpoint)
+	if err != nil {
+		t.Fatalf("NewSubscriber() failed: %v", err)
+	}
+	defer s.Destroy()
+
+	var measurements []*Measurement
+	for i := 0; (i < 20) && (len(measurements) == 0); i++ {
+		measurements, err = s.RecvMeasurement(time.Millisecond * 100)
+		if err != nil {
+			t.Fatalf("RecvMeasurement() failed: %v", err)
+		}
+	}
+
+	if (len(measurements) == 0) || (*measurements[0] != m) {
+		t.Fatalf("Got '%v', expected '%#v'", measurements, m)
+	}
+}
+
+func TestPublisherCompactEncoding(t *testing.T) {
+	const endpoint = "tcp://127.0.0.1:9015"
+
+	if _, err := NewPublisher(endpoint, WithEncoding(Encoding(100))); err == nil {
+		t.Fatalf("NewPublisher() did not fail for an unknown encoding")
+	}
+
+	p, err := NewPublisher(endpoint, WithEncoding(EncodingCompact), WithTopicEnvelope())
+	if err != nil {
+		t.Fatalf("NewPublisher() failed: %v", err)
+	}
+	defer p.Destroy()
+
+	m := Measurement{DeviceId: 1, Type: "Humidity", Value: 40.5, Timestamp: 1, Source: endpoint}
+
+	stop := publishInBackground(t, p, m)
+	defer stop()
+
+	s, err := NewSubscriber(endpoint)
+	if err != nil {
+		t.Fatalf("NewSubscriber() failed: %v", err)
+	}
+	defer s.Destroy()
+
+	var measurements []*Measurement
+	for i := 0; (i < 20) && (len(measurements) == 0); i++ {
+		measurements, err = s.RecvMeasurement(time.Millisecond * 100)
+		if err != nil {
+			t.Fatalf("RecvMeasurement() failed: %v", err)
+		}
+	}
+
+	if (len(measurements) == 0) || (*measurements[0] != m) {
+		t.Fatalf("Got '%v', expected '%#v'", measurements, m)
+	}
+}
+
+func TestPublisherCurve(t *testing.T) {
+	const endpoint = "tcp://127.0.0.1:9008"
+
+	serverPublicKey, serverSecretKey, err := zmq.NewCurveKeypair()
+	if errors.Is(err, zmq.ErrNotSupported) {
+		t.Skip("CURVE is not supported")
+	}
+
+	if err != nil {
+		t.Fatalf("NewCurveKeypair() failed: %v", err)
+	}
+
+	clientPublicKey, clientSecretKey, err := zmq.NewCurveKeypair()
+	if err != nil {
+		t.Fatalf("NewCurveKeypair() failed: %v", err)
+	}
+
+	otherPublicKey, otherSecretKey, err := zmq.NewCurveKeypair()
+	if err != nil {
+		t.Fatalf("NewCurveKeypair() failed: %v", err)
+	}
+
+	p, err := NewPublisher(endpoint, WithCurveServer(serverSecretKey, clientPublicKey))
+	if err != nil {
+		t.Fatalf("NewPublisher() failed: %v", err)
+	}
+	defer p.Destroy()
+
+	m := Measurement{DeviceId: 1, Type: "Temperature", Value: 20.5, Timestamp: 1, Source: endpoint}
+
+	stop := publishInBackground(t, p, m)
+	defer stop()
+
+	// also returns if the subscriber considers itself connected
+	recv := func(publicKey, secretKey string) ([]*Measurement, bool) {
+		s, err := NewSubscriber(endpoint, WithCurve(serverPublicKey, publicKey, secretKey))
+		if err != nil {
+			t.Fatalf("NewSubscriber() failed: %v", err)
+		}
+		defer s.Destroy()
+
+		var measurements []*Measurement
+		for i := 0; (i < 10) && (len(measurements) == 0); i++ {
+			measurements, err = s.RecvMeasurement(time.Millisecond * 100)
+			if err != nil {
+				t.Fatalf("RecvMeasurement() failed: %v", err)
+			}
+		}
+
+		return measurements, s.ConnectionState().Connected
+	}
+
+	measurements, connected := recv(clientPublicKey, clientSecretKey)
+	if (len(measurements) == 0) || (*measurements[0] != m) {
+		t.Fatalf("Got '%v', expected '%#v'", measurements, m)
+	}
+
+	if !connected {
+		t.Fatalf("An allowed subscriber is not connected")
+	}
+
+	measurements, connected = recv(otherPublicKey, otherSecretKey)
+	if len(measurements) != 0 {
+		t.Fatalf("A not allowed subscriber received '%v'", measurements)
+	}
+
+	if connected {
+		t.Fatalf("A not allowed subscriber is connected")
+	}
+
+	if _, err := NewPublisher(endpoint, WithCurveServer("invalid")); err == nil {
+		t.Fatalf("NewPublisher() did not fail for an invalid key")
+	}
+}
+
+func TestPublisherSequenceNumbers(t *testing.T) {
+	const endpoint = "tcp://127.0.0.1:9016"
+
+	gaps := make(chan Gap, 100)
+	s, err := NewSubscriber(endpoint, WithGapHandler(func(g Gap) {
+		select {
+		case gaps <- g:
+		default:
+		}
+	}))
+	if err != nil {
+		t.Fatalf("NewSubscriber() failed: %v", err)
+	}
+	defer s.Destroy()
+
+	m := Measurement{DeviceId: 1, Type: "Humidity", Value: 40.5, Timestamp: 1}
+
+	// the second publisher is the first one restarted
+	for i := 0; i < 2; i++ {
+		p, err := NewPublisher(endpoint, WithSequenceNumbers(), WithReceiverId("test"))
+		if err != nil {
+			t.Fatalf("NewPublisher() failed: %v", err)
+		}
+
+		stop := publishInBackground(t, p, m)
+
+		var received []*Measurement
+		for j := 0; (j < 50) && (len(received) < 2); j++ {
+			measurements, err := s.RecvMeasurement(time.Millisecond * 100)
+			if err != nil {
+				t.Fatalf("RecvMeasurement() failed: %v", err)
+			}
+
+			received = append(received, measurements...)
+		}
+
+		stop()
+		p.Destroy()
+
+		if (len(received) < 2) || (received[0].Seq == 0) || (received[1].Seq != received[0].Seq+1) ||
+			(received[0].ReceiverId != "test") {
+			t.Fatalf("Unexpected measurements %v", received)
+		}
+	}
+
+	stats := s.SequenceStats()
+	if (len(stats) != 1) || (stats[0].Source != endpoint) || (stats[0].Restarts != 1) {
+		t.Fatalf("Unexpected stats %#v", stats)
+	}
+
+	select {
+	case g := <-gaps:
+		if !g.Restart {
+			t.Fatalf("Unexpected gap %#v", g)
+		}
+	default:
+		t.Fatalf("No restart reported")
+	}
+}
+
+func TestPublisherDefaultReceiverId(t *testing.T) {
+	const endpoint = "tcp://127.0.0.1:9017"
+
+	ids := make(map[string]bool)
+
+	// the second publisher is the first one restarted
+	for i := 0; i < 2; i++ {
+		p, err := NewPublisher(endpoint, WithSequenceNumbers())
+		if err != nil {
+			t.Fatalf("NewPublisher() failed: %v", err)
+		}
+
+		ids[p.ReceiverId] = true
+		p.Destroy()
+	}
+
+	// a restarted publisher must continue its sequence
+	if len(ids) != 1 {
+		t.Fatalf("Unexpected receiver ids %v", ids)
+	}
+
+	for id := range ids {
+		if !strings.HasSuffix(id, "/"+endpoint) {
+			t.Fatalf("Unexpected receiver id '%s'", id)
+		}
+	}
+
+	if _, err := NewPublisher(endpoint, WithReceiverId("")); err == nil {
+		t.Fatalf("NewPublisher() accepted an empty receiver id")
+	}
+}
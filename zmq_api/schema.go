@@ -0,0 +1,131 @@
+package zmq_api
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:generate go run ./cmd/zmq_schema -o measurement.schema.json
+
+// jsonSchemaDraft is the latest draft supported by the validators
+// of the C++ and Python sides
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// MeasurementSchema returns the JSON Schema of the SchemaVersion
+// messages. It is generated from the Go types, so it is always in
+// sync with MarshalJSON().
+func MeasurementSchema() ([]byte, error) {
+	properties, required, err := schemaProperties(reflect.TypeOf(jsonMeasurement{}))
+	if err != nil {
+		return nil, err
+	}
+
+	schema := map[string]interface{}{
+		"$schema":     jsonSchemaDraft,
+		"title":       "Measurement",
+		"description": fmt.Sprintf("A measurement message, schema version %d", SchemaVersion),
+		"type":        "object",
+		"properties":  properties,
+		"required":    required,
+		// errors have no value
+		"oneOf": []interface{}{
+			map[string]interface{}{"required": []string{"value"}},
+			map[string]interface{}{"required": []string{"error"}},
+		},
+	}
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+// schemaProperties describes the fields of a struct, the required ones
+// are the fields without 'omitempty'
+func schemaProperties(tp reflect.Type) (map[string]interface{}, []string, error) {
+	properties := make(map[string]interface{})
+	required := []string{}
+
+	for i := 0; i < tp.NumField(); i++ {
+		field := tp.Field(i)
+
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		name := tag[0]
+		if (name == "") || (name == "-") {
+			continue
+		}
+
+		property, err := schemaProperty(field)
+		if err != nil {
+			return nil, nil, fmt.Errorf("field %s: %v", field.Name, err)
+		}
+		properties[name] = property
+
+		omitEmpty := false
+		for _, opt := range tag[1:] {
+			if opt == "omitempty" {
+				omitEmpty = true
+			}
+		}
+
+		if !omitEmpty {
+			required = append(required, name)
+		}
+	}
+
+	sort.Strings(required)
+
+	return properties, required, nil
+}
+
+func schemaProperty(field reflect.StructField) (map[string]interface{}, error) {
+	property := make(map[string]interface{})
+
+	tp := field.Type
+	if tp.Kind() == reflect.Ptr {
+		tp = tp.Elem()
+	}
+
+	switch tp.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		property["type"] = "integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		property["type"] = "integer"
+		property["minimum"] = 0
+	case reflect.Float32, reflect.Float64:
+		property["type"] = "number"
+	case reflect.String:
+		property["type"] = "string"
+	default:
+		return nil, fmt.Errorf("unsupported type %v", tp)
+	}
+
+	if description := field.Tag.Get("description"); description != "" {
+		property["description"] = description
+	}
+
+	// comma separated key=value pairs, the values are numbers
+	if constraints := field.Tag.Get("schema"); constraints != "" {
+		for _, constraint := range strings.Split(constraints, ",") {
+			kv := strings.SplitN(constraint, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid schema tag '%s'", constraint)
+			}
+
+			value, err := strconv.ParseFloat(kv[1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid schema tag '%s': %v", constraint, err)
+			}
+
+			property[kv[0]] = value
+		}
+	}
+
+	return property, nil
+}
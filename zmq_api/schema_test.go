@@ -0,0 +1,65 @@
+package zmq_api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+)
+
+func TestMeasurementSchema(t *testing.T) {
+	schema, err := MeasurementSchema()
+	if err != nil {
+		t.Fatalf("MeasurementSchema() failed: %v", err)
+	}
+
+	data, err := ioutil.ReadFile("measurement.schema.json")
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+
+	if !bytes.Equal(schema, data) {
+		t.Fatalf("measurement.schema.json is out of date, run 'go generate'")
+	}
+
+	var parsed struct {
+		Properties map[string]struct {
+			Type string `json:"type"`
+		} `json:"properties"`
+		Required []string `json:"required"`
+	}
+
+	if err := json.Unmarshal(schema, &parsed); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+
+	// every encoded field is described
+	encoded, err := json.Marshal(Measurement{DeviceId: 1, Type: "Humidity", Timestamp: 1,
+		Error: "x", Unit: "%", Seq: 1, ReceiverId: "pi", RSSI: -1, LinkQuality: 1, Source: "a"})
+	if err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	fields["value"] = 0
+
+	for name := range fields {
+		if parsed.Properties[name].Type == "" {
+			t.Fatalf("No '%s' in the schema", name)
+		}
+	}
+
+	// the source is set by the subscriber, it is not sent
+	if _, found := fields["source"]; found {
+		t.Fatalf("The source is encoded")
+	}
+
+	for _, name := range parsed.Required {
+		if _, found := fields[name]; !found {
+			t.Fatalf("Required '%s' is not encoded", name)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package zmq_api
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// SequenceStats describes the sequence numbers received from one
+// publisher, which is identified by the source and the receiver id
+// of its measurements
+type SequenceStats struct {
+	Source     string
+	ReceiverId string
+
+	// the number of received measurements with sequence numbers
+	Received uint64
+	// the number of measurements missing between the received ones,
+	// e.g. dropped at the high-water mark or during a reconnect
+	Lost uint64
+	// how many times the publisher started numbering from the
+	// beginning, i.e. was restarted
+	Restarts uint64
+
+	LastSeq uint64
+}
+
+// Gap is reported by the subscriber when a measurement does not
+// follow the previous one from the same publisher
+type Gap struct {
+	Source     string
+	ReceiverId string
+
+	// the number of measurements lost before this one
+	Lost uint64
+	// the publisher was restarted, the measurements since its start
+	// are counted as lost
+	Restart bool
+
+	Seq     uint64
+	LastSeq uint64
+}
+
+// a publisher which sent nothing for this long is forgotten, e.g. it
+// was replaced by one with another receiver id
+const sequenceExpireAfter = 24 * time.Hour
+
+type sequenceKey struct {
+	source     string
+	receiverId string
+}
+
+type sequencePublisher struct {
+	stats    SequenceStats
+	lastSeen time.Time
+}
+
+// sequenceTracker is safe for concurrent use
+type sequenceTracker struct {
+	// replaced in tests
+	now func() time.Time
+
+	mux        sync.Mutex
+	publishers map[sequenceKey]*sequencePublisher
+}
+
+func newSequenceTracker() *sequenceTracker {
+	return &sequenceTracker{now: time.Now,
+		publishers: make(map[sequenceKey]*sequencePublisher)}
+}
+
+// expire must be called with mux held
+func (t *sequenceTracker) expire(now time.Time) {
+	for key, p := range t.publishers {
+		if now.Sub(p.lastSeen) > sequenceExpireAfter {
+			delete(t.publishers, key)
+		}
+	}
+}
+
+// track returns a Gap if some measurements were lost before m,
+// measurements without sequence numbers are ignored
+func (t *sequenceTracker) track(m *Measurement) *Gap {
+	if m.Seq == 0 {
+		return nil
+	}
+
+	now := t.now()
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	t.expire(now)
+
+	key := sequenceKey{source: m.Source, receiverId: m.ReceiverId}
+
+	p, found := t.publishers[key]
+	if !found {
+		// the earlier measurements were sent before we subscribed
+		t.publishers[key] = &sequencePublisher{stats: SequenceStats{Source: m.Source,
+			ReceiverId: m.ReceiverId,
+			Received:   1,
+			LastSeq:    m.Seq},
+			lastSeen: now}
+		return nil
+	}
+
+	p.lastSeen = now
+	stats := &p.stats
+
+	gap := Gap{Source: m.Source, ReceiverId: m.ReceiverId, Seq: m.Seq, LastSeq: stats.LastSeq}
+
+	switch {
+	case m.Seq <= stats.LastSeq:
+		gap.Restart = true
+		gap.Lost = m.Seq - 1
+		stats.Restarts += 1
+	case m.Seq > stats.LastSeq+1:
+		gap.Lost = m.Seq - stats.LastSeq - 1
+	}
+
+	stats.Received += 1
+	stats.Lost += gap.Lost
+	stats.LastSeq = m.Seq
+
+	if (!gap.Restart) && (gap.Lost == 0) {
+		return nil
+	}
+
+	return &gap
+}
+
+// stats returns the statistics sorted by the source and receiver id
+func (t *sequenceTracker) stats() []SequenceStats {
+	now := t.now()
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	t.expire(now)
+
+	ret := make([]SequenceStats, 0, len(t.publishers))
+	for _, p := range t.publishers {
+		ret = append(ret, p.stats)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Source != ret[j].Source {
+			return ret[i].Source < ret[j].Source
+		}
+
+		return ret[i].ReceiverId < ret[j].ReceiverId
+	})
+
+	return ret
+}
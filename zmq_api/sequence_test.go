@@ -0,0 +1,76 @@
+package zmq_api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSequenceTracker(t *testing.T) {
+	tracker := newSequenceTracker()
+
+	checks := []struct {
+		m        Measurement
+		expected *Gap
+	}{
+		{Measurement{Source: "a", Seq: 5}, nil},
+		{Measurement{Source: "a", Seq: 6}, nil},
+		// not numbered
+		{Measurement{Source: "a"}, nil},
+		// another publisher behind the same source
+		{Measurement{Source: "a", ReceiverId: "r", Seq: 1}, nil},
+		{Measurement{Source: "a", Seq: 9}, &Gap{Source: "a", Lost: 2, Seq: 9, LastSeq: 6}},
+		{Measurement{Source: "a", Seq: 3}, &Gap{Source: "a", Lost: 2, Restart: true, Seq: 3, LastSeq: 9}},
+		{Measurement{Source: "a", ReceiverId: "r", Seq: 2}, nil},
+		{Measurement{Source: "b", Seq: 1}, nil},
+		{Measurement{Source: "b", Seq: 1}, &Gap{Source: "b", Restart: true, Seq: 1, LastSeq: 1}},
+	}
+
+	for i, check := range checks {
+		gap := tracker.track(&check.m)
+
+		if ((gap == nil) != (check.expected == nil)) ||
+			((gap != nil) && (*gap != *check.expected)) {
+			t.Fatalf("Check %d: got %v, expected %v", i, gap, check.expected)
+		}
+	}
+
+	expected := []SequenceStats{
+		{Source: "a", Received: 4, Lost: 4, Restarts: 1, LastSeq: 3},
+		{Source: "a", ReceiverId: "r", Received: 2, LastSeq: 2},
+		{Source: "b", Received: 2, Restarts: 1, LastSeq: 1},
+	}
+
+	stats := tracker.stats()
+	if len(stats) != len(expected) {
+		t.Fatalf("Got %v, expected %v", stats, expected)
+	}
+
+	for i := range stats {
+		if stats[i] != expected[i] {
+			t.Fatalf("Got %v, expected %v", stats, expected)
+		}
+	}
+}
+
+func TestSequenceTrackerExpire(t *testing.T) {
+	tracker := newSequenceTracker()
+
+	now := time.Unix(1600000000, 0)
+	tracker.now = func() time.Time { return now }
+
+	tracker.track(&Measurement{Source: "a", ReceiverId: "old", Seq: 1})
+
+	now = now.Add(sequenceExpireAfter / 2)
+	tracker.track(&Measurement{Source: "a", ReceiverId: "new", Seq: 1})
+
+	if stats := tracker.stats(); len(stats) != 2 {
+		t.Fatalf("Unexpected stats %v", stats)
+	}
+
+	now = now.Add(sequenceExpireAfter/2 + time.Second)
+
+	stats := tracker.stats()
+	if (len(stats) != 1) || (stats[0].ReceiverId != "new") {
+		t.Fatalf("Unexpected stats %v", stats)
+	}
+}
@@ -1,24 +1,234 @@
 package zmq_api
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kholmanskikh/home_sensors/zmq_api/zmq"
 )
 
+// messages larger than this are dropped by default
+const DefaultMaxMessageSize = 64 * 1024
+
+var ErrSubscriberDestroyed = errors.New("subscriber is destroyed")
+
+// Subscriber is safe for concurrent use. The receiving methods are
+// serialized, and Destroy() interrupts a blocked one, which then returns
+// ErrSubscriberDestroyed.
 type Subscriber struct {
 	ctx    *zmq.Context
-	sock   *zmq.Socket
-	poller *zmq.ReadPoller
+	poller *zmq.Poller
+
+	// held while receiving and destroying
+	recvMux   sync.Mutex
+	destroyed int32
+
+	subscriptions []*subscription
 
+	// the endpoint of the first source, kept for single endpoint subscribers
 	Endpoint string
+	Sources  []Source
+
+	MaxMessageSize int
+
+	// subscription prefixes, an empty list means 'everything'
+	TopicFilters []string
+
+	Conflate bool
+
+	// applied to each socket before connecting
+	socketSetups []func(sock *zmq.Socket) error
+
+	eventHandler func(e zmq.Event)
+	gapHandler   func(g Gap)
+
+	sequences *sequenceTracker
+
+	// guards the subscriptions' states and lastEvent
+	stateMux  sync.Mutex
+	lastEvent *zmq.Event
+}
+
+// Source is a receiver the subscriber connects to
+type Source struct {
+	// the received measurements are tagged with it,
+	// the endpoint is used if it is empty
+	Name     string
+	Endpoint string
+}
+
+type subscription struct {
+	source Source
+
+	sock        *zmq.Socket
+	monitor     *zmq.Monitor
+	monitorDone chan struct{}
+
+	state ConnectionState
+}
+
+type ConnectionState struct {
+	// set once the ZMTP handshake succeeds
+	Connected bool
+	// when Connected last changed, zero if it never did
+	Since time.Time
+
+	LastEvent *zmq.Event
+}
+
+type SubscriberOption func(s *Subscriber) error
+
+// WithMaxMessageSize sets the size limit for received messages,
+// 0 means no limit. The limit is enforced by the socket, a publisher
+// sending a larger message is disconnected and reconnected.
+func WithMaxMessageSize(size int) SubscriberOption {
+	return func(s *Subscriber) error {
+		if size < 0 {
+			return fmt.Errorf("invalid max message size %d", size)
+		}
+
+		s.MaxMessageSize = size
+		return nil
+	}
+}
+
+// WithTopicFilters makes the subscriber receive only messages whose topic
+// frame starts with one of the prefixes, see MeasurementTopic() and
+// DeviceTopicFilter(). Messages without the topic envelope are not
+// received then.
+func WithTopicFilters(prefixes ...string) SubscriberOption {
+	return func(s *Subscriber) error {
+		s.TopicFilters = append(s.TopicFilters, prefixes...)
+		return nil
+	}
+}
+
+// WithSocketSetup lets the caller set socket options not covered by
+// the other options. The function is called before connecting.
+func WithSocketSetup(setup func(sock *zmq.Socket) error) SubscriberOption {
+	return func(s *Subscriber) error {
+		s.socketSetups = append(s.socketSetups, setup)
+		return nil
+	}
 }
 
-func NewSubscriber(endpoint string) (*Subscriber, error) {
+// WithRcvHWM limits the number of messages queued in the socket
+func WithRcvHWM(value int) SubscriberOption {
+	return WithSocketSetup(func(sock *zmq.Socket) error {
+		return sock.SetRcvHWM(value)
+	})
+}
+
+func WithReconnectInterval(ivl, ivlMax time.Duration) SubscriberOption {
+	return WithSocketSetup(func(sock *zmq.Socket) error {
+		if err := sock.SetReconnectInterval(ivl); err != nil {
+			return err
+		}
+
+		return sock.SetReconnectIntervalMax(ivlMax)
+	})
+}
+
+// WithHeartbeat makes the socket detect dead connections using
+// ZMTP heartbeats
+func WithHeartbeat(ivl, timeout time.Duration) SubscriberOption {
+	return WithSocketSetup(func(sock *zmq.Socket) error {
+		if err := sock.SetHeartbeatInterval(ivl); err != nil {
+			return err
+		}
+
+		return sock.SetHeartbeatTimeout(timeout)
+	})
+}
+
+func WithTCPKeepalive(idle, interval time.Duration, count int) SubscriberOption {
+	return WithSocketSetup(func(sock *zmq.Socket) error {
+		if err := sock.SetTCPKeepalive(zmq.TCPKeepaliveOn); err != nil {
+			return err
+		}
+
+		if err := sock.SetTCPKeepaliveIdle(idle); err != nil {
+			return err
+		}
+
+		if err := sock.SetTCPKeepaliveInterval(interval); err != nil {
+			return err
+		}
+
+		return sock.SetTCPKeepaliveCount(count)
+	})
+}
+
+// WithConflate makes the socket keep only the latest message, i.e. one
+// message for all the devices of a source. ZMQ does not support conflating
+// multipart messages, so it cannot be used with WithTopicFilters() and
+// requires the publishers to send no topic envelope.
+func WithConflate() SubscriberOption {
+	return func(s *Subscriber) error {
+		s.Conflate = true
+
+		return WithSocketSetup(func(sock *zmq.Socket) error {
+			return sock.SetConflate(true)
+		})(s)
+	}
+}
+
+// WithCurve encrypts the connection with CURVE. serverKey is the public key
+// of the publisher, publicKey and secretKey are the subscriber's keypair.
+// All keys are Z85 encoded, see zmq.NewCurveKeypair().
+func WithCurve(serverKey, publicKey, secretKey string) SubscriberOption {
+	return WithSocketSetup(func(sock *zmq.Socket) error {
+		if err := sock.SetCurveServerKey(serverKey); err != nil {
+			return fmt.Errorf("invalid server key: %v", err)
+		}
+
+		if err := sock.SetCurvePublicKey(publicKey); err != nil {
+			return fmt.Errorf("invalid public key: %v", err)
+		}
+
+		if err := sock.SetCurveSecretKey(secretKey); err != nil {
+			return fmt.Errorf("invalid secret key: %v", err)
+		}
+
+		return nil
+	})
+}
+
+// WithEventHandler makes the subscriber call the handler for each
+// event of the socket (connected, disconnected, etc). The handler is called
+// from a separate goroutine and must not block.
+func WithEventHandler(handler func(e zmq.Event)) SubscriberOption {
+	return func(s *Subscriber) error {
+		s.eventHandler = handler
+		return nil
+	}
+}
+
+// WithGapHandler makes the subscriber call the handler when measurements
+// from a publisher numbering them were lost, see Publisher.Sequenced.
+// The handler is called from the receiving goroutine and must not block.
+func WithGapHandler(handler func(g Gap)) SubscriberOption {
+	return func(s *Subscriber) error {
+		s.gapHandler = handler
+		return nil
+	}
+}
+
+func NewSubscriber(endpoint string, opts ...SubscriberOption) (*Subscriber, error) {
+	return NewMultiSubscriber([]Source{{Endpoint: endpoint}}, opts...)
+}
+
+// NewMultiSubscriber connects to several receivers at once. The options
+// apply to all of them, e.g. WithCurve() requires all receivers to use
+// the same server key.
+func NewMultiSubscriber(sources []Source, opts ...SubscriberOption) (*Subscriber, error) {
 	var err error
-	s := Subscriber{Endpoint: endpoint}
+	s := Subscriber{MaxMessageSize: DefaultMaxMessageSize, sequences: newSequenceTracker()}
 
 	defer func() {
 		if err != nil {
@@ -26,47 +236,231 @@ func NewSubscriber(endpoint string) (*Subscriber, error) {
 		}
 	}()
 
+	if len(sources) == 0 {
+		err = fmt.Errorf("empty source list")
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, source := range sources {
+		if source.Endpoint == "" {
+			err = fmt.Errorf("empty endpoint for source '%s'", source.Name)
+			return nil, err
+		}
+
+		if source.Name == "" {
+			source.Name = source.Endpoint
+		}
+
+		if names[source.Name] {
+			err = fmt.Errorf("duplicate source name '%s'", source.Name)
+			return nil, err
+		}
+		names[source.Name] = true
+
+		s.Sources = append(s.Sources, source)
+	}
+
+	s.Endpoint = s.Sources[0].Endpoint
+
+	for _, opt := range opts {
+		if err = opt(&s); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.Conflate && (len(s.TopicFilters) != 0) {
+		err = fmt.Errorf("conflate cannot be used with topic filters")
+		return nil, err
+	}
+
 	s.ctx, err = zmq.NewContext()
 	if err != nil {
 		err = fmt.Errorf("NewContext() failed: %v", err)
 		return nil, err
 	}
 
-	s.sock, err = zmq.NewSocket(s.ctx, zmq.SocketSUB)
+	s.poller, err = zmq.NewPoller()
 	if err != nil {
-		err = fmt.Errorf("NewSocket() failed: %v", err)
+		err = fmt.Errorf("NewPoller() failed: %v", err)
 		return nil, err
 	}
 
-	if err = s.sock.Connect(s.Endpoint); err != nil {
-		err = fmt.Errorf("Connect('%s') failed: %v", s.Endpoint, err)
-		return nil, err
+	for _, source := range s.Sources {
+		sub := &subscription{source: source}
+		s.subscriptions = append(s.subscriptions, sub)
+
+		if err = s.subscribe(sub); err != nil {
+			err = fmt.Errorf("source '%s': %v", source.Name, err)
+			return nil, err
+		}
+
+		if err = s.poller.AddSocket(sub.sock, zmq.PollIn); err != nil {
+			err = fmt.Errorf("AddSocket() failed: %v", err)
+			return nil, err
+		}
 	}
 
-	if err = s.sock.AddSubscribeFilter(nil); err != nil {
-		err = fmt.Errorf("AddSubscribeFilter('') failed: %v", err)
-		return nil, err
+	return &s, err
+}
+
+// subscribe sets up the socket of the subscription, the socket is
+// closed by cleanupResources() on error
+func (s *Subscriber) subscribe(sub *subscription) error {
+	var err error
+
+	sub.sock, err = zmq.NewSocket(s.ctx, zmq.SocketSUB)
+	if err != nil {
+		return fmt.Errorf("NewSocket() failed: %v", err)
 	}
 
-	s.poller, err = zmq.NewReadPoller(s.sock)
+	maxMsgSize := int64(-1)
+	if s.MaxMessageSize != 0 {
+		maxMsgSize = int64(s.MaxMessageSize)
+	}
+
+	if err = sub.sock.SetMaxMsgSize(maxMsgSize); err != nil {
+		return fmt.Errorf("SetMaxMsgSize() failed: %v", err)
+	}
+
+	for _, setup := range s.socketSetups {
+		if err = setup(sub.sock); err != nil {
+			return fmt.Errorf("socket setup failed: %v", err)
+		}
+	}
+
+	// must be set up before connecting not to miss the first events
+	sub.monitor, err = zmq.NewMonitor(s.ctx, sub.sock, zmq.EventAll)
 	if err != nil {
-		err := fmt.Errorf("NewReadPoller() failed: %v", err)
-		return nil, err
+		return fmt.Errorf("NewMonitor() failed: %v", err)
 	}
 
-	return &s, err
+	sub.monitorDone = make(chan struct{})
+	go s.handleEvents(sub)
+
+	if err = sub.sock.Connect(sub.source.Endpoint); err != nil {
+		return fmt.Errorf("Connect('%s') failed: %v", sub.source.Endpoint, err)
+	}
+
+	if len(s.TopicFilters) == 0 {
+		if err = sub.sock.AddSubscribeFilter(nil); err != nil {
+			return fmt.Errorf("AddSubscribeFilter('') failed: %v", err)
+		}
+	}
+
+	for _, prefix := range s.TopicFilters {
+		if err = sub.sock.AddSubscribeFilter([]byte(prefix)); err != nil {
+			return fmt.Errorf("AddSubscribeFilter('%s') failed: %v", prefix, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Subscriber) handleEvents(sub *subscription) {
+	defer close(sub.monitorDone)
+
+	for event := range sub.monitor.Events() {
+		e := event
+
+		s.stateMux.Lock()
+		// TCP connected is not enough, e.g. the CURVE keys may not match
+		switch e.Type {
+		case zmq.EventHandshakeSucceeded:
+			if !sub.state.Connected {
+				sub.state.Connected = true
+				sub.state.Since = time.Now()
+			}
+		case zmq.EventDisconnected, zmq.EventHandshakeFailedNoDetail,
+			zmq.EventHandshakeFailedProtocol, zmq.EventHandshakeFailedAuth:
+			if sub.state.Connected {
+				sub.state.Connected = false
+				sub.state.Since = time.Now()
+			}
+		}
+		sub.state.LastEvent = &e
+		s.lastEvent = &e
+		s.stateMux.Unlock()
+
+		if s.eventHandler != nil {
+			s.eventHandler(e)
+		}
+	}
+}
+
+// ConnectionState tells if the subscriber is connected to all sources.
+// Since and LastEvent are the latest ones among the sources.
+func (s *Subscriber) ConnectionState() ConnectionState {
+	s.stateMux.Lock()
+	defer s.stateMux.Unlock()
+
+	ret := ConnectionState{Connected: true, LastEvent: s.lastEvent}
+	for _, sub := range s.subscriptions {
+		ret.Connected = ret.Connected && sub.state.Connected
+
+		if sub.state.Since.After(ret.Since) {
+			ret.Since = sub.state.Since
+		}
+	}
+
+	return ret
+}
+
+// ConnectionStates returns the connection state of each source by its name
+func (s *Subscriber) ConnectionStates() map[string]ConnectionState {
+	s.stateMux.Lock()
+	defer s.stateMux.Unlock()
+
+	ret := make(map[string]ConnectionState)
+	for _, sub := range s.subscriptions {
+		ret[sub.source.Name] = sub.state
+	}
+
+	return ret
+}
+
+// SequenceStats returns the statistics of the sequence numbers of each
+// publisher which numbers its measurements. A publisher which sent
+// nothing for a day is dropped.
+func (s *Subscriber) SequenceStats() []SequenceStats {
+	return s.sequences.stats()
 }
 
 func (s *Subscriber) cleanupResources() error {
 	var err error
 
-	if s.sock != nil {
-		sockErr := s.sock.Close()
-		if (err == nil) && (sockErr != nil) {
-			err = fmt.Errorf("socket Close() failed: %v", sockErr)
+	// the monitors must be closed before the sockets, and the poller
+	// is not needed for closing the sockets
+	for _, sub := range s.subscriptions {
+		if sub.monitor != nil {
+			monitorErr := sub.monitor.Close()
+			if (err == nil) && (monitorErr != nil) {
+				err = fmt.Errorf("monitor Close() failed: %v", monitorErr)
+			}
+
+			<-sub.monitorDone
+			sub.monitor = nil
+		}
+	}
+
+	if s.poller != nil {
+		pollerErr := s.poller.Close()
+		if (err == nil) && (pollerErr != nil) {
+			err = fmt.Errorf("poller Close() failed: %v", pollerErr)
 		}
 
-		s.sock = nil
+		s.poller = nil
+	}
+
+	for _, sub := range s.subscriptions {
+		if sub.sock != nil {
+			sockErr := sub.sock.Close()
+			if (err == nil) && (sockErr != nil) {
+				err = fmt.Errorf("socket Close() failed: %v", sockErr)
+			}
+
+			sub.sock = nil
+		}
 	}
 
 	if s.ctx != nil {
@@ -82,53 +476,259 @@ func (s *Subscriber) cleanupResources() error {
 }
 
 func (s *Subscriber) Destroy() error {
+	if !atomic.CompareAndSwapInt32(&s.destroyed, 0, 1) {
+		return nil
+	}
+
+	// interrupts a receiving method blocked in Poll(), or makes
+	// the next Poll() return immediately. Without it taking recvMux may
+	// block forever, so the subscriber is left as is for another Destroy().
+	if err := s.poller.Wakeup(); err != nil {
+		atomic.StoreInt32(&s.destroyed, 0)
+		return fmt.Errorf("Wakeup() failed: %v", err)
+	}
+
+	s.recvMux.Lock()
+	defer s.recvMux.Unlock()
+
 	return s.cleanupResources()
 }
 
-// returns the first error encountered
+func (s *Subscriber) isDestroyed() bool {
+	return atomic.LoadInt32(&s.destroyed) != 0
+}
+
+// Messages which cannot be decoded are reported as DecodeErrors,
+// the successfully decoded measurements are returned anyway.
 func (s *Subscriber) RecvMeasurement(timeout time.Duration) ([]*Measurement, error) {
-	measurements := make([]*Measurement, 0)
+	s.recvMux.Lock()
+	defer s.recvMux.Unlock()
+
+	if s.isDestroyed() {
+		return make([]*Measurement, 0), ErrSubscriberDestroyed
+	}
 
-	// as Poll() is edge-triggered, and RecvMeasurement() returns the first error
-	// encountered, Poll() may return an empty socket list if the previous call
-	// to Poll() failed with an error. That's why we have to always check the
-	// socket for available messages.
-	_, err := s.poller.Poll(timeout)
+	items, err := s.poller.Poll(timeout)
 	if err != nil {
-		return measurements, fmt.Errorf("Poll() failed: %v", err)
+		return make([]*Measurement, 0), fmt.Errorf("Poll() failed: %v", err)
 	}
 
-	var recvM struct {
-		DeviceId  int     `json:"device_id"`
-		Type      string  `json:"type"`
-		Value     float64 `json:"value"`
-		Timestamp int     `json:"timestamp"`
+	if s.isDestroyed() {
+		return make([]*Measurement, 0), ErrSubscriberDestroyed
 	}
 
-	recvBuf := make([]byte, 1024)
+	return s.recvAvailable(items)
+}
+
+// RecvMeasurementContext blocks until at least one measurement is received
+// or ctx is done. In the latter case ctx.Err() is returned.
+func (s *Subscriber) RecvMeasurementContext(ctx context.Context) ([]*Measurement, error) {
+	s.recvMux.Lock()
+	defer s.recvMux.Unlock()
+
+	if s.isDestroyed() {
+		return make([]*Measurement, 0), ErrSubscriberDestroyed
+	}
+
+	stopChan := make(chan struct{})
+	doneChan := make(chan struct{})
+
+	go func() {
+		defer close(doneChan)
+
+		select {
+		case <-ctx.Done():
+			s.poller.Wakeup()
+		case <-stopChan:
+		}
+	}()
+
+	// the goroutine must not touch the poller after we return
+	defer func() {
+		close(stopChan)
+		<-doneChan
+	}()
+
 	for {
-		recvLen, err, received := s.sock.RecvNonBlocking(recvBuf)
+		if err := ctx.Err(); err != nil {
+			return make([]*Measurement, 0), err
+		}
+
+		if s.isDestroyed() {
+			return make([]*Measurement, 0), ErrSubscriberDestroyed
+		}
+
+		items, err := s.poller.Poll(-1)
 		if err != nil {
-			return measurements, fmt.Errorf("RecvNonBlocking() failed: %v", err)
+			return make([]*Measurement, 0), fmt.Errorf("Poll() failed: %v", err)
+		}
+
+		// woken up, ctx and the destroyed flag are checked above
+		if len(items) == 0 {
+			continue
+		}
+
+		measurements, err := s.recvAvailable(items)
+		if (err != nil) || (len(measurements) != 0) {
+			return measurements, err
+		}
+	}
+}
+
+// after a receive error other than DecodeErrors, Stream() waits before
+// retrying, doubling the wait up to the maximum while the errors persist
+const (
+	streamRetryInterval    = 100 * time.Millisecond
+	streamRetryIntervalMax = 10 * time.Second
+)
+
+// Stream delivers the received measurements on the first channel and
+// receive errors on the second one until ctx is done or the subscriber
+// is destroyed, then closes both channels. Persistent receive errors
+// are retried with a backoff.
+func (s *Subscriber) Stream(ctx context.Context) (<-chan *Measurement, <-chan error) {
+	measurementChan := make(chan *Measurement)
+	errorChan := make(chan error)
+
+	go func() {
+		defer close(measurementChan)
+		defer close(errorChan)
+
+		retryInterval := streamRetryInterval
+
+		for {
+			measurements, err := s.RecvMeasurementContext(ctx)
+			if (ctx.Err() != nil) || (errors.Is(err, ErrSubscriberDestroyed)) {
+				return
+			}
+
+			for _, m := range measurements {
+				select {
+				case measurementChan <- m:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if err == nil {
+				retryInterval = streamRetryInterval
+				continue
+			}
+
+			select {
+			case errorChan <- err:
+			case <-ctx.Done():
+				return
+			}
+
+			var decodeErrors DecodeErrors
+			if errors.As(err, &decodeErrors) {
+				continue
+			}
+
+			select {
+			case <-time.After(retryInterval):
+			case <-ctx.Done():
+				return
+			}
+
+			retryInterval *= 2
+			if retryInterval > streamRetryIntervalMax {
+				retryInterval = streamRetryIntervalMax
+			}
+		}
+	}()
+
+	return measurementChan, errorChan
+}
+
+// recvAvailable receives all queued messages from the ready sources.
+// Messages which are too large or cannot be decoded do not prevent
+// receiving the rest, they are reported as DecodeErrors.
+func (s *Subscriber) recvAvailable(items []zmq.PollItem) ([]*Measurement, error) {
+	measurements := make([]*Measurement, 0)
+	var decodeErrors DecodeErrors
+
+	for _, sub := range s.subscriptions {
+		if !isReady(sub.sock, items) {
+			continue
+		}
+
+		subMeasurements, subDecodeErrors, err := s.recvAvailableFrom(sub)
+		measurements = append(measurements, subMeasurements...)
+		decodeErrors = append(decodeErrors, subDecodeErrors...)
+
+		if err != nil {
+			return measurements, fmt.Errorf("source '%s': %v", sub.source.Name, err)
+		}
+	}
+
+	if len(decodeErrors) != 0 {
+		return measurements, decodeErrors
+	}
+
+	return measurements, nil
+}
+
+func isReady(sock *zmq.Socket, items []zmq.PollItem) bool {
+	for _, item := range items {
+		if item.Socket == sock {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Subscriber) recvAvailableFrom(sub *subscription) ([]*Measurement, DecodeErrors, error) {
+	measurements := make([]*Measurement, 0)
+	var decodeErrors DecodeErrors
+
+	for {
+		frames, err, received := sub.sock.RecvMultipartNonBlocking()
+		if err != nil {
+			return measurements, decodeErrors,
+				fmt.Errorf("RecvMultipartNonBlocking() failed: %v", err)
 		}
 
 		if !received {
 			break
 		}
 
-		recvData := recvBuf[:recvLen]
+		// either a bare payload, or the topic followed by the payload
+		if (len(frames) != 1) && (len(frames) != 2) {
+			decodeErrors = append(decodeErrors, &DecodeError{Payload: bytes.Join(frames, nil),
+				Source: sub.source.Name,
+				Reason: fmt.Sprintf("unexpected number of frames %d", len(frames))})
+			continue
+		}
+
+		recvData := frames[len(frames)-1]
+
+		// normally already dropped by the socket
+		if (s.MaxMessageSize != 0) && (len(recvData) > s.MaxMessageSize) {
+			decodeErrors = append(decodeErrors, &DecodeError{Payload: recvData,
+				Source: sub.source.Name,
+				Reason: fmt.Sprintf("message of %d bytes exceeds the limit of %d bytes",
+					len(recvData), s.MaxMessageSize)})
+			continue
+		}
 
-		err = json.Unmarshal(recvData, &recvM)
+		m, err := decodeMeasurement(recvData)
 		if err != nil {
-			return measurements, fmt.Errorf("Unmarshal('%s') failed: %v", string(recvData), err)
+			decodeErrors = append(decodeErrors, &DecodeError{Payload: recvData,
+				Source: sub.source.Name,
+				Reason: err.Error()})
+			continue
 		}
 
-		m := Measurement{DeviceId: recvM.DeviceId,
-			Type:      recvM.Type,
-			Value:     recvM.Value,
-			Timestamp: recvM.Timestamp}
-		measurements = append(measurements, &m)
+		m.Source = sub.source.Name
+		measurements = append(measurements, m)
+
+		if gap := s.sequences.track(m); (gap != nil) && (s.gapHandler != nil) {
+			s.gapHandler(*gap)
+		}
 	}
 
-	return measurements, nil
+	return measurements, decodeErrors, nil
 }
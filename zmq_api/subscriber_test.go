@@ -1,7 +1,10 @@
 package zmq_api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -14,7 +17,7 @@ type sendWorker struct {
 	stopChan chan struct{}
 }
 
-func newSendWorker(endpoint string, dataToSend string) (*sendWorker, error) {
+func newSendWorker(endpoint string, dataToSend ...string) (*sendWorker, error) {
 	var w sendWorker
 	w.stopChan = make(chan struct{})
 
@@ -49,8 +52,10 @@ func newSendWorker(endpoint string, dataToSend string) (*sendWorker, error) {
 				break
 			}
 
-			if err = sock.Send([]byte(dataToSend)); err != nil {
-				fmt.Printf("Send('%s') failed: %v\n", dataToSend, err)
+			for _, data := range dataToSend {
+				if err = sock.Send([]byte(data)); err != nil {
+					fmt.Printf("Send('%s') failed: %v\n", data, err)
+				}
 			}
 
 			time.Sleep(time.Millisecond * 200)
@@ -73,7 +78,7 @@ func TestSubscriber(t *testing.T) {
 	const endpoint = "tcp://127.0.0.1:9000"
 
 	measurementData := `{"device_id": 99, "type": "Some type", "value": 90.7, "timestamp": 12}`
-	expectedMeasurement := Measurement{DeviceId: 99, Type: "Some type", Value: 90.7, Timestamp: 12}
+	expectedMeasurement := Measurement{DeviceId: 99, Type: "Some type", Value: 90.7, Timestamp: 12, Source: endpoint}
 
 	sender, err := newSendWorker(endpoint, measurementData)
 	if err != nil {
@@ -107,3 +112,413 @@ func TestSubscriber(t *testing.T) {
 		t.Fatalf("Destroy() failed: %v", err)
 	}
 }
+
+func TestRecvMeasurementContextCancel(t *testing.T) {
+	const endpoint = "tcp://127.0.0.1:9001"
+
+	s, err := NewSubscriber(endpoint)
+	if err != nil {
+		t.Fatalf("NewSubscriber() failed: %v", err)
+	}
+	defer s.Destroy()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+
+	_, err = s.RecvMeasurementContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Unexpected error from RecvMeasurementContext(): %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("RecvMeasurementContext() returned %v after the cancellation", elapsed)
+	}
+}
+
+func TestStream(t *testing.T) {
+	const endpoint = "tcp://127.0.0.1:9002"
+
+	measurementData := `{"device_id": 5, "type": "Humidity", "value": 45.5, "timestamp": 13}`
+	expectedMeasurement := Measurement{DeviceId: 5, Type: "Humidity", Value: 45.5, Timestamp: 13, Source: endpoint}
+
+	sender, err := newSendWorker(endpoint, measurementData)
+	if err != nil {
+		t.Fatalf("newSendWorker() failed: %v", err)
+	}
+	defer sender.Destroy()
+
+	s, err := NewSubscriber(endpoint)
+	if err != nil {
+		t.Fatalf("NewSubscriber() failed: %v", err)
+	}
+	defer s.Destroy()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	measurementChan, errorChan := s.Stream(ctx)
+
+	select {
+	case m := <-measurementChan:
+		if *m != expectedMeasurement {
+			t.Fatalf("Got '%#v', expected '%#v'", *m, expectedMeasurement)
+		}
+	case err := <-errorChan:
+		t.Fatalf("Stream() reported error: %v", err)
+	case <-ctx.Done():
+		t.Fatalf("No measurement received")
+	}
+
+	cancel()
+
+	// both channels are closed after the cancellation
+	for range measurementChan {
+	}
+	for range errorChan {
+	}
+}
+
+func TestSubscriberMaxMessageSize(t *testing.T) {
+	const endpoint = "tcp://127.0.0.1:9003"
+
+	measurementData := `{"device_id": 99, "type": "Some type", "value": 90.7, "timestamp": 12, "padding": "` +
+		strings.Repeat("x", 2000) + `"}`
+
+	sender, err := newSendWorker(endpoint, measurementData)
+	if err != nil {
+		t.Fatalf("newSendWorker() failed: %v", err)
+	}
+	defer sender.Destroy()
+
+	if _, err := NewSubscriber(endpoint, WithMaxMessageSize(-1)); err == nil {
+		t.Fatalf("NewSubscriber() did not fail for a negative max message size")
+	}
+
+	// messages larger than the former 1024 bytes buffer are received as is
+	s, err := NewSubscriber(endpoint)
+	if err != nil {
+		t.Fatalf("NewSubscriber() failed: %v", err)
+	}
+	defer s.Destroy()
+
+	var measurements []*Measurement
+	for i := 0; (i < 10) && (len(measurements) == 0); i++ {
+		measurements, err = s.RecvMeasurement(time.Millisecond * 100)
+		if err != nil {
+			t.Fatalf("RecvMeasurement() failed: %v", err)
+		}
+	}
+
+	if len(measurements) == 0 {
+		t.Fatalf("No measurement received")
+	}
+
+	limited, err := NewSubscriber(endpoint, WithMaxMessageSize(1024))
+	if err != nil {
+		t.Fatalf("NewSubscriber() failed: %v", err)
+	}
+	defer limited.Destroy()
+
+	// the socket drops the message, or the decoder reports it
+	for i := 0; i < 10; i++ {
+		measurements, err = limited.RecvMeasurement(time.Millisecond * 100)
+		if err != nil {
+			if !strings.Contains(err.Error(), "exceeds the limit") {
+				t.Fatalf("Unexpected error from RecvMeasurement(): %v", err)
+			}
+			break
+		}
+
+		if len(measurements) != 0 {
+			t.Fatalf("Received a message exceeding the limit")
+		}
+	}
+}
+
+func TestSubscriberDecodeErrors(t *testing.T) {
+	const endpoint = "tcp://127.0.0.1:9004"
+
+	badData := `{"device_id": "not a number"}`
+	measurementData := `{"device_id": 99, "type": "Some type", "value": 90.7, "timestamp": 12}`
+	expectedMeasurement := Measurement{DeviceId: 99, Type: "Some type", Value: 90.7, Timestamp: 12, Source: endpoint}
+
+	sender, err := newSendWorker(endpoint, badData, measurementData)
+	if err != nil {
+		t.Fatalf("newSendWorker() failed: %v", err)
+	}
+	defer sender.Destroy()
+
+	s, err := NewSubscriber(endpoint)
+	if err != nil {
+		t.Fatalf("NewSubscriber() failed: %v", err)
+	}
+	defer s.Destroy()
+
+	var received []*Measurement
+	var decodeErrors DecodeErrors
+	for i := 0; (i < 20) && ((len(received) == 0) || (len(decodeErrors) == 0)); i++ {
+		measurements, err := s.RecvMeasurement(time.Millisecond * 100)
+		received = append(received, measurements...)
+
+		if err != nil {
+			var errs DecodeErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("RecvMeasurement() failed: %v", err)
+			}
+
+			decodeErrors = append(decodeErrors, errs...)
+		}
+	}
+
+	if len(received) == 0 {
+		t.Fatalf("The valid measurements were not received")
+	}
+
+	if *received[0] != expectedMeasurement {
+		t.Fatalf("Got '%#v', expected '%#v'", *received[0], expectedMeasurement)
+	}
+
+	if len(decodeErrors) == 0 {
+		t.Fatalf("No decode errors reported")
+	}
+
+	if string(decodeErrors[0].Payload) != badData {
+		t.Fatalf("Decode error payload is '%s', expected '%s'", decodeErrors[0].Payload, badData)
+	}
+}
+
+func TestSubscriberSocketOptions(t *testing.T) {
+	const endpoint = "tcp://127.0.0.1:9007"
+
+	s, err := NewSubscriber(endpoint,
+		WithRcvHWM(50),
+		WithReconnectInterval(200*time.Millisecond, 5*time.Second),
+		WithHeartbeat(time.Second, 3*time.Second),
+		WithTCPKeepalive(30*time.Second, 5*time.Second, 3))
+	if err != nil {
+		t.Fatalf("NewSubscriber() failed: %v", err)
+	}
+	defer s.Destroy()
+
+	sock := s.subscriptions[0].sock
+
+	if hwm, err := sock.GetRcvHWM(); (err != nil) || (hwm != 50) {
+		t.Fatalf("RCVHWM is %d (%v), expected 50", hwm, err)
+	}
+
+	if ivl, err := sock.GetReconnectIntervalMax(); (err != nil) || (ivl != 5*time.Second) {
+		t.Fatalf("RECONNECT_IVL_MAX is %v (%v), expected 5s", ivl, err)
+	}
+
+	if ivl, err := sock.GetHeartbeatInterval(); (err != nil) || (ivl != time.Second) {
+		t.Fatalf("HEARTBEAT_IVL is %v (%v), expected 1s", ivl, err)
+	}
+
+	if cnt, err := sock.GetTCPKeepaliveCount(); (err != nil) || (cnt != 3) {
+		t.Fatalf("TCP_KEEPALIVE_CNT is %d (%v), expected 3", cnt, err)
+	}
+
+	failingSetup := WithSocketSetup(func(sock *zmq.Socket) error {
+		return fmt.Errorf("some error")
+	})
+
+	if _, err := NewSubscriber(endpoint, failingSetup); (err == nil) || (!strings.Contains(err.Error(), "some error")) {
+		t.Fatalf("Unexpected error from NewSubscriber(): %v", err)
+	}
+
+	if _, err := NewSubscriber(endpoint, WithConflate(), WithTopicFilters(DeviceTopicFilter(1))); err == nil {
+		t.Fatalf("NewSubscriber() accepted conflate with topic filters")
+	}
+}
+
+func waitForConnectionState(s *Subscriber, connected bool) bool {
+	for i := 0; i < 50; i++ {
+		if s.ConnectionState().Connected == connected {
+			return true
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return false
+}
+
+func TestSubscriberConnectionState(t *testing.T) {
+	const endpoint = "tcp://127.0.0.1:9009"
+
+	var handledEvents int32
+
+	s, err := NewSubscriber(endpoint, WithEventHandler(func(e zmq.Event) {
+		atomic.AddInt32(&handledEvents, 1)
+	}))
+	if err != nil {
+		t.Fatalf("NewSubscriber() failed: %v", err)
+	}
+	defer s.Destroy()
+
+	if state := s.ConnectionState(); state.Connected || (!state.Since.IsZero()) {
+		t.Fatalf("Unexpected initial state %#v", state)
+	}
+
+	sender, err := newSendWorker(endpoint)
+	if err != nil {
+		t.Fatalf("newSendWorker() failed: %v", err)
+	}
+
+	if !waitForConnectionState(s, true) {
+		sender.Destroy()
+		t.Fatalf("The subscriber did not connect")
+	}
+
+	sender.Destroy()
+
+	if !waitForConnectionState(s, false) {
+		t.Fatalf("The subscriber did not detect the disconnect")
+	}
+
+	state := s.ConnectionState()
+	if (state.LastEvent == nil) || (state.LastEvent.Endpoint != endpoint) || (state.Since.IsZero()) {
+		t.Fatalf("Unexpected state %#v", state)
+	}
+
+	if atomic.LoadInt32(&handledEvents) == 0 {
+		t.Fatalf("The event handler was not called")
+	}
+}
+
+func TestMultiSubscriber(t *testing.T) {
+	sources := []Source{{Name: "upstairs", Endpoint: "tcp://127.0.0.1:9010"},
+		{Name: "shed", Endpoint: "tcp://127.0.0.1:9011"}}
+
+	upstairsData := `{"device_id": 1, "type": "Temperature", "value": 21.5, "timestamp": 12}`
+	shedData := `{"device_id": 2, "type": "Temperature", "value": 5.5, "timestamp": 13}`
+
+	expected := map[string]Measurement{
+		"upstairs": {DeviceId: 1, Type: "Temperature", Value: 21.5, Timestamp: 12, Source: "upstairs"},
+		"shed":     {DeviceId: 2, Type: "Temperature", Value: 5.5, Timestamp: 13, Source: "shed"},
+	}
+
+	for i, data := range []string{upstairsData, shedData} {
+		sender, err := newSendWorker(sources[i].Endpoint, data)
+		if err != nil {
+			t.Fatalf("newSendWorker() failed: %v", err)
+		}
+		defer sender.Destroy()
+	}
+
+	s, err := NewMultiSubscriber(sources)
+	if err != nil {
+		t.Fatalf("NewMultiSubscriber() failed: %v", err)
+	}
+	defer s.Destroy()
+
+	received := make(map[string]bool)
+	for i := 0; (i < 20) && (len(received) < len(sources)); i++ {
+		measurements, err := s.RecvMeasurement(time.Millisecond * 100)
+		if err != nil {
+			t.Fatalf("RecvMeasurement() failed: %v", err)
+		}
+
+		for _, m := range measurements {
+			if *m != expected[m.Source] {
+				t.Fatalf("Got '%#v', expected '%#v'", *m, expected[m.Source])
+			}
+
+			received[m.Source] = true
+		}
+	}
+
+	if len(received) != len(sources) {
+		t.Fatalf("Received measurements only from %v", received)
+	}
+
+	if states := s.ConnectionStates(); len(states) != len(sources) {
+		t.Fatalf("Unexpected connection states %v", states)
+	}
+}
+
+func TestMultiSubscriberInvalidSources(t *testing.T) {
+	checks := []struct {
+		sources []Source
+		err     string
+	}{
+		{nil, "empty source list"},
+		{[]Source{{Name: "a"}}, "empty endpoint for source 'a'"},
+		{[]Source{{Name: "a", Endpoint: "tcp://127.0.0.1:9012"},
+			{Name: "a", Endpoint: "tcp://127.0.0.1:9013"}}, "duplicate source name 'a'"},
+		{[]Source{{Endpoint: "tcp://127.0.0.1:9012"},
+			{Endpoint: "tcp://127.0.0.1:9012"}}, "duplicate source name 'tcp://127.0.0.1:9012'"},
+	}
+
+	for _, check := range checks {
+		if _, err := NewMultiSubscriber(check.sources); (err == nil) || (err.Error() != check.err) {
+			t.Fatalf("Got error '%v' for %v, expected '%s'", err, check.sources, check.err)
+		}
+	}
+}
+
+func TestSubscriberConcurrentUse(t *testing.T) {
+	const endpoint = "tcp://127.0.0.1:9014"
+	const receivers = 4
+
+	measurementData := `{"device_id": 99, "type": "Some type", "value": 90.7, "timestamp": 12}`
+
+	sender, err := newSendWorker(endpoint, measurementData)
+	if err != nil {
+		t.Fatalf("newSendWorker() failed: %v", err)
+	}
+	defer sender.Destroy()
+
+	s, err := NewSubscriber(endpoint)
+	if err != nil {
+		t.Fatalf("NewSubscriber() failed: %v", err)
+	}
+
+	errorChan := make(chan error, receivers+1)
+
+	for i := 0; i < receivers; i++ {
+		go func() {
+			for {
+				if _, err := s.RecvMeasurement(time.Millisecond * 100); err != nil {
+					errorChan <- err
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		for {
+			if _, err := s.RecvMeasurementContext(context.Background()); err != nil {
+				errorChan <- err
+				return
+			}
+		}
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+
+	if err := s.Destroy(); err != nil {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+
+	for i := 0; i < receivers+1; i++ {
+		select {
+		case err := <-errorChan:
+			if !errors.Is(err, ErrSubscriberDestroyed) {
+				t.Fatalf("Got error '%v', expected '%v'", err, ErrSubscriberDestroyed)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("A receiving goroutine did not return after Destroy()")
+		}
+	}
+
+	if err := s.Destroy(); err != nil {
+		t.Fatalf("The second Destroy() failed: %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package zmq_api
+
+import (
+	"fmt"
+)
+
+// When the topic envelope is used, each message consists of two frames:
+// the topic of the form "sensors/<device_id>/<type>" (the type is lower
+// case) and the JSON payload. As ZMQ matches subscriptions against the
+// first frame, subscribers may receive only the sensors they need.
+const TopicPrefix = "sensors/"
+
+func MeasurementTopic(deviceId int, mtype Kind) string {
+	return fmt.Sprintf("%s%d/%s", TopicPrefix, deviceId, mtype.Name())
+}
+
+// DeviceTopicFilter returns the subscription prefix matching all
+// measurements of the device
+func DeviceTopicFilter(deviceId int) string {
+	return fmt.Sprintf("%s%d/", TopicPrefix, deviceId)
+}
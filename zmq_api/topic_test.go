@@ -0,0 +1,20 @@
+package zmq_api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMeasurementTopic(t *testing.T) {
+	if topic := MeasurementTopic(3, "Temperature"); topic != "sensors/3/temperature" {
+		t.Fatalf("Unexpected topic '%s'", topic)
+	}
+
+	if !strings.HasPrefix(MeasurementTopic(1, "Humidity"), DeviceTopicFilter(1)) {
+		t.Fatalf("The device filter does not match the device topic")
+	}
+
+	if strings.HasPrefix(MeasurementTopic(10, "Humidity"), DeviceTopicFilter(1)) {
+		t.Fatalf("The filter for device 1 matches device 10")
+	}
+}
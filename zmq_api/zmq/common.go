@@ -0,0 +1,127 @@
+package zmq
+
+// The declarations shared by the cgo implementation and the pure Go one
+// (built without cgo or with the zmq_purego tag). The latter supports
+// only PUB and SUB sockets over TCP without CURVE, which is what
+// zmq_api.Subscriber and zmq_api.Publisher need.
+
+import (
+	"errors"
+	"fmt"
+)
+
+type socketType int
+
+const (
+	SocketPUB socketType = iota
+	SocketSUB
+	SocketPUSH
+	SocketPULL
+	SocketREQ
+	SocketREP
+	SocketDEALER
+	SocketROUTER
+	SocketXPUB
+	SocketXSUB
+	SocketPAIR
+)
+
+func (tp socketType) String() string {
+	return [...]string{"PUB", "SUB", "PUSH", "PULL", "REQ", "REP",
+		"DEALER", "ROUTER", "XPUB", "XSUB", "PAIR"}[tp]
+}
+
+var (
+	ErrContextTerminated = errors.New("context is terminated")
+	ErrSocketClosed      = errors.New("socket is closed")
+	ErrPollerClosed      = errors.New("poller is closed")
+
+	// returned by the pure Go implementation for what it does not support
+	ErrNotSupported = errors.New("not supported by the pure Go implementation")
+)
+
+type EventType int
+
+var eventTypeNames = map[EventType]string{
+	EventConnected:               "connected",
+	EventConnectDelayed:          "connect delayed",
+	EventConnectRetried:          "connect retried",
+	EventListening:               "listening",
+	EventBindFailed:              "bind failed",
+	EventAccepted:                "accepted",
+	EventAcceptFailed:            "accept failed",
+	EventClosed:                  "closed",
+	EventCloseFailed:             "close failed",
+	EventDisconnected:            "disconnected",
+	EventMonitorStopped:          "monitor stopped",
+	EventHandshakeFailedNoDetail: "handshake failed",
+	EventHandshakeSucceeded:      "handshake succeeded",
+	EventHandshakeFailedProtocol: "handshake failed (protocol error)",
+	EventHandshakeFailedAuth:     "handshake failed (authentication error)",
+}
+
+func (tp EventType) String() string {
+	if name, ok := eventTypeNames[tp]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("unknown event 0x%x", int(tp))
+}
+
+type Event struct {
+	Type EventType
+
+	// depends on the type: a file descriptor, an errno value,
+	// a reconnect interval in milliseconds or a ZMTP error code
+	Value int
+
+	Endpoint string
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("%s: %s", e.Endpoint, e.Type)
+}
+
+type PollEvents int16
+
+// PollItem is either a socket or a plain file descriptor
+// with the events it is ready for
+type PollItem struct {
+	Socket *Socket
+	Fd     int
+	Events PollEvents
+}
+
+// -1 means 'the OS default'
+type TCPKeepalive int
+
+const (
+	TCPKeepaliveDefault TCPKeepalive = -1
+	TCPKeepaliveOff     TCPKeepalive = 0
+	TCPKeepaliveOn      TCPKeepalive = 1
+)
+
+type ZAPRequest struct {
+	Domain    string
+	Address   string
+	Mechanism string
+
+	// Z85 encoded client public key, set for the CURVE mechanism only
+	ClientKey string
+}
+
+// ZAPAuthorizer returns true if the connection is allowed
+type ZAPAuthorizer func(req *ZAPRequest) bool
+
+// CurveAllowList returns an authorizer accepting only CURVE clients
+// with the given public keys
+func CurveAllowList(clientKeys ...string) ZAPAuthorizer {
+	allowed := make(map[string]bool)
+	for _, key := range clientKeys {
+		allowed[key] = true
+	}
+
+	return func(req *ZAPRequest) bool {
+		return (req.Mechanism == "CURVE") && (allowed[req.ClientKey])
+	}
+}
@@ -0,0 +1,112 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package zmq
+
+// #include <zmq.h>
+// #include <stdlib.h>
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// CURVE keys are passed around Z85 encoded, i.e. as 40 character strings
+const curveKeyLen = 40
+
+func checkCurveKey(key string) error {
+	if len(key) != curveKeyLen {
+		return fmt.Errorf("a Z85 encoded CURVE key must be %d characters long, got %d",
+			curveKeyLen, len(key))
+	}
+
+	return nil
+}
+
+func NewCurveKeypair() (string, string, error) {
+	publicKey := make([]C.char, curveKeyLen+1)
+	secretKey := make([]C.char, curveKeyLen+1)
+
+	rv, err := C.zmq_curve_keypair(&publicKey[0], &secretKey[0])
+	if rv != 0 {
+		return "", "", fmt.Errorf("zmq_curve_keypair() failed: %v", err)
+	}
+
+	return C.GoString(&publicKey[0]), C.GoString(&secretKey[0]), nil
+}
+
+// CurvePublicKey derives the public key from the secret one
+func CurvePublicKey(secretKey string) (string, error) {
+	if err := checkCurveKey(secretKey); err != nil {
+		return "", err
+	}
+
+	cSecretKey := C.CString(secretKey)
+	defer C.free(unsafe.Pointer(cSecretKey))
+
+	publicKey := make([]C.char, curveKeyLen+1)
+
+	rv, err := C.zmq_curve_public(&publicKey[0], cSecretKey)
+	if rv != 0 {
+		return "", fmt.Errorf("zmq_curve_public() failed: %v", err)
+	}
+
+	return C.GoString(&publicKey[0]), nil
+}
+
+// z85Encode encodes a binary key as received in ZAP requests
+func z85Encode(data []byte) (string, error) {
+	if (len(data) == 0) || (len(data)%4 != 0) {
+		return "", fmt.Errorf("data length must be a multiple of 4, got %d", len(data))
+	}
+
+	dest := make([]C.char, len(data)*5/4+1)
+
+	p := C.zmq_z85_encode(&dest[0], (*C.uint8_t)(unsafe.Pointer(&data[0])), C.size_t(len(data)))
+	if p == nil {
+		return "", fmt.Errorf("zmq_z85_encode() failed")
+	}
+
+	return C.GoString(&dest[0]), nil
+}
+
+func (sock *Socket) setCurveKeyOption(option C.int, key string) error {
+	if err := checkCurveKey(key); err != nil {
+		return err
+	}
+
+	k := []byte(key)
+	return sock.setOption(option, unsafe.Pointer(&k[0]), C.size_t(len(k)))
+}
+
+// SetCurveServer makes the socket act as the CURVE server,
+// the secret key must be set as well
+func (sock *Socket) SetCurveServer(value bool) error {
+	return sock.setBoolOption(C.ZMQ_CURVE_SERVER, value)
+}
+
+// long term public key of the socket (client only)
+func (sock *Socket) SetCurvePublicKey(key string) error {
+	return sock.setCurveKeyOption(C.ZMQ_CURVE_PUBLICKEY, key)
+}
+
+// long term secret key of the socket (both client and server)
+func (sock *Socket) SetCurveSecretKey(key string) error {
+	return sock.setCurveKeyOption(C.ZMQ_CURVE_SECRETKEY, key)
+}
+
+// public key of the server the client socket connects to
+func (sock *Socket) SetCurveServerKey(key string) error {
+	return sock.setCurveKeyOption(C.ZMQ_CURVE_SERVERKEY, key)
+}
+
+// SetZAPDomain sets the domain passed to the ZAP handler
+func (sock *Socket) SetZAPDomain(domain string) error {
+	d := []byte(domain)
+	if len(d) == 0 {
+		return sock.setOption(C.ZMQ_ZAP_DOMAIN, nil, 0)
+	}
+
+	return sock.setOption(C.ZMQ_ZAP_DOMAIN, unsafe.Pointer(&d[0]), C.size_t(len(d)))
+}
@@ -0,0 +1,44 @@
+//go:build zmq_purego || !cgo
+// +build zmq_purego !cgo
+
+package zmq
+
+// Only the NULL security mechanism is supported
+
+func NewCurveKeypair() (string, string, error) {
+	return "", "", ErrNotSupported
+}
+
+func CurvePublicKey(secretKey string) (string, error) {
+	return "", ErrNotSupported
+}
+
+func (sock *Socket) SetCurveServer(value bool) error {
+	return ErrNotSupported
+}
+
+func (sock *Socket) SetCurvePublicKey(key string) error {
+	return ErrNotSupported
+}
+
+func (sock *Socket) SetCurveSecretKey(key string) error {
+	return ErrNotSupported
+}
+
+func (sock *Socket) SetCurveServerKey(key string) error {
+	return ErrNotSupported
+}
+
+func (sock *Socket) SetZAPDomain(domain string) error {
+	return ErrNotSupported
+}
+
+type ZAPHandler struct{}
+
+func NewZAPHandler(ctx *Context, authorize ZAPAuthorizer) (*ZAPHandler, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *ZAPHandler) Close() error {
+	return ErrNotSupported
+}
@@ -0,0 +1,134 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package zmq
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func createKeypairOrFail(t *testing.T) (string, string) {
+	publicKey, secretKey, err := NewCurveKeypair()
+	if err != nil {
+		t.Fatalf("NewCurveKeypair() failed: %v", err)
+	}
+
+	return publicKey, secretKey
+}
+
+func TestCurveKeypair(t *testing.T) {
+	publicKey, secretKey := createKeypairOrFail(t)
+
+	if (len(publicKey) != curveKeyLen) || (len(secretKey) != curveKeyLen) {
+		t.Fatalf("Unexpected key lengths %d and %d", len(publicKey), len(secretKey))
+	}
+
+	derivedKey, err := CurvePublicKey(secretKey)
+	if err != nil {
+		t.Fatalf("CurvePublicKey() failed: %v", err)
+	}
+
+	if derivedKey != publicKey {
+		t.Fatalf("Derived public key '%s', expected '%s'", derivedKey, publicKey)
+	}
+
+	if _, err := CurvePublicKey("short"); (err == nil) || (!strings.Contains(err.Error(), "40")) {
+		t.Fatalf("Unexpected error from CurvePublicKey(): %v", err)
+	}
+}
+
+// sets up a CURVE PUSH server and a PULL client and checks if a message
+// goes through
+func curveExchange(t *testing.T, ctx *Context, endpoint string,
+	serverSecretKey, serverPublicKey, clientPublicKey, clientSecretKey string) bool {
+	server := createSocketOrFail(t, ctx, SocketPUSH)
+	defer closeSocketOrFail(t, server)
+
+	if err := server.SetCurveServer(true); err != nil {
+		t.Fatalf("SetCurveServer() failed: %v", err)
+	}
+
+	if err := server.SetCurveSecretKey(serverSecretKey); err != nil {
+		t.Fatalf("SetCurveSecretKey() failed: %v", err)
+	}
+
+	if err := server.SetLinger(0); err != nil {
+		t.Fatalf("SetLinger() failed: %v", err)
+	}
+
+	if err := server.Bind(endpoint); err != nil {
+		t.Fatalf("Bind() failed: %v", err)
+	}
+
+	client := createSocketOrFail(t, ctx, SocketPULL)
+	defer closeSocketOrFail(t, client)
+
+	if err := client.SetCurveServerKey(serverPublicKey); err != nil {
+		t.Fatalf("SetCurveServerKey() failed: %v", err)
+	}
+
+	if err := client.SetCurvePublicKey(clientPublicKey); err != nil {
+		t.Fatalf("SetCurvePublicKey() failed: %v", err)
+	}
+
+	if err := client.SetCurveSecretKey(clientSecretKey); err != nil {
+		t.Fatalf("SetCurveSecretKey() failed: %v", err)
+	}
+
+	if err := client.SetRcvTimeout(time.Second); err != nil {
+		t.Fatalf("SetRcvTimeout() failed: %v", err)
+	}
+
+	if err := client.Connect(endpoint); err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+
+	if err := server.SetSndTimeout(time.Second); err != nil {
+		t.Fatalf("SetSndTimeout() failed: %v", err)
+	}
+
+	// PUSH blocks until there is an authenticated peer
+	if err := server.Send([]byte("secret")); err != nil {
+		return false
+	}
+
+	data, err := client.RecvMsg()
+	return (err == nil) && (string(data) == "secret")
+}
+
+func TestCurveWithZAP(t *testing.T) {
+	serverPublicKey, serverSecretKey := createKeypairOrFail(t)
+	clientPublicKey, clientSecretKey := createKeypairOrFail(t)
+	otherPublicKey, otherSecretKey := createKeypairOrFail(t)
+
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	handler, err := NewZAPHandler(ctx, CurveAllowList(clientPublicKey))
+	if err != nil {
+		t.Fatalf("NewZAPHandler() failed: %v", err)
+	}
+	defer func() {
+		if err := handler.Close(); err != nil {
+			t.Fatalf("ZAPHandler Close() failed: %v", err)
+		}
+	}()
+
+	if !curveExchange(t, ctx, "tcp://127.0.0.1:5570", serverSecretKey, serverPublicKey,
+		clientPublicKey, clientSecretKey) {
+		t.Fatalf("The allowed client did not receive the message")
+	}
+
+	if curveExchange(t, ctx, "tcp://127.0.0.1:5571", serverSecretKey, serverPublicKey,
+		otherPublicKey, otherSecretKey) {
+		t.Fatalf("The not allowed client received the message")
+	}
+
+	// the client does not trust a server with a different key
+	if curveExchange(t, ctx, "tcp://127.0.0.1:5572", serverSecretKey, otherPublicKey,
+		clientPublicKey, clientSecretKey) {
+		t.Fatalf("The client received the message from an unexpected server")
+	}
+}
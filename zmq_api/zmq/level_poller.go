@@ -0,0 +1,362 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package zmq
+
+// #include <zmq.h>
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	PollIn  PollEvents = C.ZMQ_POLLIN
+	PollOut PollEvents = C.ZMQ_POLLOUT
+)
+
+// GetEvents returns the events the socket is ready for (ZMQ_EVENTS)
+func (sock *Socket) GetEvents() (PollEvents, error) {
+	if err := sock.lock(); err != nil {
+		return 0, err
+	}
+	defer sock.unlock()
+
+	bitmask, err := sock.updateEventsState()
+	if err != nil {
+		return 0, err
+	}
+
+	return PollEvents(bitmask) & (PollIn | PollOut), nil
+}
+
+type pollerEntry struct {
+	sock *Socket
+	// ZMQ_FD for sockets
+	fd     int
+	events PollEvents
+}
+
+// Poller is a level-triggered poller for ZMQ sockets and plain file
+// descriptors: a socket is reported as long as it is ready according
+// to ZMQ_EVENTS, no matter if its ZMQ_FD was signalled or not.
+//
+// Sockets and file descriptors may be added and removed at any time,
+// also while another goroutine is blocked in Poll(). A socket must be
+// removed before it is closed.
+type Poller struct {
+	entries []pollerEntry
+	mux     sync.Mutex
+	closed  bool
+
+	// a byte is written to the pipe to interrupt poll(), either to
+	// rebuild the poll list or for Wakeup(), which also sets the flag
+	wakeupReadFd  int
+	wakeupWriteFd int
+	wakeupFlag    int32
+}
+
+func NewPoller() (*Poller, error) {
+	pipeFds := make([]int, 2)
+	if err := unix.Pipe(pipeFds); err != nil {
+		return nil, fmt.Errorf("pipe() failed: %v", err)
+	}
+
+	p := Poller{wakeupReadFd: pipeFds[0], wakeupWriteFd: pipeFds[1]}
+
+	for _, fd := range pipeFds {
+		if err := unix.SetNonblock(fd, true); err != nil {
+			p.Close()
+			return nil, fmt.Errorf("SetNonblock() failed: %v", err)
+		}
+	}
+
+	return &p, nil
+}
+
+func (p *Poller) Close() error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.closed {
+		return ErrPollerClosed
+	}
+
+	p.closed = true
+
+	var err error
+
+	for _, fd := range []int{p.wakeupReadFd, p.wakeupWriteFd} {
+		if closeErr := unix.Close(fd); (err == nil) && (closeErr != nil) {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+func (p *Poller) add(entry pollerEntry) error {
+	if entry.events&^(PollIn|PollOut) != 0 {
+		return fmt.Errorf("unsupported events 0x%x", int(entry.events))
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	for i := range p.entries {
+		if (p.entries[i].sock == entry.sock) && (p.entries[i].fd == entry.fd) {
+			p.entries[i].events = entry.events
+			return p.writeWakeup()
+		}
+	}
+
+	p.entries = append(p.entries, entry)
+	return p.writeWakeup()
+}
+
+func (p *Poller) remove(sock *Socket, fd int) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	for i := range p.entries {
+		if (p.entries[i].sock == sock) && (p.entries[i].fd == fd) {
+			p.entries = append(p.entries[:i], p.entries[i+1:]...)
+			return p.writeWakeup()
+		}
+	}
+
+	return fmt.Errorf("not registered")
+}
+
+// AddSocket registers the socket or changes the events of
+// an already registered one
+func (p *Poller) AddSocket(sock *Socket, events PollEvents) error {
+	fd, err := sock.GetFd()
+	if err != nil {
+		return fmt.Errorf("GetFd() failed: %v", err)
+	}
+
+	return p.add(pollerEntry{sock: sock, fd: fd, events: events})
+}
+
+func (p *Poller) RemoveSocket(sock *Socket) error {
+	p.mux.Lock()
+	fd := -1
+	for _, entry := range p.entries {
+		if entry.sock == sock {
+			fd = entry.fd
+		}
+	}
+	p.mux.Unlock()
+
+	return p.remove(sock, fd)
+}
+
+// AddFd registers the file descriptor or changes the events of
+// an already registered one
+func (p *Poller) AddFd(fd int, events PollEvents) error {
+	return p.add(pollerEntry{fd: fd, events: events})
+}
+
+func (p *Poller) RemoveFd(fd int) error {
+	return p.remove(nil, fd)
+}
+
+func (p *Poller) writeWakeup() error {
+	_, err := unix.Write(p.wakeupWriteFd, []byte{0})
+
+	// the pipe is full, i.e. Poll() is already going to be woken up
+	if errors.Is(err, unix.EAGAIN) {
+		return nil
+	}
+
+	return err
+}
+
+// Wakeup makes the current (or the next) call to Poll() return
+// immediately. It is safe to call it from another goroutine.
+func (p *Poller) Wakeup() error {
+	// set before writing, so that Poll() sees it once it reads the byte
+	atomic.StoreInt32(&p.wakeupFlag, 1)
+
+	return p.writeWakeup()
+}
+
+// drainWakeups returns true if Wakeup() was called
+func (p *Poller) drainWakeups() (bool, error) {
+	buf := make([]byte, 64)
+
+	for {
+		_, err := unix.Read(p.wakeupReadFd, buf)
+		if errors.Is(err, unix.EAGAIN) {
+			break
+		}
+
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return atomic.SwapInt32(&p.wakeupFlag, 0) != 0, nil
+}
+
+func readySockets(entries []pollerEntry) ([]PollItem, error) {
+	ret := make([]PollItem, 0)
+
+	for _, entry := range entries {
+		if entry.sock == nil {
+			continue
+		}
+
+		events, err := entry.sock.GetEvents()
+
+		// the socket may be removed and closed by another goroutine
+		// after the entries were copied
+		if errors.Is(err, ErrSocketClosed) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("GetEvents(%v) failed: %v", entry.sock, err)
+		}
+
+		if events&entry.events != 0 {
+			ret = append(ret, PollItem{Socket: entry.sock, Fd: entry.fd,
+				Events: events & entry.events})
+		}
+	}
+
+	return ret, nil
+}
+
+func toPollFlags(events PollEvents) int16 {
+	var flags int16
+
+	if events&PollIn != 0 {
+		flags |= unix.POLLIN
+	}
+
+	if events&PollOut != 0 {
+		flags |= unix.POLLOUT
+	}
+
+	return flags
+}
+
+// Poll returns the ready sockets and file descriptors. It returns
+// an empty list if the timeout expires or Wakeup() is called.
+// A negative timeout means waiting infinitely.
+func (p *Poller) Poll(timeout time.Duration) ([]PollItem, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		p.mux.Lock()
+		entries := make([]pollerEntry, len(p.entries))
+		copy(entries, p.entries)
+		p.mux.Unlock()
+
+		ready, err := readySockets(entries)
+		if err != nil {
+			return nil, err
+		}
+		socketsReady := len(ready) != 0
+
+		timeoutMs := -1
+		if len(ready) != 0 {
+			timeoutMs = 0
+		} else if timeout >= 0 {
+			timeoutMs = int(time.Until(deadline).Milliseconds())
+			if timeoutMs < 0 {
+				timeoutMs = 0
+			}
+		}
+
+		pollFds := make([]unix.PollFd, 0, len(entries)+1)
+		for _, entry := range entries {
+			// ZMQ_FD only signals that ZMQ_EVENTS should be checked
+			flags := int16(unix.POLLIN)
+			if entry.sock == nil {
+				flags = toPollFlags(entry.events)
+			}
+
+			pollFds = append(pollFds, unix.PollFd{Fd: int32(entry.fd), Events: flags})
+		}
+		pollFds = append(pollFds, unix.PollFd{Fd: int32(p.wakeupReadFd), Events: unix.POLLIN})
+
+		for {
+			_, err = unix.Poll(pollFds, timeoutMs)
+			if !errors.Is(err, unix.EINTR) {
+				break
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("poll() failed with: %v", err)
+		}
+
+		woken := false
+		socketsSignalled := false
+
+		for i, pollFd := range pollFds {
+			if pollFd.Revents == 0 {
+				continue
+			}
+
+			if i == len(pollFds)-1 {
+				if woken, err = p.drainWakeups(); err != nil {
+					return nil, fmt.Errorf("drainWakeups() failed: %v", err)
+				}
+
+				continue
+			}
+
+			entry := entries[i]
+			if entry.sock != nil {
+				socketsSignalled = true
+				continue
+			}
+
+			var events PollEvents
+			// errors and hangups are reported as readiness, so that
+			// the caller gets them from the following read or write
+			if pollFd.Revents&(unix.POLLIN|unix.POLLERR|unix.POLLHUP) != 0 {
+				events |= PollIn
+			}
+
+			if pollFd.Revents&(unix.POLLOUT|unix.POLLERR|unix.POLLHUP) != 0 {
+				events |= PollOut
+			}
+
+			if events&entry.events != 0 {
+				ready = append(ready, PollItem{Fd: entry.fd, Events: events & entry.events})
+			}
+		}
+
+		if socketsSignalled && (!socketsReady) {
+			nowReady, err := readySockets(entries)
+			if err != nil {
+				return nil, err
+			}
+
+			ready = append(ready, nowReady...)
+		}
+
+		if len(ready) != 0 {
+			return ready, nil
+		}
+
+		if woken || ((timeout >= 0) && (!time.Now().Before(deadline))) {
+			return ready, nil
+		}
+	}
+}
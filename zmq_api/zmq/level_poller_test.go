@@ -0,0 +1,241 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package zmq
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func createPollerOrFail(t *testing.T) *Poller {
+	p, err := NewPoller()
+	if err != nil {
+		t.Fatalf("NewPoller() failed: %v", err)
+	}
+
+	return p
+}
+
+func pollOrFail(t *testing.T, p *Poller, timeout time.Duration) []PollItem {
+	items, err := p.Poll(timeout)
+	if err != nil {
+		t.Fatalf("Poll() failed: %v", err)
+	}
+
+	return items
+}
+
+func TestPollerLevelTriggered(t *testing.T) {
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	pull, push := createConnectedPairOrFail(t, ctx, "inproc://level-triggered", SocketPULL, SocketPUSH)
+	defer closeSocketOrFail(t, pull)
+	defer closeSocketOrFail(t, push)
+
+	p := createPollerOrFail(t)
+	defer p.Close()
+
+	if err := p.AddSocket(pull, PollIn); err != nil {
+		t.Fatalf("AddSocket() failed: %v", err)
+	}
+
+	sendOrFail(t, push, "first")
+	sendOrFail(t, push, "second")
+
+	// the socket is reported as long as there are messages
+	for _, expected := range []string{"first", "second"} {
+		items := pollOrFail(t, p, time.Second)
+		if (len(items) != 1) || (items[0].Socket != pull) || (items[0].Events != PollIn) {
+			t.Fatalf("Unexpected poll items %v", items)
+		}
+
+		expectFrames(t, recvOrFail(t, pull), expected)
+	}
+
+	if items := pollOrFail(t, p, 100*time.Millisecond); len(items) != 0 {
+		t.Fatalf("Unexpected poll items %v", items)
+	}
+}
+
+func TestPollerPollOut(t *testing.T) {
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	pull, push := createConnectedPairOrFail(t, ctx, "inproc://poll-out", SocketPULL, SocketPUSH)
+	defer closeSocketOrFail(t, pull)
+	defer closeSocketOrFail(t, push)
+
+	p := createPollerOrFail(t)
+	defer p.Close()
+
+	if err := p.AddSocket(push, PollIn|PollOut); err != nil {
+		t.Fatalf("AddSocket() failed: %v", err)
+	}
+
+	items := pollOrFail(t, p, time.Second)
+	if (len(items) != 1) || (items[0].Socket != push) || (items[0].Events != PollOut) {
+		t.Fatalf("Unexpected poll items %v", items)
+	}
+}
+
+func TestPollerFd(t *testing.T) {
+	pipeFds := make([]int, 2)
+	if err := unix.Pipe(pipeFds); err != nil {
+		t.Fatalf("pipe() failed: %v", err)
+	}
+	defer unix.Close(pipeFds[0])
+	defer unix.Close(pipeFds[1])
+
+	p := createPollerOrFail(t)
+	defer p.Close()
+
+	if err := p.AddFd(pipeFds[0], PollIn); err != nil {
+		t.Fatalf("AddFd() failed: %v", err)
+	}
+
+	if items := pollOrFail(t, p, 50*time.Millisecond); len(items) != 0 {
+		t.Fatalf("Unexpected poll items %v", items)
+	}
+
+	if _, err := unix.Write(pipeFds[1], []byte{1}); err != nil {
+		t.Fatalf("write() failed: %v", err)
+	}
+
+	// still ready as nothing was read
+	for i := 0; i < 2; i++ {
+		items := pollOrFail(t, p, time.Second)
+		if (len(items) != 1) || (items[0].Socket != nil) || (items[0].Fd != pipeFds[0]) ||
+			(items[0].Events != PollIn) {
+			t.Fatalf("Unexpected poll items %v", items)
+		}
+	}
+
+	if err := p.RemoveFd(pipeFds[0]); err != nil {
+		t.Fatalf("RemoveFd() failed: %v", err)
+	}
+
+	if items := pollOrFail(t, p, 50*time.Millisecond); len(items) != 0 {
+		t.Fatalf("Unexpected poll items after RemoveFd() %v", items)
+	}
+
+	if err := p.RemoveFd(pipeFds[0]); err == nil {
+		t.Fatalf("RemoveFd() did not fail for a not registered fd")
+	}
+
+	if err := p.AddFd(pipeFds[0], PollEvents(0x100)); err == nil {
+		t.Fatalf("AddFd() did not fail for unsupported events")
+	}
+}
+
+func TestPollerAddWhilePolling(t *testing.T) {
+	pipeFds := make([]int, 2)
+	if err := unix.Pipe(pipeFds); err != nil {
+		t.Fatalf("pipe() failed: %v", err)
+	}
+	defer unix.Close(pipeFds[0])
+	defer unix.Close(pipeFds[1])
+
+	if _, err := unix.Write(pipeFds[1], []byte{1}); err != nil {
+		t.Fatalf("write() failed: %v", err)
+	}
+
+	p := createPollerOrFail(t)
+	defer p.Close()
+
+	itemsChan := make(chan []PollItem)
+	go func() {
+		items, err := p.Poll(-1)
+		if err != nil {
+			t.Errorf("Poll() failed: %v", err)
+		}
+
+		itemsChan <- items
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	// the blocked Poll() must pick up the new, already ready, fd
+	if err := p.AddFd(pipeFds[0], PollIn); err != nil {
+		t.Fatalf("AddFd() failed: %v", err)
+	}
+
+	select {
+	case items := <-itemsChan:
+		if (len(items) != 1) || (items[0].Fd != pipeFds[0]) {
+			t.Fatalf("Unexpected poll items %v", items)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Poll() did not return")
+	}
+
+	if err := p.RemoveFd(pipeFds[0]); err != nil {
+		t.Fatalf("RemoveFd() failed: %v", err)
+	}
+
+	go func() {
+		items, _ := p.Poll(-1)
+		itemsChan <- items
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	if err := p.Wakeup(); err != nil {
+		t.Fatalf("Wakeup() failed: %v", err)
+	}
+
+	select {
+	case items := <-itemsChan:
+		if len(items) != 0 {
+			t.Fatalf("Unexpected poll items %v", items)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Poll() did not return after Wakeup()")
+	}
+}
+
+func TestPollerWakeupFullPipe(t *testing.T) {
+	p := createPollerOrFail(t)
+	defer p.Close()
+
+	// fill the wakeup pipe with rebuild requests
+	for {
+		_, err := unix.Write(p.wakeupWriteFd, []byte{0})
+		if err == unix.EAGAIN {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("write() failed: %v", err)
+		}
+	}
+
+	if err := p.Wakeup(); err != nil {
+		t.Fatalf("Wakeup() failed: %v", err)
+	}
+
+	start := time.Now()
+	if items := pollOrFail(t, p, 5*time.Second); len(items) != 0 {
+		t.Fatalf("Unexpected poll items %v", items)
+	}
+
+	if time.Since(start) > time.Second {
+		t.Fatalf("Wakeup() on a full pipe was lost")
+	}
+}
+
+func TestPollerCloseTwice(t *testing.T) {
+	p := createPollerOrFail(t)
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	if err := p.Close(); !errors.Is(err, ErrPollerClosed) {
+		t.Fatalf("Close() of a closed poller returned '%v'", err)
+	}
+}
@@ -0,0 +1,266 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package zmq
+
+// #include <zmq.h>
+// #include <stdlib.h>
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+var ErrMessageClosed = errors.New("message is closed")
+
+// Message wraps zmq_msg_t, so the data is received, sent and forwarded
+// without being copied to and from Go memory. A Message must not be used
+// from several goroutines at once. Messages not closed explicitly are
+// closed by a finalizer, see Data() for what it means for the callers.
+type Message struct {
+	// allocated in C memory, as the data of small messages is stored
+	// inside zmq_msg_t itself, and Data() points to it
+	msg *C.zmq_msg_t
+}
+
+func newMessage(init func(msg *C.zmq_msg_t) (C.int, error)) (*Message, error) {
+	msg := (*C.zmq_msg_t)(C.malloc(C.sizeof_zmq_msg_t))
+	if msg == nil {
+		return nil, fmt.Errorf("malloc() failed")
+	}
+
+	if rv, err := init(msg); rv != 0 {
+		C.free(unsafe.Pointer(msg))
+		return nil, err
+	}
+
+	m := &Message{msg: msg}
+	runtime.SetFinalizer(m, (*Message).Close)
+
+	return m, nil
+}
+
+// NewMessage creates an empty message, e.g. to receive into
+func NewMessage() (*Message, error) {
+	return newMessage(func(msg *C.zmq_msg_t) (C.int, error) {
+		rv, err := C.zmq_msg_init(msg)
+		return rv, err
+	})
+}
+
+// NewMessageSize creates a message of the given size, the data is
+// to be filled via Data()
+func NewMessageSize(size int) (*Message, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid message size %d", size)
+	}
+
+	return newMessage(func(msg *C.zmq_msg_t) (C.int, error) {
+		rv, err := C.zmq_msg_init_size(msg, C.size_t(size))
+		return rv, err
+	})
+}
+
+// NewMessageData creates a message with a copy of the data
+func NewMessageData(data []byte) (*Message, error) {
+	m, err := NewMessageSize(len(data))
+	if err != nil {
+		return nil, err
+	}
+
+	copy(m.Data(), data)
+	runtime.KeepAlive(m)
+
+	return m, nil
+}
+
+func (m *Message) Close() error {
+	if m.msg == nil {
+		return ErrMessageClosed
+	}
+
+	rv, err := C.zmq_msg_close(m.msg)
+	if rv != 0 {
+		return err
+	}
+
+	C.free(unsafe.Pointer(m.msg))
+	m.msg = nil
+	runtime.SetFinalizer(m, nil)
+
+	return nil
+}
+
+// Data returns the message content without copying it. The slice is
+// valid only until the message is closed, sent or received into again.
+// It points to C memory and does not keep the message alive: the caller
+// must keep using m (or call runtime.KeepAlive(m)) after the last use of
+// the slice, otherwise the finalizer may free the content under it.
+func (m *Message) Data() []byte {
+	if m.msg == nil {
+		return nil
+	}
+
+	size := int(C.zmq_msg_size(m.msg))
+	if size == 0 {
+		return []byte{}
+	}
+
+	p := C.zmq_msg_data(m.msg)
+	return (*[1 << 30]byte)(p)[:size:size]
+}
+
+func (m *Message) Size() int {
+	if m.msg == nil {
+		return 0
+	}
+
+	return int(C.zmq_msg_size(m.msg))
+}
+
+// More tells if more frames of a multipart message follow
+// (for received messages only)
+func (m *Message) More() bool {
+	if m.msg == nil {
+		return false
+	}
+
+	return C.zmq_msg_more(m.msg) != 0
+}
+
+// Copy makes the message share the content of src. The content is not
+// copied, but reference counted by ZMQ, so it must not be modified.
+func (m *Message) Copy(src *Message) error {
+	if (m.msg == nil) || (src.msg == nil) {
+		return ErrMessageClosed
+	}
+
+	rv, err := C.zmq_msg_copy(m.msg, src.msg)
+	if rv != 0 {
+		return fmt.Errorf("zmq_msg_copy() failed: %v", err)
+	}
+
+	return nil
+}
+
+// Property returns a metadata property of a received message,
+// e.g. "Socket-Type", "Routing-Id", "Peer-Address" or "User-Id"
+func (m *Message) Property(name string) (string, error) {
+	if m.msg == nil {
+		return "", ErrMessageClosed
+	}
+
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	value, err := C.zmq_msg_gets(m.msg, cName)
+	if value == nil {
+		return "", fmt.Errorf("zmq_msg_gets('%s') failed: %v", name, err)
+	}
+
+	return C.GoString(value), nil
+}
+
+// RoutingId returns the routing id the peer set for its socket
+func (m *Message) RoutingId() (string, error) {
+	return m.Property("Routing-Id")
+}
+
+// PeerAddress returns the IP address of the peer (TCP only)
+func (m *Message) PeerAddress() (string, error) {
+	return m.Property("Peer-Address")
+}
+
+func (sock *Socket) doRecvMessage(m *Message, flags C.int) error {
+	if m.msg == nil {
+		return ErrMessageClosed
+	}
+
+	if err := sock.lock(); err != nil {
+		return err
+	}
+	defer sock.unlock()
+
+	var err error
+	var rv C.int
+
+	for {
+		rv, err = C.zmq_msg_recv(m.msg, sock.sock, flags)
+
+		if (rv != -1) || (!errors.Is(err, unix.EINTR)) {
+			break
+		}
+	}
+
+	if rv == -1 {
+		return err
+	}
+
+	_, err = sock.updateEventsState()
+	if err != nil {
+		return fmt.Errorf("updateEventsStatus(): %v", err)
+	}
+
+	return nil
+}
+
+// RecvMessage receives a frame into the message, replacing its content
+func (sock *Socket) RecvMessage(m *Message) error {
+	return sock.doRecvMessage(m, 0)
+}
+
+func (sock *Socket) RecvMessageNonBlocking(m *Message) (error, bool) {
+	err := sock.doRecvMessage(m, C.ZMQ_DONTWAIT)
+
+	if errors.Is(err, unix.EAGAIN) {
+		return nil, false
+	}
+
+	return err, true
+}
+
+// SendMessage sends the message, which becomes empty on success.
+// If more is set, the next message is sent as the next frame of
+// the same multipart message.
+func (sock *Socket) SendMessage(m *Message, more bool) error {
+	if m.msg == nil {
+		return ErrMessageClosed
+	}
+
+	var flags C.int
+	if more {
+		flags = C.ZMQ_SNDMORE
+	}
+
+	if err := sock.lock(); err != nil {
+		return err
+	}
+	defer sock.unlock()
+
+	var err error
+	var rv C.int
+
+	for {
+		rv, err = C.zmq_msg_send(m.msg, sock.sock, flags)
+
+		if (rv != -1) || (!errors.Is(err, unix.EINTR)) {
+			break
+		}
+	}
+
+	if rv == -1 {
+		return err
+	}
+
+	_, err = sock.updateEventsState()
+	if err != nil {
+		return fmt.Errorf("updateEventsStatus(): %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package zmq
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func createMessageOrFail(t *testing.T, data string) *Message {
+	m, err := NewMessageData([]byte(data))
+	if err != nil {
+		t.Fatalf("NewMessageData() failed: %v", err)
+	}
+
+	return m
+}
+
+func closeMessageOrFail(t *testing.T, m *Message) {
+	if err := m.Close(); err != nil {
+		t.Fatalf("Message Close() failed: %v", err)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	m := createMessageOrFail(t, "some data")
+
+	if (string(m.Data()) != "some data") || (m.Size() != 9) {
+		t.Fatalf("Unexpected message content '%s' of size %d", string(m.Data()), m.Size())
+	}
+
+	// Data() gives access to the message memory itself
+	m.Data()[0] = 'S'
+	if string(m.Data()) != "Some data" {
+		t.Fatalf("Unexpected message content '%s'", string(m.Data()))
+	}
+
+	copied, err := NewMessage()
+	if err != nil {
+		t.Fatalf("NewMessage() failed: %v", err)
+	}
+
+	if err := copied.Copy(m); err != nil {
+		t.Fatalf("Copy() failed: %v", err)
+	}
+
+	closeMessageOrFail(t, m)
+
+	if string(copied.Data()) != "Some data" {
+		t.Fatalf("Unexpected content of the copy '%s'", string(copied.Data()))
+	}
+
+	closeMessageOrFail(t, copied)
+
+	if err := m.Close(); !errors.Is(err, ErrMessageClosed) {
+		t.Fatalf("Close() of a closed message returned '%v'", err)
+	}
+
+	if (m.Data() != nil) || (m.Size() != 0) {
+		t.Fatalf("A closed message has data")
+	}
+
+	if _, err := NewMessageSize(-1); err == nil {
+		t.Fatalf("NewMessageSize() did not fail for a negative size")
+	}
+}
+
+func TestSendRecvMessage(t *testing.T) {
+	const endpoint = "tcp://127.0.0.1:5595"
+
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	pull := createSocketOrFail(t, ctx, SocketPULL)
+	defer closeSocketOrFail(t, pull)
+
+	if err := pull.Bind(endpoint); err != nil {
+		t.Fatalf("Bind() failed: %v", err)
+	}
+
+	push := createSocketOrFail(t, ctx, SocketPUSH)
+	defer closeSocketOrFail(t, push)
+
+	if err := push.SetRoutingId([]byte("sender")); err != nil {
+		t.Fatalf("SetRoutingId() failed: %v", err)
+	}
+
+	if err := push.Connect(endpoint); err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+
+	for i, data := range []string{"first", "second"} {
+		m := createMessageOrFail(t, data)
+
+		if err := push.SendMessage(m, i == 0); err != nil {
+			t.Fatalf("SendMessage() failed: %v", err)
+		}
+
+		if m.Size() != 0 {
+			t.Fatalf("The sent message is not empty")
+		}
+
+		closeMessageOrFail(t, m)
+	}
+
+	if err := pull.SetRcvTimeout(5 * time.Second); err != nil {
+		t.Fatalf("SetRcvTimeout() failed: %v", err)
+	}
+
+	m, err := NewMessage()
+	if err != nil {
+		t.Fatalf("NewMessage() failed: %v", err)
+	}
+	defer closeMessageOrFail(t, m)
+
+	for i, expected := range []string{"first", "second"} {
+		if err := pull.RecvMessage(m); err != nil {
+			t.Fatalf("RecvMessage() failed: %v", err)
+		}
+
+		if (string(m.Data()) != expected) || (m.More() != (i == 0)) {
+			t.Fatalf("Received '%s' (more %v), expected '%s'", string(m.Data()), m.More(), expected)
+		}
+	}
+
+	if id, err := m.RoutingId(); (err != nil) || (id != "sender") {
+		t.Fatalf("RoutingId() returned '%s' (%v), expected 'sender'", id, err)
+	}
+
+	if addr, err := m.PeerAddress(); (err != nil) || (addr != "127.0.0.1") {
+		t.Fatalf("PeerAddress() returned '%s' (%v), expected '127.0.0.1'", addr, err)
+	}
+
+	if err, received := pull.RecvMessageNonBlocking(m); (err != nil) || received {
+		t.Fatalf("RecvMessageNonBlocking() on an empty socket returned %v, %v", err, received)
+	}
+}
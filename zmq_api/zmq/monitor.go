@@ -0,0 +1,200 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package zmq
+
+// #include <zmq.h>
+// #include <stdlib.h>
+import "C"
+
+import (
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+)
+
+const (
+	EventConnected               EventType = C.ZMQ_EVENT_CONNECTED
+	EventConnectDelayed          EventType = C.ZMQ_EVENT_CONNECT_DELAYED
+	EventConnectRetried          EventType = C.ZMQ_EVENT_CONNECT_RETRIED
+	EventListening               EventType = C.ZMQ_EVENT_LISTENING
+	EventBindFailed              EventType = C.ZMQ_EVENT_BIND_FAILED
+	EventAccepted                EventType = C.ZMQ_EVENT_ACCEPTED
+	EventAcceptFailed            EventType = C.ZMQ_EVENT_ACCEPT_FAILED
+	EventClosed                  EventType = C.ZMQ_EVENT_CLOSED
+	EventCloseFailed             EventType = C.ZMQ_EVENT_CLOSE_FAILED
+	EventDisconnected            EventType = C.ZMQ_EVENT_DISCONNECTED
+	EventMonitorStopped          EventType = C.ZMQ_EVENT_MONITOR_STOPPED
+	EventHandshakeFailedNoDetail EventType = C.ZMQ_EVENT_HANDSHAKE_FAILED_NO_DETAIL
+	EventHandshakeSucceeded      EventType = C.ZMQ_EVENT_HANDSHAKE_SUCCEEDED
+	EventHandshakeFailedProtocol EventType = C.ZMQ_EVENT_HANDSHAKE_FAILED_PROTOCOL
+	EventHandshakeFailedAuth     EventType = C.ZMQ_EVENT_HANDSHAKE_FAILED_AUTH
+
+	EventAll EventType = C.ZMQ_EVENT_ALL
+)
+
+// the first frame of an event is the 16 bit event type followed by
+// the 32 bit value, both in the host byte order
+const eventFrameLen = 6
+
+func parseEvent(frames [][]byte) (Event, error) {
+	if (len(frames) != 2) || (len(frames[0]) != eventFrameLen) {
+		return Event{}, fmt.Errorf("malformed event")
+	}
+
+	var tp uint16
+	var value uint32
+
+	copy((*[2]byte)(unsafe.Pointer(&tp))[:], frames[0][0:2])
+	copy((*[4]byte)(unsafe.Pointer(&value))[:], frames[0][2:6])
+
+	return Event{Type: EventType(tp), Value: int(int32(value)), Endpoint: string(frames[1])}, nil
+}
+
+// the size of the events channel, events are dropped if it is full
+const monitorEventsBuffer = 64
+
+var monitorCounter uint64
+
+// Monitor delivers events of a socket. It must be closed before the socket.
+type Monitor struct {
+	monitored *Socket
+	sock      *Socket
+	poller    *ReadPoller
+
+	events chan Event
+
+	stopFlag int32
+	doneChan chan struct{}
+	err      error
+}
+
+// NewMonitor starts monitoring the socket for the given events,
+// e.g. EventConnected|EventDisconnected or EventAll
+func NewMonitor(ctx *Context, sock *Socket, events EventType) (*Monitor, error) {
+	m := Monitor{monitored: sock,
+		events:   make(chan Event, monitorEventsBuffer),
+		doneChan: make(chan struct{})}
+
+	endpoint := fmt.Sprintf("inproc://monitor-%p-%d", sock.sock,
+		atomic.AddUint64(&monitorCounter, 1))
+
+	cEndpoint := C.CString(endpoint)
+	defer C.free(unsafe.Pointer(cEndpoint))
+
+	if err := sock.lock(); err != nil {
+		return nil, err
+	}
+
+	rv, err := C.zmq_socket_monitor(sock.sock, cEndpoint, C.int(events))
+	sock.unlock()
+
+	if rv != 0 {
+		return nil, fmt.Errorf("zmq_socket_monitor() failed: %v", err)
+	}
+
+	m.sock, err = NewSocket(ctx, SocketPAIR)
+	if err != nil {
+		m.stopMonitoring()
+		return nil, fmt.Errorf("NewSocket() failed: %v", err)
+	}
+
+	if err = m.sock.Connect(endpoint); err != nil {
+		m.stopMonitoring()
+		m.sock.Close()
+		return nil, fmt.Errorf("Connect('%s') failed: %v", endpoint, err)
+	}
+
+	m.poller, err = NewReadPoller(m.sock)
+	if err != nil {
+		m.stopMonitoring()
+		m.sock.Close()
+		return nil, fmt.Errorf("NewReadPoller() failed: %v", err)
+	}
+
+	go m.run()
+
+	return &m, nil
+}
+
+func (m *Monitor) stopMonitoring() error {
+	if err := m.monitored.lock(); err != nil {
+		return err
+	}
+	defer m.monitored.unlock()
+
+	rv, err := C.zmq_socket_monitor(m.monitored.sock, nil, 0)
+	if rv != 0 {
+		return fmt.Errorf("zmq_socket_monitor() failed: %v", err)
+	}
+
+	return nil
+}
+
+// Events returns the channel the events are delivered to,
+// it is closed when the monitor is closed
+func (m *Monitor) Events() <-chan Event {
+	return m.events
+}
+
+func (m *Monitor) run() {
+	defer close(m.doneChan)
+	defer close(m.events)
+
+	defer func() {
+		if err := m.sock.Close(); (err != nil) && (m.err == nil) {
+			m.err = fmt.Errorf("socket Close() failed: %v", err)
+		}
+	}()
+
+	for atomic.LoadInt32(&m.stopFlag) == 0 {
+		for {
+			frames, err, received := m.sock.RecvMultipartNonBlocking()
+			if err != nil {
+				m.err = fmt.Errorf("RecvMultipartNonBlocking() failed: %v", err)
+				return
+			}
+
+			if !received {
+				break
+			}
+
+			event, err := parseEvent(frames)
+			if err != nil {
+				continue
+			}
+
+			select {
+			case m.events <- event:
+			default:
+			}
+		}
+
+		if _, err := m.poller.Poll(-1); err != nil {
+			m.err = fmt.Errorf("Poll() failed: %v", err)
+			return
+		}
+	}
+}
+
+func (m *Monitor) Close() error {
+	err := m.stopMonitoring()
+
+	atomic.StoreInt32(&m.stopFlag, 1)
+
+	if wakeupErr := m.poller.Wakeup(); wakeupErr != nil {
+		return fmt.Errorf("Wakeup() failed: %v", wakeupErr)
+	}
+
+	<-m.doneChan
+
+	if closeErr := m.poller.Close(); (err == nil) && (closeErr != nil) {
+		err = fmt.Errorf("poller Close() failed: %v", closeErr)
+	}
+
+	if err == nil {
+		err = m.err
+	}
+
+	return err
+}
@@ -0,0 +1,91 @@
+//go:build zmq_purego || !cgo
+// +build zmq_purego !cgo
+
+package zmq
+
+import (
+	"sync"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api/zmtp"
+)
+
+// the libzmq values, the pure Go sockets do not report
+// the failures other than the handshake ones
+const (
+	EventConnected               EventType = 0x0001
+	EventConnectDelayed          EventType = 0x0002
+	EventConnectRetried          EventType = 0x0004
+	EventListening               EventType = 0x0008
+	EventBindFailed              EventType = 0x0010
+	EventAccepted                EventType = 0x0020
+	EventAcceptFailed            EventType = 0x0040
+	EventClosed                  EventType = 0x0080
+	EventCloseFailed             EventType = 0x0100
+	EventDisconnected            EventType = 0x0200
+	EventMonitorStopped          EventType = 0x0400
+	EventHandshakeFailedNoDetail EventType = 0x0800
+	EventHandshakeSucceeded      EventType = 0x1000
+	EventHandshakeFailedProtocol EventType = 0x2000
+	EventHandshakeFailedAuth     EventType = 0x4000
+
+	EventAll EventType = 0xffff
+)
+
+var zmtpEventTypes = map[zmtp.EventType]EventType{
+	zmtp.EventConnected:          EventConnected,
+	zmtp.EventConnectDelayed:     EventConnectDelayed,
+	zmtp.EventConnectRetried:     EventConnectRetried,
+	zmtp.EventListening:          EventListening,
+	zmtp.EventAccepted:           EventAccepted,
+	zmtp.EventDisconnected:       EventDisconnected,
+	zmtp.EventHandshakeSucceeded: EventHandshakeSucceeded,
+	zmtp.EventHandshakeFailed:    EventHandshakeFailedProtocol,
+}
+
+// the size of the events channel, events are dropped if it is full
+const monitorEventsBuffer = 64
+
+// Monitor delivers events of a socket. It must be closed before the socket,
+// and there may be only one monitor per socket.
+type Monitor struct {
+	monitored *Socket
+	events    chan Event
+
+	closeOnce sync.Once
+}
+
+// NewMonitor starts monitoring the socket for the given events,
+// e.g. EventConnected|EventDisconnected or EventAll
+func NewMonitor(ctx *Context, sock *Socket, events EventType) (*Monitor, error) {
+	m := Monitor{monitored: sock, events: make(chan Event, monitorEventsBuffer)}
+
+	sock.sock.SetEventHandler(func(e zmtp.Event) {
+		tp := zmtpEventTypes[e.Type]
+		if tp&events == 0 {
+			return
+		}
+
+		select {
+		case m.events <- Event{Type: tp, Value: e.Value, Endpoint: e.Endpoint}:
+		default:
+		}
+	})
+
+	return &m, nil
+}
+
+// Events returns the channel the events are delivered to,
+// it is closed when the monitor is closed
+func (m *Monitor) Events() <-chan Event {
+	return m.events
+}
+
+func (m *Monitor) Close() error {
+	m.closeOnce.Do(func() {
+		// no events are delivered after it returns
+		m.monitored.sock.SetEventHandler(nil)
+		close(m.events)
+	})
+
+	return nil
+}
@@ -0,0 +1,99 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package zmq
+
+import (
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestParseEvent(t *testing.T) {
+	tp := uint16(EventConnectRetried)
+	value := uint32(100)
+
+	frame := make([]byte, eventFrameLen)
+	copy(frame[0:2], (*[2]byte)(unsafe.Pointer(&tp))[:])
+	copy(frame[2:6], (*[4]byte)(unsafe.Pointer(&value))[:])
+
+	event, err := parseEvent([][]byte{frame, []byte("tcp://127.0.0.1:5580")})
+	if err != nil {
+		t.Fatalf("parseEvent() failed: %v", err)
+	}
+
+	expected := Event{Type: EventConnectRetried, Value: 100, Endpoint: "tcp://127.0.0.1:5580"}
+	if event != expected {
+		t.Fatalf("Got %#v, expected %#v", event, expected)
+	}
+
+	if event.String() != "tcp://127.0.0.1:5580: connect retried" {
+		t.Fatalf("Unexpected String(): %s", event.String())
+	}
+
+	if _, err := parseEvent([][]byte{frame}); err == nil {
+		t.Fatalf("parseEvent() did not fail for a malformed event")
+	}
+}
+
+func waitForEventOrFail(t *testing.T, m *Monitor, tp EventType) Event {
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case event, ok := <-m.Events():
+			if !ok {
+				t.Fatalf("The events channel was closed")
+			}
+
+			if event.Type == tp {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("No '%s' event received", tp)
+		}
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	const endpoint = "tcp://127.0.0.1:5580"
+
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	sub := createSocketOrFail(t, ctx, SocketSUB)
+	defer closeSocketOrFail(t, sub)
+
+	m, err := NewMonitor(ctx, sub, EventConnected|EventDisconnected)
+	if err != nil {
+		t.Fatalf("NewMonitor() failed: %v", err)
+	}
+	defer func() {
+		if m != nil {
+			m.Close()
+		}
+	}()
+
+	pub := createSocketOrFail(t, ctx, SocketPUB)
+	if err := pub.Bind(endpoint); err != nil {
+		t.Fatalf("Bind() failed: %v", err)
+	}
+
+	if err := sub.Connect(endpoint); err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+
+	event := waitForEventOrFail(t, m, EventConnected)
+	if event.Endpoint != endpoint {
+		t.Fatalf("Got endpoint '%s', expected '%s'", event.Endpoint, endpoint)
+	}
+
+	closeSocketOrFail(t, pub)
+	waitForEventOrFail(t, m, EventDisconnected)
+
+	err = m.Close()
+	m = nil
+	if err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+}
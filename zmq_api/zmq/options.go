@@ -0,0 +1,322 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package zmq
+
+// #include <zmq.h>
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func (sock *Socket) setOption(option C.int, p unsafe.Pointer, l C.size_t) error {
+	if err := sock.lock(); err != nil {
+		return err
+	}
+	defer sock.unlock()
+
+	var err error
+	var rv C.int
+
+	for {
+		rv, err = C.zmq_setsockopt(sock.sock, option, p, l)
+
+		if (rv == 0) || (!errors.Is(err, unix.EINTR)) {
+			break
+		}
+	}
+
+	if rv != 0 {
+		return err
+	}
+
+	return nil
+}
+
+func (sock *Socket) getOption(option C.int, p unsafe.Pointer, l *C.size_t) error {
+	if err := sock.lock(); err != nil {
+		return err
+	}
+	defer sock.unlock()
+
+	var err error
+	var rv C.int
+
+	for {
+		rv, err = C.zmq_getsockopt(sock.sock, option, p, l)
+
+		if (rv == 0) || (!errors.Is(err, unix.EINTR)) {
+			break
+		}
+	}
+
+	if rv != 0 {
+		return err
+	}
+
+	return nil
+}
+
+func (sock *Socket) setIntOption(option C.int, value int) error {
+	v := C.int(value)
+	return sock.setOption(option, unsafe.Pointer(&v), C.size_t(unsafe.Sizeof(v)))
+}
+
+func (sock *Socket) getIntOption(option C.int) (int, error) {
+	var v C.int
+	l := C.size_t(unsafe.Sizeof(v))
+
+	if err := sock.getOption(option, unsafe.Pointer(&v), &l); err != nil {
+		return 0, err
+	}
+
+	return int(v), nil
+}
+
+func (sock *Socket) setInt64Option(option C.int, value int64) error {
+	v := C.int64_t(value)
+	return sock.setOption(option, unsafe.Pointer(&v), C.size_t(unsafe.Sizeof(v)))
+}
+
+func (sock *Socket) getInt64Option(option C.int) (int64, error) {
+	var v C.int64_t
+	l := C.size_t(unsafe.Sizeof(v))
+
+	if err := sock.getOption(option, unsafe.Pointer(&v), &l); err != nil {
+		return 0, err
+	}
+
+	return int64(v), nil
+}
+
+func (sock *Socket) setBoolOption(option C.int, value bool) error {
+	v := 0
+	if value {
+		v = 1
+	}
+
+	return sock.setIntOption(option, v)
+}
+
+func (sock *Socket) getBoolOption(option C.int) (bool, error) {
+	v, err := sock.getIntOption(option)
+	return v != 0, err
+}
+
+// for options in milliseconds where -1 means 'infinite'
+func (sock *Socket) setMillisecondsOption(option C.int, value time.Duration) error {
+	if value < 0 {
+		return sock.setIntOption(option, -1)
+	}
+
+	return sock.setIntOption(option, int(value.Milliseconds()))
+}
+
+func (sock *Socket) getMillisecondsOption(option C.int) (time.Duration, error) {
+	v, err := sock.getIntOption(option)
+	if err != nil {
+		return 0, err
+	}
+
+	if v < 0 {
+		return -1, nil
+	}
+
+	return time.Duration(v) * time.Millisecond, nil
+}
+
+// for options in seconds where -1 means 'the OS default'
+func (sock *Socket) setSecondsOption(option C.int, value time.Duration) error {
+	if value < 0 {
+		return sock.setIntOption(option, -1)
+	}
+
+	return sock.setIntOption(option, int(value/time.Second))
+}
+
+func (sock *Socket) getSecondsOption(option C.int) (time.Duration, error) {
+	v, err := sock.getIntOption(option)
+	if err != nil {
+		return 0, err
+	}
+
+	if v < 0 {
+		return -1, nil
+	}
+
+	return time.Duration(v) * time.Second, nil
+}
+
+// High water marks, 0 means 'no limit'
+
+func (sock *Socket) SetSndHWM(value int) error {
+	return sock.setIntOption(C.ZMQ_SNDHWM, value)
+}
+
+func (sock *Socket) GetSndHWM() (int, error) {
+	return sock.getIntOption(C.ZMQ_SNDHWM)
+}
+
+func (sock *Socket) SetRcvHWM(value int) error {
+	return sock.setIntOption(C.ZMQ_RCVHWM, value)
+}
+
+func (sock *Socket) GetRcvHWM() (int, error) {
+	return sock.getIntOption(C.ZMQ_RCVHWM)
+}
+
+// Maximum inbound message size in bytes, -1 means 'no limit'; a peer
+// sending a larger message is disconnected
+
+func (sock *Socket) SetMaxMsgSize(value int64) error {
+	return sock.setInt64Option(C.ZMQ_MAXMSGSIZE, value)
+}
+
+func (sock *Socket) GetMaxMsgSize() (int64, error) {
+	return sock.getInt64Option(C.ZMQ_MAXMSGSIZE)
+}
+
+// Timeouts and intervals, a negative value means 'infinite'
+
+func (sock *Socket) SetLinger(value time.Duration) error {
+	return sock.setMillisecondsOption(C.ZMQ_LINGER, value)
+}
+
+func (sock *Socket) GetLinger() (time.Duration, error) {
+	return sock.getMillisecondsOption(C.ZMQ_LINGER)
+}
+
+func (sock *Socket) SetRcvTimeout(value time.Duration) error {
+	return sock.setMillisecondsOption(C.ZMQ_RCVTIMEO, value)
+}
+
+func (sock *Socket) GetRcvTimeout() (time.Duration, error) {
+	return sock.getMillisecondsOption(C.ZMQ_RCVTIMEO)
+}
+
+func (sock *Socket) SetSndTimeout(value time.Duration) error {
+	return sock.setMillisecondsOption(C.ZMQ_SNDTIMEO, value)
+}
+
+func (sock *Socket) GetSndTimeout() (time.Duration, error) {
+	return sock.getMillisecondsOption(C.ZMQ_SNDTIMEO)
+}
+
+// a negative value disables reconnection
+func (sock *Socket) SetReconnectInterval(value time.Duration) error {
+	return sock.setMillisecondsOption(C.ZMQ_RECONNECT_IVL, value)
+}
+
+func (sock *Socket) GetReconnectInterval() (time.Duration, error) {
+	return sock.getMillisecondsOption(C.ZMQ_RECONNECT_IVL)
+}
+
+// 0 means 'use only the reconnect interval'
+func (sock *Socket) SetReconnectIntervalMax(value time.Duration) error {
+	return sock.setMillisecondsOption(C.ZMQ_RECONNECT_IVL_MAX, value)
+}
+
+func (sock *Socket) GetReconnectIntervalMax() (time.Duration, error) {
+	return sock.getMillisecondsOption(C.ZMQ_RECONNECT_IVL_MAX)
+}
+
+func (sock *Socket) SetHeartbeatInterval(value time.Duration) error {
+	return sock.setMillisecondsOption(C.ZMQ_HEARTBEAT_IVL, value)
+}
+
+func (sock *Socket) GetHeartbeatInterval() (time.Duration, error) {
+	return sock.getMillisecondsOption(C.ZMQ_HEARTBEAT_IVL)
+}
+
+func (sock *Socket) SetHeartbeatTimeout(value time.Duration) error {
+	return sock.setMillisecondsOption(C.ZMQ_HEARTBEAT_TIMEOUT, value)
+}
+
+func (sock *Socket) GetHeartbeatTimeout() (time.Duration, error) {
+	return sock.getMillisecondsOption(C.ZMQ_HEARTBEAT_TIMEOUT)
+}
+
+// TCP keepalive, the values are passed to the OS as is:
+// -1 means 'the OS default'
+
+func (sock *Socket) SetTCPKeepalive(value TCPKeepalive) error {
+	return sock.setIntOption(C.ZMQ_TCP_KEEPALIVE, int(value))
+}
+
+func (sock *Socket) GetTCPKeepalive() (TCPKeepalive, error) {
+	v, err := sock.getIntOption(C.ZMQ_TCP_KEEPALIVE)
+	return TCPKeepalive(v), err
+}
+
+func (sock *Socket) SetTCPKeepaliveCount(value int) error {
+	return sock.setIntOption(C.ZMQ_TCP_KEEPALIVE_CNT, value)
+}
+
+func (sock *Socket) GetTCPKeepaliveCount() (int, error) {
+	return sock.getIntOption(C.ZMQ_TCP_KEEPALIVE_CNT)
+}
+
+func (sock *Socket) SetTCPKeepaliveIdle(value time.Duration) error {
+	return sock.setSecondsOption(C.ZMQ_TCP_KEEPALIVE_IDLE, value)
+}
+
+func (sock *Socket) GetTCPKeepaliveIdle() (time.Duration, error) {
+	return sock.getSecondsOption(C.ZMQ_TCP_KEEPALIVE_IDLE)
+}
+
+func (sock *Socket) SetTCPKeepaliveInterval(value time.Duration) error {
+	return sock.setSecondsOption(C.ZMQ_TCP_KEEPALIVE_INTVL, value)
+}
+
+func (sock *Socket) GetTCPKeepaliveInterval() (time.Duration, error) {
+	return sock.getSecondsOption(C.ZMQ_TCP_KEEPALIVE_INTVL)
+}
+
+// Queueing
+
+// queue messages only to completed connections
+func (sock *Socket) SetImmediate(value bool) error {
+	return sock.setBoolOption(C.ZMQ_IMMEDIATE, value)
+}
+
+func (sock *Socket) GetImmediate() (bool, error) {
+	return sock.getBoolOption(C.ZMQ_IMMEDIATE)
+}
+
+// keep only the last message in the queue
+func (sock *Socket) SetConflate(value bool) error {
+	return sock.setBoolOption(C.ZMQ_CONFLATE, value)
+}
+
+func (sock *Socket) GetConflate() (bool, error) {
+	return sock.getBoolOption(C.ZMQ_CONFLATE)
+}
+
+// Identity
+
+const maxRoutingIdLen = 255
+
+func (sock *Socket) SetRoutingId(id []byte) error {
+	if (len(id) == 0) || (len(id) > maxRoutingIdLen) {
+		return fmt.Errorf("routing id must be 1 to %d bytes long", maxRoutingIdLen)
+	}
+
+	return sock.setOption(C.ZMQ_ROUTING_ID, unsafe.Pointer(&id[0]), C.size_t(len(id)))
+}
+
+func (sock *Socket) GetRoutingId() ([]byte, error) {
+	buf := make([]byte, maxRoutingIdLen)
+	l := C.size_t(len(buf))
+
+	if err := sock.getOption(C.ZMQ_ROUTING_ID, unsafe.Pointer(&buf[0]), &l); err != nil {
+		return nil, err
+	}
+
+	return buf[:l], nil
+}
@@ -0,0 +1,203 @@
+//go:build zmq_purego || !cgo
+// +build zmq_purego !cgo
+
+package zmq
+
+import (
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api/zmtp"
+)
+
+// Only the options the pure Go sockets support, they apply
+// to new connections only
+
+func (sock *Socket) updateOptions(update func(opts *zmtp.Options)) error {
+	sock.mux.Lock()
+	defer sock.mux.Unlock()
+
+	if sock.closed {
+		return ErrSocketClosed
+	}
+
+	opts := sock.sock.Options()
+	update(&opts)
+	sock.sock.SetOptions(opts)
+
+	return nil
+}
+
+func (sock *Socket) getOptions() (zmtp.Options, error) {
+	sock.mux.Lock()
+	defer sock.mux.Unlock()
+
+	if sock.closed {
+		return zmtp.Options{}, ErrSocketClosed
+	}
+
+	return sock.sock.Options(), nil
+}
+
+// negative durations mean 'infinite' or 'the default', as with libzmq
+func normalizeDuration(value time.Duration) time.Duration {
+	if value < 0 {
+		return -1
+	}
+
+	return value
+}
+
+// High water marks, 0 means 'no limit'
+
+func (sock *Socket) SetSndHWM(value int) error {
+	return sock.updateOptions(func(opts *zmtp.Options) { opts.SndHWM = value })
+}
+
+func (sock *Socket) GetSndHWM() (int, error) {
+	opts, err := sock.getOptions()
+	return opts.SndHWM, err
+}
+
+func (sock *Socket) SetRcvHWM(value int) error {
+	return sock.updateOptions(func(opts *zmtp.Options) { opts.RcvHWM = value })
+}
+
+func (sock *Socket) GetRcvHWM() (int, error) {
+	opts, err := sock.getOptions()
+	return opts.RcvHWM, err
+}
+
+// -1 means 'no limit', a peer sending a larger frame is disconnected
+func (sock *Socket) SetMaxMsgSize(value int64) error {
+	if value < 0 {
+		value = -1
+	}
+
+	return sock.updateOptions(func(opts *zmtp.Options) { opts.MaxMsgSize = value })
+}
+
+func (sock *Socket) GetMaxMsgSize() (int64, error) {
+	opts, err := sock.getOptions()
+	return opts.MaxMsgSize, err
+}
+
+// the pending messages are always dropped on Close(),
+// the value is only stored
+func (sock *Socket) SetLinger(value time.Duration) error {
+	sock.mux.Lock()
+	defer sock.mux.Unlock()
+
+	if sock.closed {
+		return ErrSocketClosed
+	}
+
+	sock.linger = normalizeDuration(value)
+
+	return nil
+}
+
+func (sock *Socket) GetLinger() (time.Duration, error) {
+	sock.mux.Lock()
+	defer sock.mux.Unlock()
+
+	if sock.closed {
+		return 0, ErrSocketClosed
+	}
+
+	return sock.linger, nil
+}
+
+// a negative value disables reconnection
+func (sock *Socket) SetReconnectInterval(value time.Duration) error {
+	return sock.updateOptions(func(opts *zmtp.Options) {
+		opts.ReconnectInterval = normalizeDuration(value)
+	})
+}
+
+func (sock *Socket) GetReconnectInterval() (time.Duration, error) {
+	opts, err := sock.getOptions()
+	return opts.ReconnectInterval, err
+}
+
+// 0 means 'use only the reconnect interval'
+func (sock *Socket) SetReconnectIntervalMax(value time.Duration) error {
+	return sock.updateOptions(func(opts *zmtp.Options) { opts.ReconnectIntervalMax = value })
+}
+
+func (sock *Socket) GetReconnectIntervalMax() (time.Duration, error) {
+	opts, err := sock.getOptions()
+	return opts.ReconnectIntervalMax, err
+}
+
+func (sock *Socket) SetHeartbeatInterval(value time.Duration) error {
+	return sock.updateOptions(func(opts *zmtp.Options) { opts.HeartbeatInterval = value })
+}
+
+func (sock *Socket) GetHeartbeatInterval() (time.Duration, error) {
+	opts, err := sock.getOptions()
+	return opts.HeartbeatInterval, err
+}
+
+func (sock *Socket) SetHeartbeatTimeout(value time.Duration) error {
+	return sock.updateOptions(func(opts *zmtp.Options) {
+		opts.HeartbeatTimeout = normalizeDuration(value)
+	})
+}
+
+func (sock *Socket) GetHeartbeatTimeout() (time.Duration, error) {
+	opts, err := sock.getOptions()
+	return opts.HeartbeatTimeout, err
+}
+
+// TCP keepalive, only the idle time can be set, the interval
+// and the count are only stored
+
+func (sock *Socket) SetTCPKeepalive(value TCPKeepalive) error {
+	return sock.updateOptions(func(opts *zmtp.Options) { opts.TCPKeepalive = int(value) })
+}
+
+func (sock *Socket) GetTCPKeepalive() (TCPKeepalive, error) {
+	opts, err := sock.getOptions()
+	return TCPKeepalive(opts.TCPKeepalive), err
+}
+
+func (sock *Socket) SetTCPKeepaliveCount(value int) error {
+	return sock.updateOptions(func(opts *zmtp.Options) { opts.TCPKeepaliveCount = value })
+}
+
+func (sock *Socket) GetTCPKeepaliveCount() (int, error) {
+	opts, err := sock.getOptions()
+	return opts.TCPKeepaliveCount, err
+}
+
+func (sock *Socket) SetTCPKeepaliveIdle(value time.Duration) error {
+	return sock.updateOptions(func(opts *zmtp.Options) {
+		opts.TCPKeepaliveIdle = normalizeDuration(value)
+	})
+}
+
+func (sock *Socket) GetTCPKeepaliveIdle() (time.Duration, error) {
+	opts, err := sock.getOptions()
+	return opts.TCPKeepaliveIdle, err
+}
+
+func (sock *Socket) SetTCPKeepaliveInterval(value time.Duration) error {
+	return sock.updateOptions(func(opts *zmtp.Options) {
+		opts.TCPKeepaliveInterval = normalizeDuration(value)
+	})
+}
+
+func (sock *Socket) GetTCPKeepaliveInterval() (time.Duration, error) {
+	opts, err := sock.getOptions()
+	return opts.TCPKeepaliveInterval, err
+}
+
+// keep only the last message in the queue
+func (sock *Socket) SetConflate(value bool) error {
+	return sock.updateOptions(func(opts *zmtp.Options) { opts.Conflate = value })
+}
+
+func (sock *Socket) GetConflate() (bool, error) {
+	opts, err := sock.getOptions()
+	return opts.Conflate, err
+}
@@ -0,0 +1,205 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package zmq
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestIntOptions(t *testing.T) {
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	sock := createSocketOrFail(t, ctx, SocketDEALER)
+	defer closeSocketOrFail(t, sock)
+
+	options := []struct {
+		name  string
+		set   func(int) error
+		get   func() (int, error)
+		value int
+	}{
+		{"SNDHWM", sock.SetSndHWM, sock.GetSndHWM, 10},
+		{"RCVHWM", sock.SetRcvHWM, sock.GetRcvHWM, 20},
+		{"TCP_KEEPALIVE_CNT", sock.SetTCPKeepaliveCount, sock.GetTCPKeepaliveCount, 5},
+	}
+
+	for _, opt := range options {
+		if err := opt.set(opt.value); err != nil {
+			t.Fatalf("Setting %s failed: %v", opt.name, err)
+		}
+
+		value, err := opt.get()
+		if err != nil {
+			t.Fatalf("Getting %s failed: %v", opt.name, err)
+		}
+
+		if value != opt.value {
+			t.Fatalf("%s is %d, expected %d", opt.name, value, opt.value)
+		}
+	}
+}
+
+func TestMaxMsgSize(t *testing.T) {
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	sock := createSocketOrFail(t, ctx, SocketSUB)
+	defer closeSocketOrFail(t, sock)
+
+	for _, size := range []int64{1 << 20, -1} {
+		if err := sock.SetMaxMsgSize(size); err != nil {
+			t.Fatalf("SetMaxMsgSize() failed: %v", err)
+		}
+
+		value, err := sock.GetMaxMsgSize()
+		if err != nil {
+			t.Fatalf("GetMaxMsgSize() failed: %v", err)
+		}
+
+		if value != size {
+			t.Fatalf("MAXMSGSIZE is %d, expected %d", value, size)
+		}
+	}
+}
+
+func TestDurationOptions(t *testing.T) {
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	sock := createSocketOrFail(t, ctx, SocketDEALER)
+	defer closeSocketOrFail(t, sock)
+
+	options := []struct {
+		name  string
+		set   func(time.Duration) error
+		get   func() (time.Duration, error)
+		value time.Duration
+	}{
+		{"LINGER", sock.SetLinger, sock.GetLinger, 100 * time.Millisecond},
+		{"LINGER", sock.SetLinger, sock.GetLinger, -1},
+		{"RCVTIMEO", sock.SetRcvTimeout, sock.GetRcvTimeout, time.Second},
+		{"SNDTIMEO", sock.SetSndTimeout, sock.GetSndTimeout, 2 * time.Second},
+		{"RECONNECT_IVL", sock.SetReconnectInterval, sock.GetReconnectInterval, 500 * time.Millisecond},
+		{"RECONNECT_IVL_MAX", sock.SetReconnectIntervalMax, sock.GetReconnectIntervalMax, 30 * time.Second},
+		{"HEARTBEAT_IVL", sock.SetHeartbeatInterval, sock.GetHeartbeatInterval, 5 * time.Second},
+		{"HEARTBEAT_TIMEOUT", sock.SetHeartbeatTimeout, sock.GetHeartbeatTimeout, 15 * time.Second},
+		{"TCP_KEEPALIVE_IDLE", sock.SetTCPKeepaliveIdle, sock.GetTCPKeepaliveIdle, 60 * time.Second},
+		{"TCP_KEEPALIVE_INTVL", sock.SetTCPKeepaliveInterval, sock.GetTCPKeepaliveInterval, 10 * time.Second},
+	}
+
+	for _, opt := range options {
+		if err := opt.set(opt.value); err != nil {
+			t.Fatalf("Setting %s failed: %v", opt.name, err)
+		}
+
+		value, err := opt.get()
+		if err != nil {
+			t.Fatalf("Getting %s failed: %v", opt.name, err)
+		}
+
+		if value != opt.value {
+			t.Fatalf("%s is %v, expected %v", opt.name, value, opt.value)
+		}
+	}
+}
+
+func TestBoolOptions(t *testing.T) {
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	sock := createSocketOrFail(t, ctx, SocketDEALER)
+	defer closeSocketOrFail(t, sock)
+
+	options := []struct {
+		name string
+		set  func(bool) error
+		get  func() (bool, error)
+	}{
+		{"IMMEDIATE", sock.SetImmediate, sock.GetImmediate},
+		{"CONFLATE", sock.SetConflate, sock.GetConflate},
+	}
+
+	for _, opt := range options {
+		for _, v := range []bool{true, false} {
+			if err := opt.set(v); err != nil {
+				t.Fatalf("Setting %s failed: %v", opt.name, err)
+			}
+
+			value, err := opt.get()
+			if err != nil {
+				t.Fatalf("Getting %s failed: %v", opt.name, err)
+			}
+
+			if value != v {
+				t.Fatalf("%s is %v, expected %v", opt.name, value, v)
+			}
+		}
+	}
+}
+
+func TestTCPKeepalive(t *testing.T) {
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	sock := createSocketOrFail(t, ctx, SocketSUB)
+	defer closeSocketOrFail(t, sock)
+
+	for _, v := range []TCPKeepalive{TCPKeepaliveOn, TCPKeepaliveOff, TCPKeepaliveDefault} {
+		if err := sock.SetTCPKeepalive(v); err != nil {
+			t.Fatalf("SetTCPKeepalive(%d) failed: %v", v, err)
+		}
+
+		value, err := sock.GetTCPKeepalive()
+		if err != nil {
+			t.Fatalf("GetTCPKeepalive() failed: %v", err)
+		}
+
+		if value != v {
+			t.Fatalf("TCP_KEEPALIVE is %d, expected %d", value, v)
+		}
+	}
+}
+
+func TestRoutingId(t *testing.T) {
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	router := createSocketOrFail(t, ctx, SocketROUTER)
+	defer closeSocketOrFail(t, router)
+
+	dealer := createSocketOrFail(t, ctx, SocketDEALER)
+	defer closeSocketOrFail(t, dealer)
+
+	if err := dealer.SetRoutingId(nil); err == nil {
+		t.Fatalf("SetRoutingId() accepted an empty id")
+	}
+
+	id := []byte("gateway-1")
+	if err := dealer.SetRoutingId(id); err != nil {
+		t.Fatalf("SetRoutingId() failed: %v", err)
+	}
+
+	got, err := dealer.GetRoutingId()
+	if err != nil {
+		t.Fatalf("GetRoutingId() failed: %v", err)
+	}
+
+	if !bytes.Equal(got, id) {
+		t.Fatalf("Routing id is '%s', expected '%s'", got, id)
+	}
+
+	if err := router.Bind("inproc://routing_id"); err != nil {
+		t.Fatalf("Bind() failed: %v", err)
+	}
+
+	if err := dealer.Connect("inproc://routing_id"); err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+
+	sendOrFail(t, dealer, "hello")
+	expectFrames(t, recvOrFail(t, router), string(id), "hello")
+}
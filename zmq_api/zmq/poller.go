@@ -1,3 +1,6 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
 package zmq
 
 import (
@@ -8,10 +11,17 @@ import (
 	"golang.org/x/sys/unix"
 )
 
+// ReadPoller is edge-triggered: a socket is reported only when its ZMQ_FD
+// is signalled, so the caller must receive everything available after
+// each Poll(). See Poller for a level-triggered alternative.
 type ReadPoller struct {
 	fdToSock map[int]*Socket
 
 	pollFds []unix.PollFd
+
+	// a pipe used to interrupt Poll() from another goroutine
+	wakeupReadFd  int
+	wakeupWriteFd int
 }
 
 func NewReadPoller(sockets ...*Socket) (*ReadPoller, error) {
@@ -33,17 +43,80 @@ func NewReadPoller(sockets ...*Socket) (*ReadPoller, error) {
 		poller.pollFds = append(poller.pollFds, unix.PollFd{Fd: int32(fd), Events: unix.POLLIN})
 	}
 
+	pipeFds := make([]int, 2)
+	if err := unix.Pipe(pipeFds); err != nil {
+		return nil, fmt.Errorf("pipe() failed: %v", err)
+	}
+
+	poller.wakeupReadFd = pipeFds[0]
+	poller.wakeupWriteFd = pipeFds[1]
+
+	for _, fd := range pipeFds {
+		if err := unix.SetNonblock(fd, true); err != nil {
+			poller.Close()
+			return nil, fmt.Errorf("SetNonblock() failed: %v", err)
+		}
+	}
+
+	poller.pollFds = append(poller.pollFds, unix.PollFd{Fd: int32(poller.wakeupReadFd), Events: unix.POLLIN})
+
 	return &poller, nil
 }
 
+func (p *ReadPoller) Close() error {
+	var err error
+
+	for _, fd := range []int{p.wakeupReadFd, p.wakeupWriteFd} {
+		if closeErr := unix.Close(fd); (err == nil) && (closeErr != nil) {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// Wakeup makes the current (or the next) call to Poll() return immediately.
+// It is safe to call it from a goroutine other than the one calling Poll().
+func (p *ReadPoller) Wakeup() error {
+	_, err := unix.Write(p.wakeupWriteFd, []byte{0})
+
+	// the pipe is full, i.e. Poll() is already going to be woken up
+	if errors.Is(err, unix.EAGAIN) {
+		return nil
+	}
+
+	return err
+}
+
+func (p *ReadPoller) drainWakeups() error {
+	buf := make([]byte, 64)
+
+	for {
+		_, err := unix.Read(p.wakeupReadFd, buf)
+		if errors.Is(err, unix.EAGAIN) {
+			return nil
+		}
+
+		if (err != nil) && (!errors.Is(err, unix.EINTR)) {
+			return err
+		}
+	}
+}
+
+// a negative timeout means waiting until a socket is ready or Wakeup() is called
 func (p *ReadPoller) Poll(timeout time.Duration) ([]*Socket, error) {
 	ret := make([]*Socket, 0)
 
+	timeoutMs := int(timeout.Milliseconds())
+	if timeout < 0 {
+		timeoutMs = -1
+	}
+
 	var n int
 	var err error
 
 	for {
-		n, err = unix.Poll(p.pollFds, int(timeout.Milliseconds()))
+		n, err = unix.Poll(p.pollFds, timeoutMs)
 		if !errors.Is(err, unix.EINTR) {
 			break
 		}
@@ -59,6 +132,15 @@ func (p *ReadPoller) Poll(timeout time.Duration) ([]*Socket, error) {
 			break
 		}
 
+		if (int(pollFd.Fd) == p.wakeupReadFd) && (pollFd.Revents&unix.POLLIN != 0) {
+			if err := p.drainWakeups(); err != nil {
+				return ret, fmt.Errorf("drainWakeups() failed: %v", err)
+			}
+
+			processed += 1
+			continue
+		}
+
 		if pollFd.Revents&unix.POLLIN != 0 {
 			sock := p.fdToSock[int(pollFd.Fd)]
 
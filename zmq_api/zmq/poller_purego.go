@@ -0,0 +1,191 @@
+//go:build zmq_purego || !cgo
+// +build zmq_purego !cgo
+
+package zmq
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api/zmtp"
+)
+
+// the libzmq values
+const (
+	PollIn  PollEvents = 1
+	PollOut PollEvents = 2
+)
+
+// GetEvents returns the events the socket is ready for, a PUB socket
+// is always ready for sending
+func (sock *Socket) GetEvents() (PollEvents, error) {
+	sock.mux.Lock()
+	defer sock.mux.Unlock()
+
+	if sock.closed {
+		return 0, ErrSocketClosed
+	}
+
+	var events PollEvents
+
+	if sock.sock.Type() == zmtp.PUB {
+		events |= PollOut
+	}
+
+	if sock.sock.Readable() {
+		events |= PollIn
+	}
+
+	return events, nil
+}
+
+type pollerEntry struct {
+	sock   *Socket
+	events PollEvents
+}
+
+// Poller is a level-triggered poller for sockets, plain file
+// descriptors are not supported
+type Poller struct {
+	entries []pollerEntry
+	mux     sync.Mutex
+	closed  bool
+
+	// signalled by the sockets when they receive a message
+	notifyChan chan struct{}
+	wakeupChan chan struct{}
+}
+
+func NewPoller() (*Poller, error) {
+	return &Poller{notifyChan: make(chan struct{}, 1),
+		wakeupChan: make(chan struct{}, 1)}, nil
+}
+
+func (p *Poller) Close() error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.closed {
+		return ErrPollerClosed
+	}
+
+	p.closed = true
+
+	for _, entry := range p.entries {
+		entry.sock.sock.Unwatch(p.notifyChan)
+	}
+	p.entries = nil
+
+	return nil
+}
+
+// makes a blocked Poll() check the sockets again
+func (p *Poller) notify() {
+	select {
+	case p.notifyChan <- struct{}{}:
+	default:
+	}
+}
+
+// AddSocket registers the socket or changes the events of
+// an already registered one
+func (p *Poller) AddSocket(sock *Socket, events PollEvents) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	defer p.notify()
+
+	for i := range p.entries {
+		if p.entries[i].sock == sock {
+			p.entries[i].events = events
+			return nil
+		}
+	}
+
+	p.entries = append(p.entries, pollerEntry{sock: sock, events: events})
+	sock.sock.Watch(p.notifyChan)
+
+	return nil
+}
+
+func (p *Poller) RemoveSocket(sock *Socket) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	for i := range p.entries {
+		if p.entries[i].sock == sock {
+			p.entries = append(p.entries[:i], p.entries[i+1:]...)
+			sock.sock.Unwatch(p.notifyChan)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("not registered")
+}
+
+func (p *Poller) AddFd(fd int, events PollEvents) error {
+	return ErrNotSupported
+}
+
+func (p *Poller) RemoveFd(fd int) error {
+	return ErrNotSupported
+}
+
+// Wakeup makes the current (or the next) call to Poll() return immediately
+// with nothing ready, unless a socket is already ready
+func (p *Poller) Wakeup() error {
+	select {
+	case p.wakeupChan <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (p *Poller) readyItems() []PollItem {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	items := make([]PollItem, 0)
+
+	for _, entry := range p.entries {
+		events, err := entry.sock.GetEvents()
+		if err != nil {
+			// closed
+			continue
+		}
+
+		if events&entry.events != 0 {
+			items = append(items, PollItem{Socket: entry.sock, Fd: -1,
+				Events: events & entry.events})
+		}
+	}
+
+	return items
+}
+
+// a negative timeout means waiting until a socket is ready or Wakeup() is called
+func (p *Poller) Poll(timeout time.Duration) ([]PollItem, error) {
+	var timeoutChan <-chan time.Time
+	if timeout >= 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		timeoutChan = timer.C
+	}
+
+	for {
+		if items := p.readyItems(); len(items) != 0 {
+			return items, nil
+		}
+
+		select {
+		case <-p.notifyChan:
+		case <-p.wakeupChan:
+			return make([]PollItem, 0), nil
+		case <-timeoutChan:
+			return make([]PollItem, 0), nil
+		}
+	}
+}
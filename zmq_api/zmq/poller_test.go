@@ -1,3 +1,6 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
 package zmq
 
 import (
@@ -119,6 +122,7 @@ func TestReadPoller(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewReadPoller() failed: %v", err)
 	}
+	defer poller.Close()
 
 	succReads := make(map[*Socket]int)
 	for _, socket := range sockets {
@@ -172,3 +176,63 @@ func TestReadPollerWithEmptySocketList(t *testing.T) {
 		t.Fatalf("Unexpected error from NewReadPoller(): %v", err)
 	}
 }
+
+func TestReadPollerWakeup(t *testing.T) {
+	const endpoint = "tcp://127.0.0.1:6999"
+
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	sock := createSocketOrFail(t, ctx, SocketSUB)
+	defer closeSocketOrFail(t, sock)
+
+	if err := sock.Connect(endpoint); err != nil {
+		t.Fatalf("Connect('%s') failed: %v", endpoint, err)
+	}
+
+	poller, err := NewReadPoller(sock)
+	if err != nil {
+		t.Fatalf("NewReadPoller() failed: %v", err)
+	}
+	defer poller.Close()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		poller.Wakeup()
+	}()
+
+	start := time.Now()
+
+	readySocks, err := poller.Poll(-1)
+	if err != nil {
+		t.Fatalf("Poll() failed: %v", err)
+	}
+
+	if len(readySocks) != 0 {
+		t.Fatalf("Poll() returned %d ready sockets, expected 0", len(readySocks))
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Poll() was not woken up, returned after %v", elapsed)
+	}
+
+	// several wakeups are coalesced and do not affect the next Poll()
+	for i := 0; i < 3; i++ {
+		if err := poller.Wakeup(); err != nil {
+			t.Fatalf("Wakeup() failed: %v", err)
+		}
+	}
+
+	if _, err := poller.Poll(0); err != nil {
+		t.Fatalf("Poll() failed: %v", err)
+	}
+
+	start = time.Now()
+	if _, err := poller.Poll(200 * time.Millisecond); err != nil {
+		t.Fatalf("Poll() failed: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("Poll() returned too early, after %v", elapsed)
+	}
+}
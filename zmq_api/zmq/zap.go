@@ -0,0 +1,144 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package zmq
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// the endpoint libzmq sends ZAP (RFC 27) requests to
+const zapEndpoint = "inproc://zeromq.zap.01"
+
+const zapVersion = "1.0"
+
+// ZAPHandler authenticates incoming connections to all sockets of
+// the context which have the ZAP domain or the CURVE server role set.
+// There may be only one handler per context.
+type ZAPHandler struct {
+	sock   *Socket
+	poller *ReadPoller
+
+	authorize ZAPAuthorizer
+
+	stopFlag int32
+	doneChan chan struct{}
+	err      error
+}
+
+func NewZAPHandler(ctx *Context, authorize ZAPAuthorizer) (*ZAPHandler, error) {
+	h := ZAPHandler{authorize: authorize, doneChan: make(chan struct{})}
+
+	var err error
+
+	h.sock, err = NewSocket(ctx, SocketREP)
+	if err != nil {
+		return nil, fmt.Errorf("NewSocket() failed: %v", err)
+	}
+
+	if err = h.sock.Bind(zapEndpoint); err != nil {
+		h.sock.Close()
+		return nil, fmt.Errorf("Bind('%s') failed: %v", zapEndpoint, err)
+	}
+
+	h.poller, err = NewReadPoller(h.sock)
+	if err != nil {
+		h.sock.Close()
+		return nil, fmt.Errorf("NewReadPoller() failed: %v", err)
+	}
+
+	go h.run()
+
+	return &h, nil
+}
+
+func (h *ZAPHandler) run() {
+	defer close(h.doneChan)
+
+	defer func() {
+		if err := h.sock.Close(); (err != nil) && (h.err == nil) {
+			h.err = fmt.Errorf("socket Close() failed: %v", err)
+		}
+	}()
+
+	for atomic.LoadInt32(&h.stopFlag) == 0 {
+		for {
+			frames, err, received := h.sock.RecvMultipartNonBlocking()
+			if err != nil {
+				h.err = fmt.Errorf("RecvMultipartNonBlocking() failed: %v", err)
+				return
+			}
+
+			if !received {
+				break
+			}
+
+			if err = h.sock.SendMultipart(h.reply(frames)); err != nil {
+				h.err = fmt.Errorf("SendMultipart() failed: %v", err)
+				return
+			}
+		}
+
+		if _, err := h.poller.Poll(-1); err != nil {
+			h.err = fmt.Errorf("Poll() failed: %v", err)
+			return
+		}
+	}
+}
+
+func (h *ZAPHandler) reply(frames [][]byte) [][]byte {
+	// version, request id, domain, address, routing id, mechanism, credentials...
+	if (len(frames) < 6) || (string(frames[0]) != zapVersion) {
+		return zapReply(nil, "400", "Malformed request")
+	}
+
+	requestId := frames[1]
+	req := ZAPRequest{Domain: string(frames[2]),
+		Address:   string(frames[3]),
+		Mechanism: string(frames[5])}
+
+	if req.Mechanism == "CURVE" {
+		if len(frames) != 7 {
+			return zapReply(requestId, "400", "Malformed CURVE credentials")
+		}
+
+		key, err := z85Encode(frames[6])
+		if err != nil {
+			return zapReply(requestId, "400", "Malformed CURVE credentials")
+		}
+
+		req.ClientKey = key
+	}
+
+	if !h.authorize(&req) {
+		return zapReply(requestId, "400", "Not allowed")
+	}
+
+	return zapReply(requestId, "200", "OK")
+}
+
+func zapReply(requestId []byte, statusCode string, statusText string) [][]byte {
+	// version, request id, status code, status text, user id, metadata
+	return [][]byte{[]byte(zapVersion), requestId, []byte(statusCode),
+		[]byte(statusText), []byte{}, []byte{}}
+}
+
+// Close stops the handler, the context cannot be terminated before that
+func (h *ZAPHandler) Close() error {
+	atomic.StoreInt32(&h.stopFlag, 1)
+
+	if err := h.poller.Wakeup(); err != nil {
+		return fmt.Errorf("Wakeup() failed: %v", err)
+	}
+
+	<-h.doneChan
+
+	// the poller is closed only here, so Wakeup() above is safe even if
+	// the handler goroutine has already exited
+	if err := h.poller.Close(); (err != nil) && (h.err == nil) {
+		h.err = fmt.Errorf("poller Close() failed: %v", err)
+	}
+
+	return h.err
+}
@@ -1,30 +1,30 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
 package zmq
 
 // #cgo LDFLAGS: -lzmq
 // #include <zmq.h>
+// #include <stdlib.h>
 import "C"
 
 import (
 	"errors"
 	"fmt"
+	"runtime"
+	"sync"
 	"unsafe"
 
 	"golang.org/x/sys/unix"
 )
 
-type socketType int
-
-const (
-	SocketPUB socketType = iota
-	SocketSUB
-)
-
-func (tp socketType) String() string {
-	return [...]string{"PUB", "SUB"}[tp]
-}
-
+// Contexts and sockets not terminated/closed explicitly are
+// terminated/closed by finalizers, but one should not rely on that.
 type Context struct {
 	ctx unsafe.Pointer
+
+	// NewSocket() is safe to call concurrently, but not with Terminate()
+	mux sync.RWMutex
 }
 
 func NewContext() (*Context, error) {
@@ -43,10 +43,20 @@ func NewContext() (*Context, error) {
 		return nil, err
 	}
 
-	return &Context{ctx: p}, nil
+	ctx := &Context{ctx: p}
+	runtime.SetFinalizer(ctx, (*Context).Terminate)
+
+	return ctx, nil
 }
 
 func (ctx *Context) Terminate() error {
+	ctx.mux.Lock()
+	defer ctx.mux.Unlock()
+
+	if ctx.ctx == nil {
+		return ErrContextTerminated
+	}
+
 	var err error
 	var rv C.int
 
@@ -62,14 +72,33 @@ func (ctx *Context) Terminate() error {
 		return err
 	}
 
+	ctx.ctx = nil
+	runtime.SetFinalizer(ctx, nil)
+
 	return nil
 }
 
+// A Socket may be used from several goroutines, the calls are serialized
+// (ZMQ allows passing a socket between threads with a full memory barrier
+// in between, which the lock provides). As a consequence, a blocking call,
+// e.g. Recv() without a receive timeout, blocks the other calls including
+// Close() until it returns.
 type Socket struct {
 	sock unsafe.Pointer
+	mux  sync.Mutex
+
+	// keeps the context from being finalized before the socket
+	ctx *Context
 }
 
 func NewSocket(ctx *Context, sockType socketType) (*Socket, error) {
+	ctx.mux.RLock()
+	defer ctx.mux.RUnlock()
+
+	if ctx.ctx == nil {
+		return nil, ErrContextTerminated
+	}
+
 	var tp C.int
 
 	switch sockType {
@@ -77,6 +106,24 @@ func NewSocket(ctx *Context, sockType socketType) (*Socket, error) {
 		tp = C.ZMQ_PUB
 	case SocketSUB:
 		tp = C.ZMQ_SUB
+	case SocketPUSH:
+		tp = C.ZMQ_PUSH
+	case SocketPULL:
+		tp = C.ZMQ_PULL
+	case SocketREQ:
+		tp = C.ZMQ_REQ
+	case SocketREP:
+		tp = C.ZMQ_REP
+	case SocketDEALER:
+		tp = C.ZMQ_DEALER
+	case SocketROUTER:
+		tp = C.ZMQ_ROUTER
+	case SocketXPUB:
+		tp = C.ZMQ_XPUB
+	case SocketXSUB:
+		tp = C.ZMQ_XSUB
+	case SocketPAIR:
+		tp = C.ZMQ_PAIR
 	default:
 		return nil, fmt.Errorf("unsupported socket type %d", sockType)
 	}
@@ -96,10 +143,44 @@ func NewSocket(ctx *Context, sockType socketType) (*Socket, error) {
 		return nil, err
 	}
 
-	return &Socket{sock: p}, nil
+	sock := &Socket{sock: p, ctx: ctx}
+	runtime.SetFinalizer(sock, (*Socket).finalize)
+
+	return sock, nil
+}
+
+// lock must be held while sock.sock is used. It also keeps the socket
+// from being finalized during a C call, as unlock() uses the socket.
+func (sock *Socket) lock() error {
+	sock.mux.Lock()
+
+	if sock.sock == nil {
+		sock.mux.Unlock()
+		return ErrSocketClosed
+	}
+
+	return nil
+}
+
+func (sock *Socket) unlock() {
+	sock.mux.Unlock()
+}
+
+// pending messages of an abandoned socket must not block
+// the context termination
+func (sock *Socket) finalize() {
+	sock.SetLinger(0)
+	sock.Close()
 }
 
 func (sock *Socket) Close() error {
+	sock.mux.Lock()
+	defer sock.mux.Unlock()
+
+	if sock.sock == nil {
+		return ErrSocketClosed
+	}
+
 	var err error
 	var rv C.int
 
@@ -115,10 +196,19 @@ func (sock *Socket) Close() error {
 		return err
 	}
 
+	sock.sock = nil
+	sock.ctx = nil
+	runtime.SetFinalizer(sock, nil)
+
 	return nil
 }
 
 func (sock *Socket) GetFd() (int, error) {
+	if err := sock.lock(); err != nil {
+		return -1, err
+	}
+	defer sock.unlock()
+
 	var fd C.int
 	l := C.size_t(unsafe.Sizeof(fd))
 
@@ -141,11 +231,19 @@ func (sock *Socket) GetFd() (int, error) {
 }
 
 func (sock *Socket) Bind(endpoint string) error {
+	if err := sock.lock(); err != nil {
+		return err
+	}
+	defer sock.unlock()
+
+	cEndpoint := C.CString(endpoint)
+	defer C.free(unsafe.Pointer(cEndpoint))
+
 	var err error
 	var rv C.int
 
 	for {
-		rv, err = C.zmq_bind(sock.sock, C.CString(endpoint))
+		rv, err = C.zmq_bind(sock.sock, cEndpoint)
 
 		if (rv == 0) || (!errors.Is(err, unix.EINTR)) {
 			break
@@ -160,11 +258,19 @@ func (sock *Socket) Bind(endpoint string) error {
 }
 
 func (sock *Socket) Unbind(endpoint string) error {
+	if err := sock.lock(); err != nil {
+		return err
+	}
+	defer sock.unlock()
+
+	cEndpoint := C.CString(endpoint)
+	defer C.free(unsafe.Pointer(cEndpoint))
+
 	var err error
 	var rv C.int
 
 	for {
-		rv, err = C.zmq_unbind(sock.sock, C.CString(endpoint))
+		rv, err = C.zmq_unbind(sock.sock, cEndpoint)
 
 		if (rv == 0) || (!errors.Is(err, unix.EINTR)) {
 			break
@@ -179,11 +285,19 @@ func (sock *Socket) Unbind(endpoint string) error {
 }
 
 func (sock *Socket) Connect(endpoint string) error {
+	if err := sock.lock(); err != nil {
+		return err
+	}
+	defer sock.unlock()
+
+	cEndpoint := C.CString(endpoint)
+	defer C.free(unsafe.Pointer(cEndpoint))
+
 	var err error
 	var rv C.int
 
 	for {
-		rv, err = C.zmq_connect(sock.sock, C.CString(endpoint))
+		rv, err = C.zmq_connect(sock.sock, cEndpoint)
 
 		if (rv == 0) || (!errors.Is(err, unix.EINTR)) {
 			break
@@ -231,10 +345,20 @@ func (sock *Socket) doRecv(p []byte, flags C.int) (int, error) {
 }
 
 func (sock *Socket) Recv(p []byte) (int, error) {
+	if err := sock.lock(); err != nil {
+		return 0, err
+	}
+	defer sock.unlock()
+
 	return sock.doRecv(p, 0)
 }
 
 func (sock *Socket) RecvNonBlocking(p []byte) (int, error, bool) {
+	if err := sock.lock(); err != nil {
+		return 0, err, true
+	}
+	defer sock.unlock()
+
 	readLen, err := sock.doRecv(p, C.ZMQ_DONTWAIT)
 
 	if errors.Is(err, unix.EAGAIN) {
@@ -244,16 +368,122 @@ func (sock *Socket) RecvNonBlocking(p []byte) (int, error, bool) {
 	return readLen, err, true
 }
 
-func (sock *Socket) Send(p []byte) error {
-	if (p == nil) || (len(p) == 0) {
-		return nil
+// doRecvMsg receives a whole message (frame) of any size, the second
+// returned value tells if more frames of a multipart message follow
+func (sock *Socket) doRecvMsg(flags C.int) ([]byte, bool, error) {
+	var msg C.zmq_msg_t
+
+	rv, err := C.zmq_msg_init(&msg)
+	if rv != 0 {
+		return nil, false, fmt.Errorf("zmq_msg_init() failed: %v", err)
+	}
+	defer C.zmq_msg_close(&msg)
+
+	for {
+		rv, err = C.zmq_msg_recv(&msg, sock.sock, flags)
+
+		if (rv != -1) || (!errors.Is(err, unix.EINTR)) {
+			break
+		}
+	}
+
+	if rv == -1 {
+		return nil, false, err
+	}
+
+	data := C.GoBytes(C.zmq_msg_data(&msg), C.int(C.zmq_msg_size(&msg)))
+	more := C.zmq_msg_more(&msg) != 0
+
+	_, err = sock.updateEventsState()
+	if err != nil {
+		return nil, false, fmt.Errorf("updateEventsStatus(): %v", err)
+	}
+
+	return data, more, nil
+}
+
+func (sock *Socket) RecvMsg() ([]byte, error) {
+	if err := sock.lock(); err != nil {
+		return nil, err
+	}
+	defer sock.unlock()
+
+	data, _, err := sock.doRecvMsg(0)
+	return data, err
+}
+
+func (sock *Socket) RecvMsgNonBlocking() ([]byte, error, bool) {
+	if err := sock.lock(); err != nil {
+		return nil, err, true
+	}
+	defer sock.unlock()
+
+	data, _, err := sock.doRecvMsg(C.ZMQ_DONTWAIT)
+
+	if errors.Is(err, unix.EAGAIN) {
+		return nil, nil, false
+	}
+
+	return data, err, true
+}
+
+// ZMQ delivers multipart messages atomically, so once the first frame
+// is received, the rest are received without blocking
+func (sock *Socket) doRecvMultipart(flags C.int) ([][]byte, error) {
+	frames := make([][]byte, 0)
+
+	for {
+		data, more, err := sock.doRecvMsg(flags)
+		if err != nil {
+			return frames, err
+		}
+
+		frames = append(frames, data)
+		if !more {
+			break
+		}
+
+		flags = 0
+	}
+
+	return frames, nil
+}
+
+func (sock *Socket) RecvMultipart() ([][]byte, error) {
+	if err := sock.lock(); err != nil {
+		return nil, err
+	}
+	defer sock.unlock()
+
+	return sock.doRecvMultipart(0)
+}
+
+func (sock *Socket) RecvMultipartNonBlocking() ([][]byte, error, bool) {
+	if err := sock.lock(); err != nil {
+		return nil, err, true
+	}
+	defer sock.unlock()
+
+	frames, err := sock.doRecvMultipart(C.ZMQ_DONTWAIT)
+
+	if errors.Is(err, unix.EAGAIN) && (len(frames) == 0) {
+		return nil, nil, false
+	}
+
+	return frames, err, true
+}
+
+func (sock *Socket) doSend(p []byte, flags C.int) error {
+	var data unsafe.Pointer
+	if len(p) != 0 {
+		data = unsafe.Pointer(&p[0])
 	}
 
 	var err error
 	var rv C.int
 
 	for {
-		rv, err = C.zmq_send(sock.sock, unsafe.Pointer(&p[0]), C.size_t(len(p)), 0)
+		rv, err = C.zmq_send(sock.sock, data, C.size_t(len(p)), flags)
 
 		if (rv != -1) || (!errors.Is(err, unix.EINTR)) {
 			break
@@ -272,7 +502,52 @@ func (sock *Socket) Send(p []byte) error {
 	return nil
 }
 
+func (sock *Socket) Send(p []byte) error {
+	if (p == nil) || (len(p) == 0) {
+		return nil
+	}
+
+	if err := sock.lock(); err != nil {
+		return err
+	}
+	defer sock.unlock()
+
+	return sock.doSend(p, 0)
+}
+
+// SendMultipart sends the frames as one multipart message,
+// empty frames are allowed
+func (sock *Socket) SendMultipart(frames [][]byte) error {
+	if len(frames) == 0 {
+		return nil
+	}
+
+	// the lock keeps frames of concurrent messages from interleaving
+	if err := sock.lock(); err != nil {
+		return err
+	}
+	defer sock.unlock()
+
+	for i, frame := range frames {
+		var flags C.int
+		if i != len(frames)-1 {
+			flags = C.ZMQ_SNDMORE
+		}
+
+		if err := sock.doSend(frame, flags); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (sock *Socket) AddSubscribeFilter(prefix []byte) error {
+	if err := sock.lock(); err != nil {
+		return err
+	}
+	defer sock.unlock()
+
 	var p unsafe.Pointer
 	var l C.size_t
 
@@ -294,6 +569,11 @@ func (sock *Socket) AddSubscribeFilter(prefix []byte) error {
 }
 
 func (sock *Socket) RemoveSubscribeFilter(prefix []byte) error {
+	if err := sock.lock(); err != nil {
+		return err
+	}
+	defer sock.unlock()
+
 	var p unsafe.Pointer
 	var l C.size_t
 
@@ -315,6 +595,11 @@ func (sock *Socket) RemoveSubscribeFilter(prefix []byte) error {
 }
 
 func (sock *Socket) GetLastEndpoint() (string, error) {
+	if err := sock.lock(); err != nil {
+		return "", err
+	}
+	defer sock.unlock()
+
 	buf := make([]byte, 512)
 	l := C.size_t(len(buf))
 
@@ -355,6 +640,11 @@ func (sock *Socket) updateEventsState() (C.int, error) {
 }
 
 func (sock *Socket) IsUnblockedForRecv() (bool, error) {
+	if err := sock.lock(); err != nil {
+		return false, err
+	}
+	defer sock.unlock()
+
 	bitmask, err := sock.updateEventsState()
 	if err != nil {
 		return false, fmt.Errorf("updateEventsState() failed: %v", err)
@@ -0,0 +1,89 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package zmq
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentSendMultipart(t *testing.T) {
+	const senders = 8
+	const messagesPerSender = 100
+
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	pull, push := createConnectedPairOrFail(t, ctx, "inproc://concurrent", SocketPULL, SocketPUSH)
+	defer closeSocketOrFail(t, pull)
+	defer closeSocketOrFail(t, push)
+
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+
+		go func(id int) {
+			defer wg.Done()
+
+			for j := 0; j < messagesPerSender; j++ {
+				frame := []byte(fmt.Sprintf("%d-%d", id, j))
+				if err := push.SendMultipart([][]byte{frame, frame, frame}); err != nil {
+					t.Errorf("SendMultipart() failed: %v", err)
+					return
+				}
+			}
+		}(i)
+	}
+
+	if err := pull.SetRcvTimeout(5 * time.Second); err != nil {
+		t.Fatalf("SetRcvTimeout() failed: %v", err)
+	}
+
+	// frames of different messages must not interleave
+	for i := 0; i < senders*messagesPerSender; i++ {
+		frames := recvOrFail(t, pull)
+		if (len(frames) != 3) || (string(frames[0]) != string(frames[1])) ||
+			(string(frames[0]) != string(frames[2])) {
+			t.Fatalf("Unexpected frames %q", frames)
+		}
+	}
+
+	wg.Wait()
+}
+
+func TestCloseWhileInUse(t *testing.T) {
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	sock := createSocketOrFail(t, ctx, SocketPULL)
+	if err := sock.Bind("inproc://close-while-in-use"); err != nil {
+		t.Fatalf("Bind() failed: %v", err)
+	}
+
+	doneChan := make(chan error)
+	go func() {
+		for {
+			_, err, _ := sock.RecvMsgNonBlocking()
+			if err != nil {
+				doneChan <- err
+				return
+			}
+		}
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	closeSocketOrFail(t, sock)
+
+	select {
+	case err := <-doneChan:
+		if !errors.Is(err, ErrSocketClosed) {
+			t.Fatalf("RecvMsgNonBlocking() returned '%v', expected '%v'", err, ErrSocketClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("The receiving goroutine did not notice the socket was closed")
+	}
+}
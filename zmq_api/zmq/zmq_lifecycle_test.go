@@ -0,0 +1,153 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package zmq
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUseAfterClose(t *testing.T) {
+	ctx := createContextOrFail(t)
+
+	sock := createSocketOrFail(t, ctx, SocketSUB)
+	closeSocketOrFail(t, sock)
+
+	checks := map[string]error{
+		"Close()":              sock.Close(),
+		"Bind()":               sock.Bind("inproc://closed"),
+		"Connect()":            sock.Connect("inproc://closed"),
+		"AddSubscribeFilter()": sock.AddSubscribeFilter(nil),
+		"SetLinger()":          sock.SetLinger(0),
+		"Send()":               sock.Send([]byte("data")),
+	}
+
+	_, err := sock.RecvMsg()
+	checks["RecvMsg()"] = err
+
+	_, err = sock.GetFd()
+	checks["GetFd()"] = err
+
+	for name, err := range checks {
+		if !errors.Is(err, ErrSocketClosed) {
+			t.Fatalf("%s on a closed socket returned '%v', expected '%v'",
+				name, err, ErrSocketClosed)
+		}
+	}
+
+	terminateContextOrFail(t, ctx)
+
+	if err := ctx.Terminate(); !errors.Is(err, ErrContextTerminated) {
+		t.Fatalf("Terminate() on a terminated context returned '%v'", err)
+	}
+
+	if _, err := NewSocket(ctx, SocketPUB); !errors.Is(err, ErrContextTerminated) {
+		t.Fatalf("NewSocket() on a terminated context returned '%v'", err)
+	}
+}
+
+func TestSocketFinalizer(t *testing.T) {
+	ctx := createContextOrFail(t)
+
+	func() {
+		sock := createSocketOrFail(t, ctx, SocketPUSH)
+		if err := sock.Connect("tcp://127.0.0.1:5590"); err != nil {
+			t.Fatalf("Connect() failed: %v", err)
+		}
+
+		// a pending message must not block the termination either
+		if err := sock.Send([]byte("data")); err != nil {
+			t.Fatalf("Send() failed: %v", err)
+		}
+	}()
+
+	runtime.GC()
+
+	doneChan := make(chan error)
+	go func() {
+		doneChan <- ctx.Terminate()
+	}()
+
+	select {
+	case err := <-doneChan:
+		if err != nil {
+			t.Fatalf("Terminate() failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("The abandoned socket was not closed by the finalizer")
+	}
+}
+
+// returns the resident set size of the process in bytes
+func getRSS(t *testing.T) int {
+	data, err := ioutil.ReadFile("/proc/self/statm")
+	if os.IsNotExist(err) {
+		t.Skip("/proc/self/statm is not available")
+	}
+
+	if err != nil {
+		t.Fatalf("Unable to read statm: %v", err)
+	}
+
+	var size, resident int
+	if _, err := fmt.Sscanf(string(data), "%d %d", &size, &resident); err != nil {
+		t.Fatalf("Unable to parse statm '%s': %v", string(data), err)
+	}
+
+	return resident * os.Getpagesize()
+}
+
+func openAndCloseSockets(t *testing.T, ctx *Context, n int) {
+	// long endpoints make leaked C strings noticeable
+	longName := strings.Repeat("x", 4096)
+
+	for i := 0; i < n; i++ {
+		sock := createSocketOrFail(t, ctx, SocketPAIR)
+		endpoint := fmt.Sprintf("inproc://%s-%d", longName, i)
+
+		if err := sock.Bind(endpoint); err != nil {
+			t.Fatalf("Bind() failed: %v", err)
+		}
+
+		if err := sock.Unbind(endpoint); err != nil {
+			t.Fatalf("Unbind() failed: %v", err)
+		}
+
+		if err := sock.Connect(endpoint); err != nil {
+			t.Fatalf("Connect() failed: %v", err)
+		}
+
+		closeSocketOrFail(t, sock)
+	}
+}
+
+func TestSocketStress(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipped in the short mode")
+	}
+
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	// let the allocators reach a steady state first
+	openAndCloseSockets(t, ctx, 1000)
+	runtime.GC()
+	before := getRSS(t)
+
+	openAndCloseSockets(t, ctx, 5000)
+	runtime.GC()
+	after := getRSS(t)
+
+	// leaking the three endpoint strings per iteration would be 60MB
+	const maxGrowth = 16 * 1024 * 1024
+	if after-before > maxGrowth {
+		t.Fatalf("RSS grew from %d to %d bytes", before, after)
+	}
+}
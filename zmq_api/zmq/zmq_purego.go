@@ -0,0 +1,172 @@
+//go:build zmq_purego || !cgo
+// +build zmq_purego !cgo
+
+package zmq
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api/zmtp"
+)
+
+// Context only tracks whether it is terminated, as the pure Go
+// sockets do not share anything
+type Context struct {
+	terminated bool
+	mux        sync.RWMutex
+}
+
+func NewContext() (*Context, error) {
+	return &Context{}, nil
+}
+
+// Terminate does not wait for the sockets to be closed, unlike libzmq
+func (ctx *Context) Terminate() error {
+	ctx.mux.Lock()
+	defer ctx.mux.Unlock()
+
+	if ctx.terminated {
+		return ErrContextTerminated
+	}
+
+	ctx.terminated = true
+
+	return nil
+}
+
+// A Socket may be used from several goroutines
+type Socket struct {
+	sock *zmtp.Socket
+
+	// guards the options and the closed flag
+	mux    sync.Mutex
+	closed bool
+	linger time.Duration
+}
+
+func NewSocket(ctx *Context, sockType socketType) (*Socket, error) {
+	ctx.mux.RLock()
+	defer ctx.mux.RUnlock()
+
+	if ctx.terminated {
+		return nil, ErrContextTerminated
+	}
+
+	var tp zmtp.SocketType
+
+	switch sockType {
+	case SocketPUB:
+		tp = zmtp.PUB
+	case SocketSUB:
+		tp = zmtp.SUB
+	default:
+		return nil, fmt.Errorf("socket type %v: %w", sockType, ErrNotSupported)
+	}
+
+	sock, err := zmtp.NewSocket(tp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Socket{sock: sock, linger: -1}, nil
+}
+
+func convertError(err error) error {
+	if errors.Is(err, zmtp.ErrClosed) {
+		return ErrSocketClosed
+	}
+
+	return err
+}
+
+func (sock *Socket) Close() error {
+	sock.mux.Lock()
+	defer sock.mux.Unlock()
+
+	if sock.closed {
+		return ErrSocketClosed
+	}
+
+	sock.closed = true
+
+	return convertError(sock.sock.Close())
+}
+
+func (sock *Socket) Bind(endpoint string) error {
+	return convertError(sock.sock.Bind(endpoint))
+}
+
+func (sock *Socket) Unbind(endpoint string) error {
+	return ErrNotSupported
+}
+
+func (sock *Socket) Connect(endpoint string) error {
+	return convertError(sock.sock.Connect(endpoint))
+}
+
+func (sock *Socket) RecvMultipart() ([][]byte, error) {
+	ch := make(chan struct{}, 1)
+	sock.sock.Watch(ch)
+	defer sock.sock.Unwatch(ch)
+
+	for {
+		frames, received, err := sock.sock.Recv()
+		if err != nil {
+			return nil, convertError(err)
+		}
+
+		if received {
+			return frames, nil
+		}
+
+		<-ch
+	}
+}
+
+func (sock *Socket) RecvMultipartNonBlocking() ([][]byte, error, bool) {
+	frames, received, err := sock.sock.Recv()
+	if err != nil {
+		return nil, convertError(err), true
+	}
+
+	return frames, nil, received
+}
+
+func (sock *Socket) Send(p []byte) error {
+	if (p == nil) || (len(p) == 0) {
+		return nil
+	}
+
+	return convertError(sock.sock.Send([][]byte{p}))
+}
+
+// SendMultipart sends the frames as one multipart message,
+// empty frames are allowed
+func (sock *Socket) SendMultipart(frames [][]byte) error {
+	return convertError(sock.sock.Send(frames))
+}
+
+func (sock *Socket) AddSubscribeFilter(prefix []byte) error {
+	return convertError(sock.sock.Subscribe(prefix))
+}
+
+func (sock *Socket) RemoveSubscribeFilter(prefix []byte) error {
+	return convertError(sock.sock.Unsubscribe(prefix))
+}
+
+func (sock *Socket) GetLastEndpoint() (string, error) {
+	endpoint := sock.sock.LastEndpoint()
+	if endpoint == "" {
+		return "", fmt.Errorf("empty endpoint?")
+	}
+
+	return endpoint, nil
+}
+
+func (sock *Socket) IsUnblockedForRecv() (bool, error) {
+	events, err := sock.GetEvents()
+	return events&PollIn != 0, err
+}
@@ -0,0 +1,129 @@
+//go:build zmq_purego || !cgo
+// +build zmq_purego !cgo
+
+package zmq
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPureGoPubSub(t *testing.T) {
+	ctx, err := NewContext()
+	if err != nil {
+		t.Fatalf("NewContext() failed: %v", err)
+	}
+	defer ctx.Terminate()
+
+	if _, err := NewSocket(ctx, SocketREQ); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("NewSocket(REQ) returned %v", err)
+	}
+
+	pub, err := NewSocket(ctx, SocketPUB)
+	if err != nil {
+		t.Fatalf("NewSocket() failed: %v", err)
+	}
+	defer pub.Close()
+
+	if err := pub.Bind("tcp://127.0.0.1:5600"); err != nil {
+		t.Fatalf("Bind() failed: %v", err)
+	}
+
+	sub, err := NewSocket(ctx, SocketSUB)
+	if err != nil {
+		t.Fatalf("NewSocket() failed: %v", err)
+	}
+	defer sub.Close()
+
+	if err := sub.SetCurveServerKey("key"); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("SetCurveServerKey() returned %v", err)
+	}
+
+	monitor, err := NewMonitor(ctx, sub, EventConnected|EventHandshakeSucceeded)
+	if err != nil {
+		t.Fatalf("NewMonitor() failed: %v", err)
+	}
+	defer monitor.Close()
+
+	poller, err := NewPoller()
+	if err != nil {
+		t.Fatalf("NewPoller() failed: %v", err)
+	}
+	defer poller.Close()
+
+	if err := poller.AddSocket(sub, PollIn); err != nil {
+		t.Fatalf("AddSocket() failed: %v", err)
+	}
+
+	if err := sub.Connect("tcp://127.0.0.1:5600"); err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+
+	for _, expected := range []EventType{EventConnected, EventHandshakeSucceeded} {
+		select {
+		case e := <-monitor.Events():
+			if e.Type != expected {
+				t.Fatalf("Received event '%v', expected '%v'", e, expected)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("No '%v' event", expected)
+		}
+	}
+
+	if err := sub.AddSubscribeFilter(nil); err != nil {
+		t.Fatalf("AddSubscribeFilter() failed: %v", err)
+	}
+
+	var items []PollItem
+	for i := 0; (i < 100) && (len(items) == 0); i++ {
+		if err := pub.SendMultipart([][]byte{[]byte("topic"), []byte("data")}); err != nil {
+			t.Fatalf("SendMultipart() failed: %v", err)
+		}
+
+		items, err = poller.Poll(10 * time.Millisecond)
+		if err != nil {
+			t.Fatalf("Poll() failed: %v", err)
+		}
+	}
+
+	if (len(items) != 1) || (items[0].Socket != sub) || (items[0].Events != PollIn) {
+		t.Fatalf("Unexpected poll items %v", items)
+	}
+
+	// level-triggered
+	items, err = poller.Poll(0)
+	if (err != nil) || (len(items) != 1) {
+		t.Fatalf("Poll() returned %v, %v", items, err)
+	}
+
+	frames, err, received := sub.RecvMultipartNonBlocking()
+	if (err != nil) || (!received) || (len(frames) != 2) {
+		t.Fatalf("RecvMultipartNonBlocking() returned %q, %v, %v", frames, err, received)
+	}
+
+	if err := poller.RemoveSocket(sub); err != nil {
+		t.Fatalf("RemoveSocket() failed: %v", err)
+	}
+
+	if err := poller.Wakeup(); err != nil {
+		t.Fatalf("Wakeup() failed: %v", err)
+	}
+
+	items, err = poller.Poll(-1)
+	if (err != nil) || (len(items) != 0) {
+		t.Fatalf("Poll() after Wakeup() returned %v, %v", items, err)
+	}
+
+	if err := monitor.Close(); err != nil {
+		t.Fatalf("monitor Close() failed: %v", err)
+	}
+
+	if err := sub.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	if _, err := sub.RecvMultipart(); err != ErrSocketClosed {
+		t.Fatalf("RecvMultipart() after Close() returned %v", err)
+	}
+}
@@ -1,3 +1,6 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
 package zmq
 
 import (
@@ -245,3 +248,113 @@ func TestTruncatedRecv(t *testing.T) {
 		t.Fatalf("Unexpected error from Recv(): %v", err)
 	}
 }
+
+func TestRecvMsg(t *testing.T) {
+	const endpoint = "tcp://127.0.0.1:5559"
+
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	sock := createSocketOrFail(t, ctx, SocketSUB)
+	defer closeSocketOrFail(t, sock)
+
+	if err := sock.Connect(endpoint); err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+
+	if err := sock.AddSubscribeFilter([]byte("")); err != nil {
+		t.Fatalf("AddSubscribeFilter('') failed: %v", err)
+	}
+
+	dataChan := make(chan []byte)
+	defer close(dataChan)
+
+	err, errorChan := sendGoroutine(SocketPUB, endpoint, dataChan)
+	if err != nil {
+		t.Fatalf("Failed to initialize the send goroutine: %v", <-errorChan)
+	}
+
+	initSendGoroutine(t, dataChan, errorChan)
+
+	smallData := []byte("small")
+	largeData := bytes.Repeat([]byte("0123456789"), 100*1024)
+
+	for _, sentData := range [][]byte{smallData, largeData} {
+		dataChan <- sentData
+		if err = <-errorChan; err != nil {
+			t.Fatalf("Send() failed: %v", err)
+		}
+
+		recvData, err := sock.RecvMsg()
+		if err != nil {
+			t.Fatalf("RecvMsg() failed: %v", err)
+		}
+
+		if !bytes.Equal(recvData, sentData) {
+			t.Fatalf("Received %d bytes, expected %d bytes", len(recvData), len(sentData))
+		}
+	}
+
+	_, err, received := sock.RecvMsgNonBlocking()
+	if (err != nil) || (received) {
+		t.Fatalf("Unexpected result of RecvMsgNonBlocking() on an empty socket: %v, %v", err, received)
+	}
+}
+
+func TestSendRecvMultipart(t *testing.T) {
+	const endpoint = "tcp://127.0.0.1:5560"
+
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	pubSock := createSocketOrFail(t, ctx, SocketPUB)
+	defer closeSocketOrFail(t, pubSock)
+
+	if err := pubSock.Bind(endpoint); err != nil {
+		t.Fatalf("Bind() failed: %v", err)
+	}
+
+	subSock := createSocketOrFail(t, ctx, SocketSUB)
+	defer closeSocketOrFail(t, subSock)
+
+	if err := subSock.Connect(endpoint); err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+
+	topic := "sensors/1/"
+	if err := subSock.AddSubscribeFilter([]byte(topic)); err != nil {
+		t.Fatalf("AddSubscribeFilter('%s') failed: %v", topic, err)
+	}
+
+	// see initSendGoroutine()
+	time.Sleep(time.Second)
+
+	ignoredMsg := [][]byte{[]byte("sensors/2/temperature"), []byte("ignored")}
+	expectedMsg := [][]byte{[]byte("sensors/1/temperature"), []byte{}, []byte("payload")}
+
+	for _, msg := range [][][]byte{ignoredMsg, expectedMsg} {
+		if err := pubSock.SendMultipart(msg); err != nil {
+			t.Fatalf("SendMultipart() failed: %v", err)
+		}
+	}
+
+	frames, err := subSock.RecvMultipart()
+	if err != nil {
+		t.Fatalf("RecvMultipart() failed: %v", err)
+	}
+
+	if len(frames) != len(expectedMsg) {
+		t.Fatalf("Received %d frames, expected %d", len(frames), len(expectedMsg))
+	}
+
+	for i := range frames {
+		if !bytes.Equal(frames[i], expectedMsg[i]) {
+			t.Fatalf("Frame %d is '%s', expected '%s'", i, frames[i], expectedMsg[i])
+		}
+	}
+
+	_, err, received := subSock.RecvMultipartNonBlocking()
+	if (err != nil) || (received) {
+		t.Fatalf("Unexpected result of RecvMultipartNonBlocking() on an empty socket: %v, %v", err, received)
+	}
+}
@@ -0,0 +1,170 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package zmq
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// creates a pair of sockets connected via inproc
+func createConnectedPairOrFail(t *testing.T, ctx *Context, endpoint string,
+	bindType, connectType socketType) (*Socket, *Socket) {
+	bindSock := createSocketOrFail(t, ctx, bindType)
+	if err := bindSock.Bind(endpoint); err != nil {
+		t.Fatalf("Bind(%s) failed: %v", bindType, err)
+	}
+
+	connectSock := createSocketOrFail(t, ctx, connectType)
+	if err := connectSock.Connect(endpoint); err != nil {
+		t.Fatalf("Connect(%s) failed: %v", connectType, err)
+	}
+
+	return bindSock, connectSock
+}
+
+func sendOrFail(t *testing.T, sock *Socket, frames ...string) {
+	msg := make([][]byte, 0)
+	for _, frame := range frames {
+		msg = append(msg, []byte(frame))
+	}
+
+	if err := sock.SendMultipart(msg); err != nil {
+		t.Fatalf("SendMultipart(%v) failed: %v", frames, err)
+	}
+}
+
+func recvOrFail(t *testing.T, sock *Socket) [][]byte {
+	frames, err := sock.RecvMultipart()
+	if err != nil {
+		t.Fatalf("RecvMultipart() failed: %v", err)
+	}
+
+	return frames
+}
+
+func expectFrames(t *testing.T, got [][]byte, expected ...string) {
+	if len(got) != len(expected) {
+		t.Fatalf("Received %d frames, expected %d", len(got), len(expected))
+	}
+
+	for i := range got {
+		if !bytes.Equal(got[i], []byte(expected[i])) {
+			t.Fatalf("Frame %d is '%s', expected '%s'", i, got[i], expected[i])
+		}
+	}
+}
+
+func TestPushPull(t *testing.T) {
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	pull, push := createConnectedPairOrFail(t, ctx, "inproc://push_pull", SocketPULL, SocketPUSH)
+	defer closeSocketOrFail(t, pull)
+	defer closeSocketOrFail(t, push)
+
+	// unlike PUB, PUSH queues messages, so nothing is lost
+	for _, msg := range []string{"one", "two", "three"} {
+		sendOrFail(t, push, msg)
+	}
+
+	for _, msg := range []string{"one", "two", "three"} {
+		expectFrames(t, recvOrFail(t, pull), msg)
+	}
+}
+
+func TestReqRep(t *testing.T) {
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	rep, req := createConnectedPairOrFail(t, ctx, "inproc://req_rep", SocketREP, SocketREQ)
+	defer closeSocketOrFail(t, rep)
+	defer closeSocketOrFail(t, req)
+
+	for i := 0; i < 3; i++ {
+		sendOrFail(t, req, "ping")
+		expectFrames(t, recvOrFail(t, rep), "ping")
+
+		sendOrFail(t, rep, "pong")
+		expectFrames(t, recvOrFail(t, req), "pong")
+	}
+
+	// REQ must wait for the reply before sending the next request
+	sendOrFail(t, req, "ping")
+	if err := req.SendMultipart([][]byte{[]byte("ping again")}); err == nil {
+		t.Fatalf("REQ allowed two requests in a row")
+	}
+}
+
+func TestDealerRouter(t *testing.T) {
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	router, dealer := createConnectedPairOrFail(t, ctx, "inproc://dealer_router", SocketROUTER, SocketDEALER)
+	defer closeSocketOrFail(t, router)
+	defer closeSocketOrFail(t, dealer)
+
+	sendOrFail(t, dealer, "request")
+
+	// ROUTER prepends the routing id of the peer
+	frames := recvOrFail(t, router)
+	if len(frames) != 2 {
+		t.Fatalf("ROUTER received %d frames, expected 2", len(frames))
+	}
+	routingId := string(frames[0])
+	expectFrames(t, frames, routingId, "request")
+
+	// and uses it to route the reply
+	sendOrFail(t, router, routingId, "reply")
+	expectFrames(t, recvOrFail(t, dealer), "reply")
+}
+
+func TestXPubXSub(t *testing.T) {
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	xpub, xsub := createConnectedPairOrFail(t, ctx, "inproc://xpub_xsub", SocketXPUB, SocketXSUB)
+	defer closeSocketOrFail(t, xpub)
+	defer closeSocketOrFail(t, xsub)
+
+	// XSUB subscribes by sending a message, which XPUB receives
+	sendOrFail(t, xsub, "\x01topic")
+	expectFrames(t, recvOrFail(t, xpub), "\x01topic")
+
+	sendOrFail(t, xpub, "other")
+	sendOrFail(t, xpub, "topic data")
+	expectFrames(t, recvOrFail(t, xsub), "topic data")
+}
+
+func TestPair(t *testing.T) {
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	pair1, pair2 := createConnectedPairOrFail(t, ctx, "inproc://pair", SocketPAIR, SocketPAIR)
+	defer closeSocketOrFail(t, pair1)
+	defer closeSocketOrFail(t, pair2)
+
+	sendOrFail(t, pair1, "to 2")
+	expectFrames(t, recvOrFail(t, pair2), "to 2")
+
+	sendOrFail(t, pair2, "to 1", "with", "frames")
+	expectFrames(t, recvOrFail(t, pair1), "to 1", "with", "frames")
+}
+
+func TestNonBlockingRecvOnEmptySocket(t *testing.T) {
+	ctx := createContextOrFail(t)
+	defer terminateContextOrFail(t, ctx)
+
+	pull, push := createConnectedPairOrFail(t, ctx, "inproc://empty", SocketPULL, SocketPUSH)
+	defer closeSocketOrFail(t, pull)
+	defer closeSocketOrFail(t, push)
+
+	time.Sleep(10 * time.Millisecond)
+
+	_, err, received := pull.RecvMultipartNonBlocking()
+	if (err != nil) || (received) {
+		t.Fatalf("Unexpected result of RecvMultipartNonBlocking(): %v, %v", err, received)
+	}
+}
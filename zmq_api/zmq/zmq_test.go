@@ -1,3 +1,6 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
 package zmq
 
 import (
@@ -47,6 +50,15 @@ func TestSocketTypeString(t *testing.T) {
 
 	compareStrings(t, fmt.Sprint(SocketPUB), "PUB")
 	compareStrings(t, fmt.Sprint(SocketSUB), "SUB")
+	compareStrings(t, fmt.Sprint(SocketPUSH), "PUSH")
+	compareStrings(t, fmt.Sprint(SocketPULL), "PULL")
+	compareStrings(t, fmt.Sprint(SocketREQ), "REQ")
+	compareStrings(t, fmt.Sprint(SocketREP), "REP")
+	compareStrings(t, fmt.Sprint(SocketDEALER), "DEALER")
+	compareStrings(t, fmt.Sprint(SocketROUTER), "ROUTER")
+	compareStrings(t, fmt.Sprint(SocketXPUB), "XPUB")
+	compareStrings(t, fmt.Sprint(SocketXSUB), "XSUB")
+	compareStrings(t, fmt.Sprint(SocketPAIR), "PAIR")
 }
 
 func TestContext(t *testing.T) {
@@ -61,7 +73,9 @@ func TestSocketTypes(t *testing.T) {
 	ctx := createContextOrFail(t)
 	defer terminateContextOrFail(t, ctx)
 
-	types := [...]socketType{SocketPUB, SocketSUB}
+	types := [...]socketType{SocketPUB, SocketSUB, SocketPUSH, SocketPULL,
+		SocketREQ, SocketREP, SocketDEALER, SocketROUTER,
+		SocketXPUB, SocketXSUB, SocketPAIR}
 
 	for _, tp := range types {
 		sock, err := NewSocket(ctx, tp)
@@ -0,0 +1,191 @@
+package zmtp
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the same as the default ZMQ_HANDSHAKE_IVL
+const handshakeTimeout = 30 * time.Second
+
+// conn is a TCP connection after the ZMTP handshake
+type conn struct {
+	netConn net.Conn
+	r       *bufio.Reader
+
+	// serializes writing messages and commands
+	wmux sync.Mutex
+	w    *bufio.Writer
+
+	// larger frames are rejected, -1 means 'no limit'
+	maxMsgSize int64
+
+	// ZMTP 3.1 peers expect subscriptions as commands
+	peerMinor      byte
+	peerSocketType string
+}
+
+func handshake(netConn net.Conn, tp SocketType, asServer bool, maxMsgSize int64) (*conn, error) {
+	c := conn{netConn: netConn,
+		maxMsgSize: maxMsgSize,
+		r:          bufio.NewReader(netConn),
+		w:          bufio.NewWriter(netConn)}
+
+	netConn.SetDeadline(time.Now().Add(handshakeTimeout))
+
+	g := greeting{major: versionMajor, minor: versionMinor,
+		mechanism: mechanismNull, asServer: asServer}
+	if _, err := netConn.Write(g.marshal()); err != nil {
+		return nil, err
+	}
+
+	peerGreeting, err := readGreeting(c.r)
+	if err != nil {
+		return nil, fmt.Errorf("readGreeting() failed: %v", err)
+	}
+
+	if peerGreeting.mechanism != mechanismNull {
+		c.writeCommand(commandError, marshalError("unsupported security mechanism"))
+		return nil, fmt.Errorf("unsupported security mechanism '%s'", peerGreeting.mechanism)
+	}
+
+	c.peerMinor = peerGreeting.minor
+	if peerGreeting.major > versionMajor {
+		c.peerMinor = versionMinor
+	}
+
+	if err := c.writeCommand(commandReady, marshalProperty(propertySocketType, string(tp))); err != nil {
+		return nil, err
+	}
+
+	_, cmd, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case cmd == nil:
+		return nil, fmt.Errorf("a message received instead of READY")
+	case cmd.name == commandError:
+		return nil, fmt.Errorf("peer error: %s", parseError(cmd.data))
+	case cmd.name != commandReady:
+		return nil, fmt.Errorf("unexpected command %s", cmd.name)
+	}
+
+	metadata, err := parseMetadata(cmd.data)
+	if err != nil {
+		return nil, err
+	}
+
+	c.peerSocketType = metadata[strings.ToLower(propertySocketType)]
+	if !tp.compatible(c.peerSocketType) {
+		c.writeCommand(commandError, marshalError("incompatible socket type"))
+		return nil, fmt.Errorf("incompatible peer socket type '%s'", c.peerSocketType)
+	}
+
+	netConn.SetDeadline(time.Time{})
+
+	return &c, nil
+}
+
+func marshalError(reason string) []byte {
+	return append([]byte{byte(len(reason))}, reason...)
+}
+
+func parseError(data []byte) string {
+	if (len(data) == 0) || (len(data) < 1+int(data[0])) {
+		return "unknown error"
+	}
+
+	return string(data[1 : 1+int(data[0])])
+}
+
+// read returns either a message or a command
+func (c *conn) read() ([][]byte, *command, error) {
+	frames := make([][]byte, 0)
+
+	for {
+		flags, body, err := readFrame(c.r, c.maxMsgSize)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if flags&flagCommand != 0 {
+			if len(frames) != 0 {
+				return nil, nil, fmt.Errorf("a command inside a multipart message")
+			}
+
+			cmd, err := parseCommand(body)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return nil, &cmd, nil
+		}
+
+		frames = append(frames, body)
+
+		if flags&flagMore == 0 {
+			return frames, nil, nil
+		}
+	}
+}
+
+func (c *conn) writeMessage(frames [][]byte) error {
+	c.wmux.Lock()
+	defer c.wmux.Unlock()
+
+	for i, frame := range frames {
+		var flags byte
+		if i != len(frames)-1 {
+			flags = flagMore
+		}
+
+		if err := writeFrame(c.w, flags, frame); err != nil {
+			return err
+		}
+	}
+
+	return c.w.Flush()
+}
+
+func (c *conn) writeCommand(name string, data []byte) error {
+	c.wmux.Lock()
+	defer c.wmux.Unlock()
+
+	if err := writeFrame(c.w, flagCommand, marshalCommand(name, data)); err != nil {
+		return err
+	}
+
+	return c.w.Flush()
+}
+
+// ZMTP 3.0 peers expect subscriptions as messages starting with 1 (subscribe)
+// or 0 (cancel)
+func (c *conn) writeSubscription(prefix []byte, subscribe bool) error {
+	if c.peerMinor >= 1 {
+		name := commandCancel
+		if subscribe {
+			name = commandSubscribe
+		}
+
+		return c.writeCommand(name, prefix)
+	}
+
+	msg := make([]byte, 0, 1+len(prefix))
+	if subscribe {
+		msg = append(msg, 1)
+	} else {
+		msg = append(msg, 0)
+	}
+
+	return c.writeMessage([][]byte{append(msg, prefix...)})
+}
+
+func (c *conn) close() error {
+	return c.netConn.Close()
+}
@@ -0,0 +1,229 @@
+// Package zmtp is a pure Go implementation of the subset of ZMTP 3.x
+// (https://rfc.zeromq.org/spec/23/ and https://rfc.zeromq.org/spec/37/)
+// needed for PUB/SUB over TCP with the NULL security mechanism.
+//
+// The zmq package uses it when built without cgo or with
+// the zmq_purego build tag.
+package zmtp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+const (
+	greetingLen  = 64
+	signatureLen = 10
+
+	versionMajor = 3
+	versionMinor = 1
+
+	mechanismNull = "NULL"
+)
+
+type greeting struct {
+	major     byte
+	minor     byte
+	mechanism string
+	asServer  bool
+}
+
+func (g greeting) marshal() []byte {
+	b := make([]byte, greetingLen)
+
+	b[0] = 0xff
+	b[signatureLen-1] = 0x7f
+	b[signatureLen] = g.major
+	b[signatureLen+1] = g.minor
+	copy(b[12:32], g.mechanism)
+
+	if g.asServer {
+		b[32] = 1
+	}
+
+	return b
+}
+
+func readGreeting(r io.Reader) (greeting, error) {
+	b := make([]byte, greetingLen)
+
+	// the version is checked before reading the rest, as older
+	// versions have shorter greetings
+	if _, err := io.ReadFull(r, b[:signatureLen+1]); err != nil {
+		return greeting{}, err
+	}
+
+	if (b[0] != 0xff) || (b[signatureLen-1]&0x01 == 0) {
+		return greeting{}, fmt.Errorf("invalid signature")
+	}
+
+	if b[signatureLen] < versionMajor {
+		return greeting{}, fmt.Errorf("unsupported ZMTP version %d", b[signatureLen])
+	}
+
+	if _, err := io.ReadFull(r, b[signatureLen+1:]); err != nil {
+		return greeting{}, err
+	}
+
+	return greeting{major: b[signatureLen],
+		minor:     b[signatureLen+1],
+		mechanism: strings.TrimRight(string(b[12:32]), "\x00"),
+		asServer:  b[32] == 1}, nil
+}
+
+const (
+	flagMore    = 0x01
+	flagLong    = 0x02
+	flagCommand = 0x04
+)
+
+// larger frames are rejected instead of being allocated,
+// whatever Options.MaxMsgSize is
+const maxFrameSize = math.MaxInt32
+
+func writeFrame(w *bufio.Writer, flags byte, body []byte) error {
+	if len(body) > math.MaxUint8 {
+		header := make([]byte, 9)
+		header[0] = flags | flagLong
+		binary.BigEndian.PutUint64(header[1:], uint64(len(body)))
+
+		if _, err := w.Write(header); err != nil {
+			return err
+		}
+	} else {
+		if _, err := w.Write([]byte{flags, byte(len(body))}); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write(body)
+	return err
+}
+
+// maxSize is checked before the body is allocated, -1 means maxFrameSize.
+// Like ZMQ_MAXMSGSIZE, it applies to the message frames only.
+func readFrame(r *bufio.Reader, maxSize int64) (byte, []byte, error) {
+	flags, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if flags&^(flagMore|flagLong|flagCommand) != 0 {
+		return 0, nil, fmt.Errorf("invalid frame flags 0x%x", flags)
+	}
+
+	var size uint64
+
+	if flags&flagLong != 0 {
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return 0, nil, err
+		}
+
+		size = binary.BigEndian.Uint64(header)
+	} else {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		size = uint64(b)
+	}
+
+	if (maxSize < 0) || (maxSize > maxFrameSize) || (flags&flagCommand != 0) {
+		maxSize = maxFrameSize
+	}
+
+	if size > uint64(maxSize) {
+		return 0, nil, fmt.Errorf("frame of %d bytes exceeds the limit of %d bytes", size, maxSize)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+
+	return flags &^ flagLong, body, nil
+}
+
+const (
+	commandReady     = "READY"
+	commandError     = "ERROR"
+	commandSubscribe = "SUBSCRIBE"
+	commandCancel    = "CANCEL"
+	commandPing      = "PING"
+	commandPong      = "PONG"
+)
+
+type command struct {
+	name string
+	data []byte
+}
+
+func marshalCommand(name string, data []byte) []byte {
+	body := make([]byte, 0, 1+len(name)+len(data))
+	body = append(body, byte(len(name)))
+	body = append(body, name...)
+
+	return append(body, data...)
+}
+
+func parseCommand(body []byte) (command, error) {
+	if (len(body) == 0) || (len(body) < 1+int(body[0])) {
+		return command{}, fmt.Errorf("malformed command")
+	}
+
+	nameLen := int(body[0])
+
+	return command{name: string(body[1 : 1+nameLen]), data: body[1+nameLen:]}, nil
+}
+
+const propertySocketType = "Socket-Type"
+
+func marshalProperty(name string, value string) []byte {
+	b := make([]byte, 0, 1+len(name)+4+len(value))
+	b = append(b, byte(len(name)))
+	b = append(b, name...)
+
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(value)))
+	b = append(b, size...)
+
+	return append(b, value...)
+}
+
+// property names are case insensitive, so they are returned lower cased
+func parseMetadata(data []byte) (map[string]string, error) {
+	ret := make(map[string]string)
+	r := bytes.NewReader(data)
+
+	for r.Len() != 0 {
+		nameLen, _ := r.ReadByte()
+
+		name := make([]byte, nameLen)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return nil, fmt.Errorf("malformed metadata")
+		}
+
+		var valueLen uint32
+		if err := binary.Read(r, binary.BigEndian, &valueLen); err != nil {
+			return nil, fmt.Errorf("malformed metadata")
+		}
+
+		if int64(valueLen) > int64(r.Len()) {
+			return nil, fmt.Errorf("malformed metadata")
+		}
+
+		value := make([]byte, valueLen)
+		io.ReadFull(r, value)
+
+		ret[strings.ToLower(string(name))] = string(value)
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,129 @@
+package zmtp
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGreeting(t *testing.T) {
+	g := greeting{major: 3, minor: 1, mechanism: mechanismNull, asServer: true}
+
+	b := g.marshal()
+	if len(b) != greetingLen {
+		t.Fatalf("Greeting is %d bytes long, expected %d", len(b), greetingLen)
+	}
+
+	parsed, err := readGreeting(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("readGreeting() failed: %v", err)
+	}
+
+	if parsed != g {
+		t.Fatalf("Parsed '%#v', expected '%#v'", parsed, g)
+	}
+
+	// ZMTP 2.0
+	b[signatureLen] = 1
+	if _, err := readGreeting(bytes.NewReader(b)); (err == nil) ||
+		(!strings.Contains(err.Error(), "unsupported ZMTP version")) {
+		t.Fatalf("Unexpected error for an old version: %v", err)
+	}
+
+	if _, err := readGreeting(strings.NewReader("GET / HTTP/1.1\r\n")); err == nil {
+		t.Fatalf("readGreeting() did not fail for an invalid signature")
+	}
+}
+
+func TestFrames(t *testing.T) {
+	frames := []struct {
+		flags byte
+		body  []byte
+	}{
+		{0, []byte{}},
+		{flagMore, []byte("topic")},
+		{0, bytes.Repeat([]byte{'x'}, 256)},
+		{flagCommand, marshalCommand(commandPing, []byte{0, 0})},
+	}
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+
+	for _, f := range frames {
+		if err := writeFrame(w, f.flags, f.body); err != nil {
+			t.Fatalf("writeFrame() failed: %v", err)
+		}
+	}
+	w.Flush()
+
+	// the short and long size encodings
+	if buf.Len() != 2+2+len("topic")+9+256+2+1+len(commandPing)+2 {
+		t.Fatalf("Unexpected encoded length %d", buf.Len())
+	}
+
+	r := bufio.NewReader(&buf)
+	for i, expected := range frames {
+		flags, body, err := readFrame(r, -1)
+		if err != nil {
+			t.Fatalf("readFrame() of frame %d failed: %v", i, err)
+		}
+
+		if (flags != expected.flags) || (!bytes.Equal(body, expected.body)) {
+			t.Fatalf("Frame %d: read %x '%q', expected %x '%q'", i,
+				flags, body, expected.flags, expected.body)
+		}
+	}
+
+	cmd, err := parseCommand(frames[3].body)
+	if err != nil {
+		t.Fatalf("parseCommand() failed: %v", err)
+	}
+
+	if (cmd.name != commandPing) || (!bytes.Equal(cmd.data, []byte{0, 0})) {
+		t.Fatalf("Unexpected command '%#v'", cmd)
+	}
+
+	if _, _, err := readFrame(bufio.NewReader(bytes.NewReader([]byte{0x80, 0})), -1); err == nil {
+		t.Fatalf("readFrame() did not fail for invalid flags")
+	}
+
+	// the size is checked before reading the body
+	huge := []byte{flagLong, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	if _, _, err := readFrame(bufio.NewReader(bytes.NewReader(huge)), -1); err == nil {
+		t.Fatalf("readFrame() did not fail for a huge frame")
+	}
+
+	limited := []byte{0, 5, 'h', 'e', 'l', 'l', 'o'}
+	if _, _, err := readFrame(bufio.NewReader(bytes.NewReader(limited)), 4); err == nil {
+		t.Fatalf("readFrame() did not fail for a frame exceeding the limit")
+	}
+
+	if _, body, err := readFrame(bufio.NewReader(bytes.NewReader(limited)), 5); (err != nil) || (string(body) != "hello") {
+		t.Fatalf("readFrame() returned '%s' (%v), expected 'hello'", body, err)
+	}
+
+	// e.g. READY with the metadata is not limited
+	command := []byte{flagCommand, 5, 'h', 'e', 'l', 'l', 'o'}
+	if _, body, err := readFrame(bufio.NewReader(bytes.NewReader(command)), 4); (err != nil) || (string(body) != "hello") {
+		t.Fatalf("readFrame() returned '%s' (%v) for a command, expected 'hello'", body, err)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	data := append(marshalProperty(propertySocketType, "PUB"),
+		marshalProperty("Identity", "")...)
+
+	metadata, err := parseMetadata(data)
+	if err != nil {
+		t.Fatalf("parseMetadata() failed: %v", err)
+	}
+
+	if (len(metadata) != 2) || (metadata["socket-type"] != "PUB") {
+		t.Fatalf("Unexpected metadata '%v'", metadata)
+	}
+
+	if _, err := parseMetadata(data[:len(data)-6]); err == nil {
+		t.Fatalf("parseMetadata() did not fail for truncated metadata")
+	}
+}
@@ -0,0 +1,160 @@
+//go:build cgo && !zmq_purego
+// +build cgo,!zmq_purego
+
+package zmtp_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api/zmq"
+	"github.com/kholmanskikh/home_sensors/zmq_api/zmtp"
+)
+
+// the pure Go sockets against libzmq ones
+
+func TestInteropLibzmqPub(t *testing.T) {
+	ctx, err := zmq.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext() failed: %v", err)
+	}
+	defer ctx.Terminate()
+
+	pub, err := zmq.NewSocket(ctx, zmq.SocketPUB)
+	if err != nil {
+		t.Fatalf("NewSocket() failed: %v", err)
+	}
+	defer pub.Close()
+
+	if err := pub.Bind("tcp://127.0.0.1:7010"); err != nil {
+		t.Fatalf("Bind() failed: %v", err)
+	}
+
+	sub, err := zmtp.NewSocket(zmtp.SUB)
+	if err != nil {
+		t.Fatalf("NewSocket() failed: %v", err)
+	}
+	defer sub.Close()
+
+	if err := sub.Subscribe([]byte("measurement.")); err != nil {
+		t.Fatalf("Subscribe() failed: %v", err)
+	}
+
+	if err := sub.Connect("tcp://127.0.0.1:7010"); err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+
+	expected := [][]byte{[]byte("measurement.1"), bytes.Repeat([]byte{'x'}, 300)}
+
+	var received [][]byte
+	for i := 0; (i < 200) && (received == nil); i++ {
+		// filtered out by libzmq
+		if err := pub.SendMultipart([][]byte{[]byte("other"), []byte("data")}); err != nil {
+			t.Fatalf("SendMultipart() failed: %v", err)
+		}
+
+		if err := pub.SendMultipart(expected); err != nil {
+			t.Fatalf("SendMultipart() failed: %v", err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+
+		frames, ok, err := sub.Recv()
+		if err != nil {
+			t.Fatalf("Recv() failed: %v", err)
+		}
+
+		if ok {
+			received = frames
+		}
+	}
+
+	if (len(received) != 2) || (!bytes.Equal(received[0], expected[0])) ||
+		(!bytes.Equal(received[1], expected[1])) {
+		t.Fatalf("Received '%q', expected '%q'", received, expected)
+	}
+}
+
+func TestInteropLibzmqSub(t *testing.T) {
+	pub, err := zmtp.NewSocket(zmtp.PUB)
+	if err != nil {
+		t.Fatalf("NewSocket() failed: %v", err)
+	}
+	defer pub.Close()
+
+	if err := pub.Bind("tcp://127.0.0.1:7011"); err != nil {
+		t.Fatalf("Bind() failed: %v", err)
+	}
+
+	ctx, err := zmq.NewContext()
+	if err != nil {
+		t.Fatalf("NewContext() failed: %v", err)
+	}
+	defer ctx.Terminate()
+
+	sub, err := zmq.NewSocket(ctx, zmq.SocketSUB)
+	if err != nil {
+		t.Fatalf("NewSocket() failed: %v", err)
+	}
+	defer sub.Close()
+
+	// the pings must be answered
+	if err := sub.SetHeartbeatInterval(20 * time.Millisecond); err != nil {
+		t.Fatalf("SetHeartbeatInterval() failed: %v", err)
+	}
+
+	if err := sub.AddSubscribeFilter([]byte("measurement.")); err != nil {
+		t.Fatalf("AddSubscribeFilter() failed: %v", err)
+	}
+
+	if err := sub.Connect("tcp://127.0.0.1:7011"); err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+
+	expected := [][]byte{[]byte("measurement.1"), []byte("data")}
+
+	var received [][]byte
+	for i := 0; (i < 200) && (received == nil); i++ {
+		if err := pub.Send([][]byte{[]byte("other")}); err != nil {
+			t.Fatalf("Send() failed: %v", err)
+		}
+
+		if err := pub.Send(expected); err != nil {
+			t.Fatalf("Send() failed: %v", err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+
+		frames, err, ok := sub.RecvMultipartNonBlocking()
+		if err != nil {
+			t.Fatalf("RecvMultipartNonBlocking() failed: %v", err)
+		}
+
+		if ok {
+			received = frames
+		}
+	}
+
+	if (len(received) != 2) || (!bytes.Equal(received[0], expected[0])) ||
+		(!bytes.Equal(received[1], expected[1])) {
+		t.Fatalf("Received '%q', expected '%q'", received, expected)
+	}
+
+	// the connection survives a few heartbeat timeouts
+	time.Sleep(200 * time.Millisecond)
+
+	expected = [][]byte{[]byte("measurement.2"), []byte("data")}
+	if err := pub.Send(expected); err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+
+	received, err = sub.RecvMultipart()
+	if err != nil {
+		t.Fatalf("RecvMultipart() failed: %v", err)
+	}
+
+	if !bytes.Equal(received[0], expected[0]) {
+		t.Fatalf("Received '%q', expected '%q'", received, expected)
+	}
+}
@@ -0,0 +1,819 @@
+package zmtp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrClosed = errors.New("socket is closed")
+
+type SocketType string
+
+const (
+	PUB SocketType = "PUB"
+	SUB SocketType = "SUB"
+)
+
+func (tp SocketType) compatible(peer string) bool {
+	switch tp {
+	case PUB:
+		return (peer == "SUB") || (peer == "XSUB")
+	case SUB:
+		return (peer == "PUB") || (peer == "XPUB")
+	}
+
+	return false
+}
+
+// Options follow the ZMQ socket options of the same names.
+// Changes apply to new connections only.
+type Options struct {
+	// 0 means 'no limit'
+	SndHWM int
+	RcvHWM int
+
+	// a negative interval disables reconnection,
+	// 0 max means 'use only the interval'
+	ReconnectInterval    time.Duration
+	ReconnectIntervalMax time.Duration
+
+	// 0 interval disables heartbeats, 0 timeout means 'the interval'
+	HeartbeatInterval time.Duration
+	HeartbeatTimeout  time.Duration
+
+	// -1 means 'the OS default'. Only the idle time can be set, it is used
+	// as the probe interval too, the probe count is ignored.
+	TCPKeepalive         int
+	TCPKeepaliveIdle     time.Duration
+	TCPKeepaliveInterval time.Duration
+	TCPKeepaliveCount    int
+
+	// keep only the last message in the receive queue
+	Conflate bool
+
+	// the peers sending larger frames are disconnected, -1 means 'no limit'
+	MaxMsgSize int64
+}
+
+// DefaultOptions returns the libzmq defaults
+func DefaultOptions() Options {
+	return Options{SndHWM: 1000,
+		RcvHWM:               1000,
+		ReconnectInterval:    100 * time.Millisecond,
+		TCPKeepalive:         -1,
+		TCPKeepaliveIdle:     -1,
+		TCPKeepaliveInterval: -1,
+		TCPKeepaliveCount:    -1,
+		MaxMsgSize:           -1}
+}
+
+type EventType int
+
+const (
+	EventConnected EventType = iota
+	EventConnectDelayed
+	// Value is the reconnect interval in milliseconds
+	EventConnectRetried
+	EventListening
+	EventAccepted
+	EventDisconnected
+	EventHandshakeSucceeded
+	EventHandshakeFailed
+)
+
+type Event struct {
+	Type     EventType
+	Value    int
+	Endpoint string
+}
+
+// Socket is a PUB or SUB socket, it is safe for concurrent use.
+// Unlike ZMQ sockets, it may both connect and bind only TCP endpoints.
+type Socket struct {
+	tp SocketType
+
+	// stops the connecting, accepting and serving goroutines
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mux          sync.Mutex
+	closed       bool
+	opts         Options
+	peers        map[*peer]struct{}
+	listeners    []net.Listener
+	lastEndpoint string
+
+	// SUB only: the prefix reference counts and the received messages,
+	// queueCond is signalled when the queue shrinks
+	subscriptions map[string]int
+	queue         [][][]byte
+	queueCond     *sync.Cond
+	watchers      map[chan<- struct{}]struct{}
+
+	// the handler is called under the lock, so it is not called
+	// after SetEventHandler(nil) returns
+	eventMux     sync.Mutex
+	eventHandler func(e Event)
+}
+
+type peer struct {
+	conn     *conn
+	endpoint string
+
+	// the time of the last received frame, for the heartbeats
+	lastRecv int64
+
+	// PUB only, guarded by the socket lock
+	subscriptions map[string]int
+	queue         [][][]byte
+
+	// SUB only, the subscriptions to send, guarded by the socket lock
+	updates []subscriptionUpdate
+
+	// signals the write loop, so the socket lock is never held
+	// while writing to a stalled peer
+	queued chan struct{}
+
+	done chan struct{}
+}
+
+type subscriptionUpdate struct {
+	prefix    []byte
+	subscribe bool
+}
+
+func NewSocket(tp SocketType) (*Socket, error) {
+	if (tp != PUB) && (tp != SUB) {
+		return nil, fmt.Errorf("unsupported socket type '%s'", tp)
+	}
+
+	s := Socket{tp: tp,
+		opts:          DefaultOptions(),
+		peers:         make(map[*peer]struct{}),
+		subscriptions: make(map[string]int),
+		watchers:      make(map[chan<- struct{}]struct{})}
+	s.queueCond = sync.NewCond(&s.mux)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	return &s, nil
+}
+
+func (s *Socket) Type() SocketType {
+	return s.tp
+}
+
+func (s *Socket) Options() Options {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.opts
+}
+
+func (s *Socket) SetOptions(opts Options) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.opts = opts
+}
+
+// SetEventHandler sets the function called for each event of the socket,
+// nil removes it. The handler must not block.
+func (s *Socket) SetEventHandler(handler func(e Event)) {
+	s.eventMux.Lock()
+	defer s.eventMux.Unlock()
+
+	s.eventHandler = handler
+}
+
+func (s *Socket) emit(tp EventType, value int, endpoint string) {
+	s.eventMux.Lock()
+	defer s.eventMux.Unlock()
+
+	if s.eventHandler != nil {
+		s.eventHandler(Event{Type: tp, Value: value, Endpoint: endpoint})
+	}
+}
+
+// Watch makes the socket signal ch (without blocking) each time
+// a message is queued for receiving
+func (s *Socket) Watch(ch chan<- struct{}) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.watchers[ch] = struct{}{}
+}
+
+func (s *Socket) Unwatch(ch chan<- struct{}) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.watchers, ch)
+}
+
+func parseEndpoint(endpoint string) (string, error) {
+	const prefix = "tcp://"
+
+	if !strings.HasPrefix(endpoint, prefix) {
+		return "", fmt.Errorf("unsupported endpoint '%s', only tcp:// is supported", endpoint)
+	}
+
+	addr := strings.TrimPrefix(endpoint, prefix)
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint '%s': %v", endpoint, err)
+	}
+
+	if host == "*" {
+		host = ""
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
+
+// Connect connects to the endpoint in the background,
+// reconnecting according to the options
+func (s *Socket) Connect(endpoint string) error {
+	addr, err := parseEndpoint(endpoint)
+	if err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	s.wg.Add(1)
+	go s.connectLoop(endpoint, addr)
+
+	return nil
+}
+
+func (s *Socket) Bind(endpoint string) error {
+	addr, err := parseEndpoint(endpoint)
+	if err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	// the accepted connections get the OS default keepalive
+	// unless the options say otherwise, as with libzmq
+	lc := net.ListenConfig{KeepAlive: -1}
+	l, err := lc.Listen(s.ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	s.listeners = append(s.listeners, l)
+	s.lastEndpoint = "tcp://" + l.Addr().String()
+
+	s.wg.Add(1)
+	go s.acceptLoop(l, s.lastEndpoint)
+
+	s.emit(EventListening, 0, s.lastEndpoint)
+
+	return nil
+}
+
+// LastEndpoint returns the endpoint of the last Bind(), with the actual port
+func (s *Socket) LastEndpoint() string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.lastEndpoint
+}
+
+func (s *Socket) Close() error {
+	s.mux.Lock()
+
+	if s.closed {
+		s.mux.Unlock()
+		return ErrClosed
+	}
+
+	s.closed = true
+	s.cancel()
+
+	for _, l := range s.listeners {
+		l.Close()
+	}
+
+	for p := range s.peers {
+		p.conn.close()
+	}
+
+	s.queueCond.Broadcast()
+
+	// the watchers find out it is closed on the next Recv()
+	s.notifyWatchers()
+	s.mux.Unlock()
+
+	s.wg.Wait()
+
+	return nil
+}
+
+func (s *Socket) connectLoop(endpoint string, addr string) {
+	defer s.wg.Done()
+
+	opts := s.Options()
+	ivl := opts.ReconnectInterval
+
+	for {
+		s.emit(EventConnectDelayed, 0, endpoint)
+
+		dialer := net.Dialer{KeepAlive: -1}
+		netConn, err := dialer.DialContext(s.ctx, "tcp", addr)
+		if err == nil {
+			s.emit(EventConnected, 0, endpoint)
+
+			if s.serve(netConn, endpoint, false) {
+				ivl = opts.ReconnectInterval
+			}
+		}
+
+		if (s.ctx.Err() != nil) || (opts.ReconnectInterval < 0) {
+			return
+		}
+
+		s.emit(EventConnectRetried, int(ivl.Milliseconds()), endpoint)
+
+		select {
+		case <-time.After(ivl):
+		case <-s.ctx.Done():
+			return
+		}
+
+		// like libzmq, a max less than the interval is ignored
+		if opts.ReconnectIntervalMax > opts.ReconnectInterval {
+			ivl *= 2
+			if ivl > opts.ReconnectIntervalMax {
+				ivl = opts.ReconnectIntervalMax
+			}
+		}
+	}
+}
+
+func (s *Socket) acceptLoop(l net.Listener, endpoint string) {
+	defer s.wg.Done()
+
+	for {
+		netConn, err := l.Accept()
+		if err != nil {
+			if s.ctx.Err() != nil {
+				return
+			}
+
+			// e.g. too many open files
+			select {
+			case <-time.After(100 * time.Millisecond):
+				continue
+			case <-s.ctx.Done():
+				return
+			}
+		}
+
+		s.emit(EventAccepted, 0, endpoint)
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serve(netConn, endpoint, true)
+		}()
+	}
+}
+
+func setupKeepalive(netConn net.Conn, opts Options) {
+	tcpConn, ok := netConn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+
+	switch opts.TCPKeepalive {
+	case 0:
+		tcpConn.SetKeepAlive(false)
+	case 1:
+		tcpConn.SetKeepAlive(true)
+
+		if opts.TCPKeepaliveIdle > 0 {
+			tcpConn.SetKeepAlivePeriod(opts.TCPKeepaliveIdle)
+		}
+	}
+}
+
+// serve handles the connection until it is closed, it returns true
+// if the handshake succeeded
+func (s *Socket) serve(netConn net.Conn, endpoint string, asServer bool) bool {
+	defer s.emit(EventDisconnected, 0, endpoint)
+
+	opts := s.Options()
+	setupKeepalive(netConn, opts)
+
+	// Close() must be able to interrupt the handshake
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+
+	go func() {
+		select {
+		case <-s.ctx.Done():
+			netConn.Close()
+		case <-stopChan:
+		}
+	}()
+
+	c, err := handshake(netConn, s.tp, asServer, opts.MaxMsgSize)
+	if err != nil {
+		netConn.Close()
+		s.emit(EventHandshakeFailed, 0, endpoint)
+		return false
+	}
+
+	p := peer{conn: c,
+		endpoint:      endpoint,
+		lastRecv:      time.Now().UnixNano(),
+		subscriptions: make(map[string]int),
+		queued:        make(chan struct{}, 1),
+		done:          make(chan struct{})}
+
+	if !s.addPeer(&p) {
+		c.close()
+		return true
+	}
+
+	s.emit(EventHandshakeSucceeded, 0, endpoint)
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.writeLoop(&p)
+	}()
+
+	if opts.HeartbeatInterval > 0 {
+		timeout := opts.HeartbeatTimeout
+		if timeout <= 0 {
+			timeout = opts.HeartbeatInterval
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.heartbeat(opts.HeartbeatInterval, timeout)
+		}()
+	}
+
+	s.readLoop(&p)
+
+	s.removePeer(&p)
+	c.close()
+	close(p.done)
+	wg.Wait()
+
+	return true
+}
+
+// the subscriptions are queued under the lock not to race
+// with Subscribe() and Unsubscribe()
+func (s *Socket) addPeer(p *peer) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	s.peers[p] = struct{}{}
+
+	for prefix := range s.subscriptions {
+		p.queueSubscription([]byte(prefix), true)
+	}
+
+	return true
+}
+
+// queueSubscription must be called with the socket lock held
+func (p *peer) queueSubscription(prefix []byte, subscribe bool) {
+	p.updates = append(p.updates, subscriptionUpdate{prefix: prefix, subscribe: subscribe})
+
+	select {
+	case p.queued <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Socket) removePeer(p *peer) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.peers, p)
+}
+
+func (s *Socket) readLoop(p *peer) {
+	for {
+		frames, cmd, err := p.conn.read()
+		if err != nil {
+			return
+		}
+
+		atomic.StoreInt64(&p.lastRecv, time.Now().UnixNano())
+
+		if cmd != nil {
+			if !s.handleCommand(p, cmd) {
+				return
+			}
+
+			continue
+		}
+
+		if s.tp == SUB {
+			if !s.enqueue(frames) {
+				return
+			}
+
+			continue
+		}
+
+		// ZMTP 3.0 subscriptions, the other messages are dropped
+		if (len(frames) == 1) && (len(frames[0]) != 0) && (frames[0][0] <= 1) {
+			s.updatePeerSubscription(p, frames[0][1:], frames[0][0] == 1)
+		}
+	}
+}
+
+func (s *Socket) handleCommand(p *peer, cmd *command) bool {
+	switch cmd.name {
+	case commandPing:
+		// the TTL followed by the context to return
+		if len(cmd.data) < 2 {
+			return false
+		}
+
+		if err := p.conn.writeCommand(commandPong, cmd.data[2:]); err != nil {
+			return false
+		}
+	case commandError:
+		return false
+	case commandSubscribe, commandCancel:
+		if s.tp == PUB {
+			s.updatePeerSubscription(p, cmd.data, cmd.name == commandSubscribe)
+		}
+	}
+
+	return true
+}
+
+func (s *Socket) updatePeerSubscription(p *peer, prefix []byte, subscribe bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if subscribe {
+		p.subscriptions[string(prefix)] += 1
+		return
+	}
+
+	if p.subscriptions[string(prefix)] > 1 {
+		p.subscriptions[string(prefix)] -= 1
+	} else {
+		delete(p.subscriptions, string(prefix))
+	}
+}
+
+func matches(subscriptions map[string]int, frames [][]byte) bool {
+	for prefix := range subscriptions {
+		if bytes.HasPrefix(frames[0], []byte(prefix)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// enqueue blocks while the queue is full, making the peer stop sending,
+// it returns false if the socket is closed
+func (s *Socket) enqueue(frames [][]byte) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if !matches(s.subscriptions, frames) {
+		return true
+	}
+
+	if s.opts.Conflate {
+		s.queue = [][][]byte{frames}
+	} else {
+		for (!s.closed) && (s.opts.RcvHWM > 0) && (len(s.queue) >= s.opts.RcvHWM) {
+			s.queueCond.Wait()
+		}
+
+		if s.closed {
+			return false
+		}
+
+		s.queue = append(s.queue, frames)
+	}
+
+	s.notifyWatchers()
+
+	return true
+}
+
+func (s *Socket) notifyWatchers() {
+	for ch := range s.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (p *peer) heartbeat(ivl time.Duration, timeout time.Duration) {
+	ticker := time.NewTicker(ivl)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.done:
+			return
+		}
+
+		if time.Since(time.Unix(0, atomic.LoadInt64(&p.lastRecv))) > timeout {
+			p.conn.close()
+			return
+		}
+
+		// no TTL and an empty context
+		if err := p.conn.writeCommand(commandPing, []byte{0, 0}); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Socket) writeLoop(p *peer) {
+	for {
+		select {
+		case <-p.queued:
+		case <-p.done:
+			return
+		}
+
+		s.mux.Lock()
+		updates := p.updates
+		p.updates = nil
+		queue := p.queue
+		p.queue = nil
+		s.mux.Unlock()
+
+		for _, update := range updates {
+			if err := p.conn.writeSubscription(update.prefix, update.subscribe); err != nil {
+				p.conn.close()
+				return
+			}
+		}
+
+		for _, frames := range queue {
+			if err := p.conn.writeMessage(frames); err != nil {
+				p.conn.close()
+				return
+			}
+		}
+	}
+}
+
+// Subscribe makes a SUB socket receive messages starting with the prefix,
+// an empty prefix means 'everything'
+func (s *Socket) Subscribe(prefix []byte) error {
+	return s.updateSubscription(prefix, true)
+}
+
+func (s *Socket) Unsubscribe(prefix []byte) error {
+	return s.updateSubscription(prefix, false)
+}
+
+func (s *Socket) updateSubscription(prefix []byte, subscribe bool) error {
+	if s.tp != SUB {
+		return fmt.Errorf("not a SUB socket")
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	// like libzmq, each subscription is sent, but only the last
+	// cancellation of a prefix
+	if subscribe {
+		s.subscriptions[string(prefix)] += 1
+	} else {
+		count, ok := s.subscriptions[string(prefix)]
+		if !ok {
+			return nil
+		}
+
+		if count > 1 {
+			s.subscriptions[string(prefix)] -= 1
+			return nil
+		}
+
+		delete(s.subscriptions, string(prefix))
+	}
+
+	for p := range s.peers {
+		p.queueSubscription(append([]byte(nil), prefix...), subscribe)
+	}
+
+	return nil
+}
+
+// Send queues the message for all subscribed peers without blocking,
+// peers whose queue is full (see Options.SndHWM) miss it
+func (s *Socket) Send(frames [][]byte) error {
+	if s.tp != PUB {
+		return fmt.Errorf("not a PUB socket")
+	}
+
+	if len(frames) == 0 {
+		return nil
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	for p := range s.peers {
+		if !matches(p.subscriptions, frames) {
+			continue
+		}
+
+		if (s.opts.SndHWM > 0) && (len(p.queue) >= s.opts.SndHWM) {
+			continue
+		}
+
+		p.queue = append(p.queue, frames)
+
+		select {
+		case p.queued <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Recv returns the next received message without blocking,
+// false means there is none
+func (s *Socket) Recv() ([][]byte, bool, error) {
+	if s.tp != SUB {
+		return nil, false, fmt.Errorf("not a SUB socket")
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.closed {
+		return nil, false, ErrClosed
+	}
+
+	if len(s.queue) == 0 {
+		return nil, false, nil
+	}
+
+	frames := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+
+	s.queueCond.Broadcast()
+
+	return frames, true, nil
+}
+
+// Readable tells if Recv() is going to return a message
+func (s *Socket) Readable() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return len(s.queue) != 0
+}
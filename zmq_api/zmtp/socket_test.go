@@ -0,0 +1,378 @@
+package zmtp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func createSocketOrFail(t *testing.T, tp SocketType) *Socket {
+	s, err := NewSocket(tp)
+	if err != nil {
+		t.Fatalf("NewSocket() failed: %v", err)
+	}
+
+	return s
+}
+
+// recvOrFail sends the message until it is received, as
+// the subscriptions are not propagated immediately
+func recvOrFail(t *testing.T, pub *Socket, sub *Socket, frames [][]byte) [][]byte {
+	for i := 0; i < 200; i++ {
+		if err := pub.Send(frames); err != nil {
+			t.Fatalf("Send() failed: %v", err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+
+		received, ok, err := sub.Recv()
+		if err != nil {
+			t.Fatalf("Recv() failed: %v", err)
+		}
+
+		if ok {
+			return received
+		}
+	}
+
+	t.Fatalf("No message received")
+	return nil
+}
+
+func drain(t *testing.T, sub *Socket) {
+	for {
+		_, ok, err := sub.Recv()
+		if err != nil {
+			t.Fatalf("Recv() failed: %v", err)
+		}
+
+		if !ok {
+			return
+		}
+	}
+}
+
+func compareFrames(t *testing.T, received [][]byte, expected [][]byte) {
+	if len(received) != len(expected) {
+		t.Fatalf("Received '%q', expected '%q'", received, expected)
+	}
+
+	for i := range received {
+		if !bytes.Equal(received[i], expected[i]) {
+			t.Fatalf("Received '%q', expected '%q'", received, expected)
+		}
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	pub := createSocketOrFail(t, PUB)
+	defer pub.Close()
+
+	if err := pub.Bind("tcp://127.0.0.1:7000"); err != nil {
+		t.Fatalf("Bind() failed: %v", err)
+	}
+
+	sub := createSocketOrFail(t, SUB)
+	defer sub.Close()
+
+	if err := sub.Connect("tcp://127.0.0.1:7000"); err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+
+	if err := sub.Subscribe([]byte("a")); err != nil {
+		t.Fatalf("Subscribe() failed: %v", err)
+	}
+
+	expected := [][]byte{[]byte("a.topic"), []byte{}, bytes.Repeat([]byte{'x'}, 1000)}
+	compareFrames(t, recvOrFail(t, pub, sub, expected), expected)
+	drain(t, sub)
+
+	for _, frames := range [][][]byte{{[]byte("b.topic")}, {[]byte("a.last")}} {
+		if err := pub.Send(frames); err != nil {
+			t.Fatalf("Send() failed: %v", err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// only the subscribed topic
+	received, ok, err := sub.Recv()
+	if (err != nil) || (!ok) {
+		t.Fatalf("Recv() failed: %v, %v", err, ok)
+	}
+	compareFrames(t, received, [][]byte{[]byte("a.last")})
+
+	if sub.Readable() {
+		t.Fatalf("Readable() returned true for an empty queue")
+	}
+
+	if err := sub.Unsubscribe([]byte("a")); err != nil {
+		t.Fatalf("Unsubscribe() failed: %v", err)
+	}
+
+	if err := sub.Subscribe([]byte("b")); err != nil {
+		t.Fatalf("Subscribe() failed: %v", err)
+	}
+
+	expected = [][]byte{[]byte("b.topic")}
+	compareFrames(t, recvOrFail(t, pub, sub, expected), expected)
+
+	if err := sub.Send(expected); err == nil {
+		t.Fatalf("Send() on a SUB socket did not fail")
+	}
+
+	if _, _, err := pub.Recv(); err == nil {
+		t.Fatalf("Recv() on a PUB socket did not fail")
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	sub := createSocketOrFail(t, SUB)
+	defer sub.Close()
+
+	events := make(chan Event, 100)
+	sub.SetEventHandler(func(e Event) {
+		select {
+		case events <- e:
+		default:
+		}
+	})
+
+	if err := sub.Subscribe(nil); err != nil {
+		t.Fatalf("Subscribe() failed: %v", err)
+	}
+
+	// the publisher is not there yet
+	if err := sub.Connect("tcp://127.0.0.1:7001"); err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		pub := createSocketOrFail(t, PUB)
+
+		if err := pub.Bind("tcp://127.0.0.1:7001"); err != nil {
+			t.Fatalf("Bind() failed: %v", err)
+		}
+
+		expected := [][]byte{[]byte("data")}
+		compareFrames(t, recvOrFail(t, pub, sub, expected), expected)
+
+		if err := pub.Close(); err != nil {
+			t.Fatalf("Close() failed: %v", err)
+		}
+	}
+
+	sub.SetEventHandler(nil)
+	close(events)
+
+	counts := make(map[EventType]int)
+	for e := range events {
+		counts[e.Type] += 1
+	}
+
+	if (counts[EventHandshakeSucceeded] != 2) || (counts[EventDisconnected] < 1) ||
+		(counts[EventConnectRetried] < 1) {
+		t.Fatalf("Unexpected events %v", counts)
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	pub := createSocketOrFail(t, PUB)
+	defer pub.Close()
+
+	if err := pub.Bind("tcp://127.0.0.1:7002"); err != nil {
+		t.Fatalf("Bind() failed: %v", err)
+	}
+
+	sub := createSocketOrFail(t, SUB)
+	defer sub.Close()
+
+	opts := sub.Options()
+	opts.HeartbeatInterval = 20 * time.Millisecond
+	opts.HeartbeatTimeout = 100 * time.Millisecond
+	sub.SetOptions(opts)
+
+	disconnected := make(chan struct{}, 1)
+	sub.SetEventHandler(func(e Event) {
+		if e.Type == EventDisconnected {
+			select {
+			case disconnected <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	if err := sub.Connect("tcp://127.0.0.1:7002"); err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+
+	// the publisher answers the pings, so the connection stays
+	select {
+	case <-disconnected:
+		t.Fatalf("Disconnected despite the heartbeats")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	// a publisher which does not answer the pings
+	l, err := net.Listen("tcp", "127.0.0.1:7005")
+	if err != nil {
+		t.Fatalf("Listen() failed: %v", err)
+	}
+	defer l.Close()
+
+	go func() {
+		netConn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer netConn.Close()
+
+		if _, err := handshake(netConn, PUB, true, -1); err != nil {
+			return
+		}
+
+		time.Sleep(time.Second)
+	}()
+
+	if err := sub.Connect("tcp://127.0.0.1:7005"); err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+
+	select {
+	case <-disconnected:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("Not disconnected from a dead publisher")
+	}
+}
+
+func TestStalledPeer(t *testing.T) {
+	// a publisher which never reads
+	l, err := net.Listen("tcp", "127.0.0.1:7006")
+	if err != nil {
+		t.Fatalf("Listen() failed: %v", err)
+	}
+	defer l.Close()
+
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+
+	go func() {
+		netConn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer netConn.Close()
+
+		if _, err := handshake(netConn, PUB, true, -1); err != nil {
+			return
+		}
+
+		<-stopChan
+	}()
+
+	sub := createSocketOrFail(t, SUB)
+
+	connected := make(chan struct{}, 1)
+	sub.SetEventHandler(func(e Event) {
+		if e.Type == EventHandshakeSucceeded {
+			select {
+			case connected <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	if err := sub.Connect("tcp://127.0.0.1:7006"); err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatalf("Not connected")
+	}
+
+	// more than the socket buffers take
+	doneChan := make(chan struct{})
+	go func() {
+		defer close(doneChan)
+
+		prefix := make([]byte, 1024*1024)
+		for i := 0; i < 64; i++ {
+			prefix[0] = byte(i)
+			sub.Subscribe(prefix)
+		}
+
+		sub.Close()
+	}()
+
+	select {
+	case <-doneChan:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Subscribe() or Close() blocked on a stalled peer")
+	}
+}
+
+func TestConflate(t *testing.T) {
+	pub := createSocketOrFail(t, PUB)
+	defer pub.Close()
+
+	if err := pub.Bind("tcp://127.0.0.1:7003"); err != nil {
+		t.Fatalf("Bind() failed: %v", err)
+	}
+
+	sub := createSocketOrFail(t, SUB)
+	defer sub.Close()
+
+	opts := sub.Options()
+	opts.Conflate = true
+	sub.SetOptions(opts)
+
+	if err := sub.Subscribe(nil); err != nil {
+		t.Fatalf("Subscribe() failed: %v", err)
+	}
+
+	if err := sub.Connect("tcp://127.0.0.1:7003"); err != nil {
+		t.Fatalf("Connect() failed: %v", err)
+	}
+
+	recvOrFail(t, pub, sub, [][]byte{[]byte("first")})
+
+	for _, data := range []string{"1", "2", "3"} {
+		if err := pub.Send([][]byte{[]byte(data)}); err != nil {
+			t.Fatalf("Send() failed: %v", err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	received, ok, err := sub.Recv()
+	if (err != nil) || (!ok) {
+		t.Fatalf("Recv() failed: %v, %v", err, ok)
+	}
+	compareFrames(t, received, [][]byte{[]byte("3")})
+
+	if sub.Readable() {
+		t.Fatalf("More than one message queued")
+	}
+}
+
+func TestInvalidEndpoints(t *testing.T) {
+	sock := createSocketOrFail(t, SUB)
+	defer sock.Close()
+
+	for _, endpoint := range []string{"inproc://test", "ipc:///tmp/test", "tcp://nohost"} {
+		if err := sock.Connect(endpoint); err == nil {
+			t.Fatalf("Connect('%s') did not fail", endpoint)
+		}
+	}
+
+	if err := sock.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	if err := sock.Connect("tcp://127.0.0.1:7004"); err != ErrClosed {
+		t.Fatalf("Connect() after Close() returned %v", err)
+	}
+}
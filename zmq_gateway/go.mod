@@ -4,5 +4,8 @@ go 1.13
 
 require (
 	github.com/eclipse/paho.mqtt.golang v1.3.5
+	github.com/gorilla/websocket v1.4.2
 	github.com/kholmanskikh/home_sensors/zmq_api v0.0.0-20210628145029-2631ca9da45e
 )
+
+replace github.com/kholmanskikh/home_sensors/zmq_api => ../zmq_api
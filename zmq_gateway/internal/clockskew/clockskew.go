@@ -0,0 +1,231 @@
+package clockskew
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+)
+
+// Action is what Checker does with the timestamps of skewed receivers
+type Action int
+
+const (
+	// only flag the receiver
+	ActionLog Action = iota
+	// use the gateway's time
+	ActionReplace
+	// shift by the receiver's clock offset, keeping the intervals
+	// between the measurements
+	ActionCorrect
+)
+
+func ParseAction(s string) (Action, error) {
+	switch s {
+	case "", "log":
+		return ActionLog, nil
+	case "replace":
+		return ActionReplace, nil
+	case "correct":
+		return ActionCorrect, nil
+	}
+
+	return ActionLog, fmt.Errorf("unknown clock skew action '%s'", s)
+}
+
+func (a Action) String() string {
+	switch a {
+	case ActionLog:
+		return "log"
+	case ActionReplace:
+		return "replace"
+	case ActionCorrect:
+		return "correct"
+	}
+
+	return fmt.Sprintf("unknown action %d", int(a))
+}
+
+// the offset is the median of the latest samples, so a single
+// delayed measurement does not affect it
+const offsetSamples = 5
+
+// a receiver which sent nothing for this long is forgotten, e.g. it
+// was replaced by one with another receiver id
+const receiverExpireAfter = 24 * time.Hour
+
+// ReceiverClock is the state of the clock of a receiver, identified
+// by the source and the receiver id of its measurements
+type ReceiverClock struct {
+	Source     string
+	ReceiverId string
+
+	// the receiver's time minus the gateway's one
+	Offset time.Duration
+	Skewed bool
+	// when Skewed last changed, zero if it never did
+	Since time.Time
+
+	// the number of measurements with replaced or corrected timestamps
+	Fixed uint64
+}
+
+type receiverKey struct {
+	source     string
+	receiverId string
+}
+
+type receiver struct {
+	clock    ReceiverClock
+	samples  []time.Duration
+	lastSeen time.Time
+}
+
+// Checker compares the timestamps of the measurements against the
+// gateway's clock. It is safe for concurrent use.
+type Checker struct {
+	Tolerance time.Duration
+	Action    Action
+
+	// the gateway's clock, replaced in tests
+	now func() time.Time
+
+	mux       sync.Mutex
+	receivers map[receiverKey]*receiver
+}
+
+func NewChecker(tolerance time.Duration, action Action) (*Checker, error) {
+	if tolerance <= 0 {
+		return nil, fmt.Errorf("invalid tolerance %v", tolerance)
+	}
+
+	return &Checker{Tolerance: tolerance,
+		Action:    action,
+		now:       time.Now,
+		receivers: make(map[receiverKey]*receiver)}, nil
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+
+	return d
+}
+
+func median(samples []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return sorted[len(sorted)/2]
+}
+
+// Check fixes the timestamp of m according to Action if it is off by
+// more than Tolerance. The receiver is flagged as skewed when the
+// median offset of its clock is more than Tolerance. The state of the
+// receiver's clock is returned if it became skewed or back in sync,
+// nil otherwise.
+func (c *Checker) Check(m *zmq_api.Measurement) *ReceiverClock {
+	now := c.now()
+
+	// an unset timestamp is as wrong as 1970
+	t := m.Time()
+	if t.IsZero() {
+		t = time.Unix(0, 0)
+	}
+	offset := t.Sub(now)
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.expire(now)
+
+	key := receiverKey{source: m.Source, receiverId: m.ReceiverId}
+
+	r, found := c.receivers[key]
+	if !found {
+		r = &receiver{clock: ReceiverClock{Source: m.Source, ReceiverId: m.ReceiverId}}
+		c.receivers[key] = r
+	}
+	r.lastSeen = now
+
+	r.samples = append(r.samples, offset)
+	if len(r.samples) > offsetSamples {
+		r.samples = r.samples[1:]
+	}
+	r.clock.Offset = median(r.samples)
+
+	// a single late or early message does not flip the state
+	skewed := abs(r.clock.Offset) > c.Tolerance
+
+	var changed *ReceiverClock
+	if skewed != r.clock.Skewed {
+		r.clock.Skewed = skewed
+		r.clock.Since = now
+
+		clock := r.clock
+		changed = &clock
+	}
+
+	// a single wrong timestamp is fixed even if the receiver is not
+	// flagged yet, e.g. the first messages after a reboot
+	if abs(offset) <= c.Tolerance {
+		return changed
+	}
+
+	switch c.Action {
+	case ActionReplace:
+		m.SetTime(now)
+		r.clock.Fixed += 1
+	case ActionCorrect:
+		corrected := t.Add(-r.clock.Offset)
+
+		// the offset has just changed, e.g. the receiver's clock
+		// was set, and the median is not there yet
+		if abs(corrected.Sub(now)) > c.Tolerance {
+			corrected = now
+		}
+
+		m.SetTime(corrected)
+		r.clock.Fixed += 1
+	}
+
+	return changed
+}
+
+// expire must be called with mux held
+func (c *Checker) expire(now time.Time) {
+	for key, r := range c.receivers {
+		if now.Sub(r.lastSeen) > receiverExpireAfter {
+			delete(c.receivers, key)
+		}
+	}
+}
+
+// Clocks returns the states of the receivers sorted by the source
+// and receiver id. A receiver which sent nothing for a day is dropped.
+func (c *Checker) Clocks() []ReceiverClock {
+	now := c.now()
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.expire(now)
+
+	ret := make([]ReceiverClock, 0, len(c.receivers))
+	for _, r := range c.receivers {
+		ret = append(ret, r.clock)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Source != ret[j].Source {
+			return ret[i].Source < ret[j].Source
+		}
+
+		return ret[i].ReceiverId < ret[j].ReceiverId
+	})
+
+	return ret
+}
@@ -0,0 +1,169 @@
+package clockskew
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+)
+
+func createCheckerOrFail(t *testing.T, action Action, now time.Time) *Checker {
+	c, err := NewChecker(time.Minute, action)
+	if err != nil {
+		t.Fatalf("NewChecker() failed: %v", err)
+	}
+
+	c.now = func() time.Time { return now }
+
+	return c
+}
+
+func measurementAt(source string, t time.Time) *zmq_api.Measurement {
+	m := &zmq_api.Measurement{DeviceId: 1, Type: zmq_api.KindTemperature, Source: source}
+	m.SetTime(t)
+
+	return m
+}
+
+func TestParseAction(t *testing.T) {
+	for _, name := range []string{"log", "replace", "correct"} {
+		a, err := ParseAction(name)
+		if (err != nil) || (a.String() != name) {
+			t.Fatalf("ParseAction('%s') returned %v, %v", name, a, err)
+		}
+	}
+
+	if a, err := ParseAction(""); (err != nil) || (a != ActionLog) {
+		t.Fatalf("ParseAction('') returned %v, %v", a, err)
+	}
+
+	if _, err := ParseAction("other"); err == nil {
+		t.Fatalf("ParseAction() did not fail for an unknown action")
+	}
+}
+
+func TestChecker(t *testing.T) {
+	if _, err := NewChecker(0, ActionLog); err == nil {
+		t.Fatalf("NewChecker() did not fail for a zero tolerance")
+	}
+
+	now := time.Unix(1600000000, 0)
+
+	checks := []struct {
+		action   Action
+		expected time.Time
+	}{
+		{ActionLog, time.Unix(3600, 0)},
+		{ActionReplace, now},
+		{ActionCorrect, now},
+	}
+
+	for _, check := range checks {
+		c := createCheckerOrFail(t, check.action, now)
+
+		// within the tolerance
+		m := measurementAt("a", now.Add(-30*time.Second))
+		if changed := c.Check(m); (changed != nil) || (!m.Time().Equal(now.Add(-30 * time.Second))) {
+			t.Fatalf("%v: Check() returned %v, time %v", check.action, changed, m.Time())
+		}
+
+		// a single stale message does not make the clock skewed,
+		// but its timestamp is fixed
+		m = measurementAt("a", time.Unix(3600, 0))
+		if changed := c.Check(m); (changed != nil) || (!m.Time().Equal(check.expected)) {
+			t.Fatalf("%v: Check() returned %v, time %v", check.action, changed, m.Time())
+		}
+
+		// the receiver booted without a clock
+		m = measurementAt("a", time.Unix(3600, 0))
+		changed := c.Check(m)
+		if (changed == nil) || (!changed.Skewed) || (changed.Source != "a") {
+			t.Fatalf("%v: Check() returned %v", check.action, changed)
+		}
+
+		if !m.Time().Equal(check.expected) {
+			t.Fatalf("%v: got time %v, expected %v", check.action, m.Time(), check.expected)
+		}
+
+		// another receiver is fine
+		if changed := c.Check(measurementAt("b", now)); changed != nil {
+			t.Fatalf("%v: Check() returned %v", check.action, changed)
+		}
+
+		// the clock is set, the median follows after a few messages
+		changed = nil
+		for i := 0; (i < 5) && (changed == nil); i++ {
+			changed = c.Check(measurementAt("a", now))
+		}
+
+		if (changed == nil) || (changed.Skewed) {
+			t.Fatalf("%v: Check() returned %v", check.action, changed)
+		}
+
+		clocks := c.Clocks()
+		if (len(clocks) != 2) || (clocks[0].Source != "a") || (clocks[1].Source != "b") {
+			t.Fatalf("%v: unexpected clocks %v", check.action, clocks)
+		}
+
+		fixed := uint64(2)
+		if check.action == ActionLog {
+			fixed = 0
+		}
+
+		if clocks[0].Fixed != fixed {
+			t.Fatalf("%v: %d fixed timestamps, expected %d", check.action, clocks[0].Fixed, fixed)
+		}
+	}
+}
+
+func TestCheckerCorrect(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	c := createCheckerOrFail(t, ActionCorrect, now)
+
+	// the receiver's clock is an hour ahead, the measurements
+	// keep their order after the correction
+	var last time.Time
+	for i := 0; i < 10; i++ {
+		sent := now.Add(time.Hour + time.Duration(i)*time.Second)
+		c.now = func() time.Time { return sent.Add(-time.Hour) }
+
+		m := measurementAt("a", sent)
+		c.Check(m)
+
+		if abs(m.Time().Sub(sent.Add(-time.Hour))) > time.Second {
+			t.Fatalf("Corrected %v to %v", sent, m.Time())
+		}
+
+		if !m.Time().After(last) {
+			t.Fatalf("Corrected time %v is not after %v", m.Time(), last)
+		}
+		last = m.Time()
+	}
+
+	clocks := c.Clocks()
+	if (len(clocks) != 1) || (abs(clocks[0].Offset-time.Hour) > time.Second) || (clocks[0].Fixed != 10) {
+		t.Fatalf("Unexpected clocks %v", clocks)
+	}
+}
+
+func TestCheckerExpire(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	c := createCheckerOrFail(t, ActionLog, now)
+	c.now = func() time.Time { return now }
+
+	c.Check(measurementAt("a", now))
+
+	now = now.Add(receiverExpireAfter / 2)
+	c.Check(measurementAt("b", now))
+
+	if clocks := c.Clocks(); len(clocks) != 2 {
+		t.Fatalf("Unexpected clocks %v", clocks)
+	}
+
+	now = now.Add(receiverExpireAfter/2 + time.Second)
+
+	clocks := c.Clocks()
+	if (len(clocks) != 1) || (clocks[0].Source != "b") {
+		t.Fatalf("Unexpected clocks %v", clocks)
+	}
+}
@@ -7,9 +7,30 @@ import (
 	"os"
 )
 
+type ZMQSource struct {
+	Name     string `json:"name"`
+	Endpoint string `json:"endpoint"`
+}
+
 type Config struct {
-	ZMQEndpoint string `json:"zmq_endpoint"`
-	Debug       bool   `json:"debug"`
+	ZMQEndpoint       string      `json:"zmq_endpoint"`
+	ZMQSources        []ZMQSource `json:"zmq_sources"`
+	ZMQMaxMessageSize int         `json:"zmq_max_message_size"`
+	ZMQTopicFilters   []string    `json:"zmq_topic_filters"`
+	Debug             bool        `json:"debug"`
+	DeadLetterFile    string      `json:"dead_letter_file"`
+
+	// in seconds, 0 disables the check
+	ClockSkewTolerance int    `json:"clock_skew_tolerance"`
+	ClockSkewAction    string `json:"clock_skew_action"`
+
+	// in seconds, see DefaultNodeOfflineAfter
+	NodeOfflineAfter int `json:"node_offline_after"`
+
+	// CURVE
+	ZMQCurveServerKey string `json:"zmq_curve_server_key"`
+	ZMQCurvePublicKey string `json:"zmq_curve_public_key"`
+	ZMQCurveSecretKey string `json:"zmq_curve_secret_key"`
 
 	Publisher string `json:"publisher"`
 
@@ -22,11 +43,40 @@ type Config struct {
 	MQTTUser     string `json:"mqtt_user"`
 	MQTTPassword string `json:"mqtt_password"`
 	MQTTTopic    string `json:"mqtt_topic"`
+
+	// Live stream
+	StreamListen       string `json:"stream_listen"`
+	StreamClientBuffer int    `json:"stream_client_buffer"`
 }
 
+// nodes which sent nothing for this long are offline
+const DefaultNodeOfflineAfter = 30 * 60
+
 var Format string = `{
     "zmq_endpint": "tcp://1.2.3.4:5555",
+    "zmq_sources": [{"name": "upstairs", "endpoint": "tcp://1.2.3.4:5555"}, ...],
+                    // instead of zmq_endpoint, to receive from several receivers,
+                       the measurements are tagged with the source name
+    "zmq_max_message_size": 65536, // optional, larger messages are dropped,
+                                      0 or not set means 65536, -1 means no limit
+    "zmq_topic_filters": ["sensors/1/", "sensors/2/temperature"], // optional, receive only
+                                            messages with these topic prefixes (requires
+                                            the receiver to send the topic frame)
+    "zmq_curve_server_key": "...", // optional, Z85 encoded public key of the receiver,
+                                      enables CURVE encryption
+    "zmq_curve_public_key": "...", // required with zmq_curve_server_key,
+    "zmq_curve_secret_key": "...", // the gateway's Z85 encoded keypair
     "debug": true of false, // optional
+    "dead_letter_file": "/path/to/file", // optional, messages which could not be
+                                            decoded are appended to it
+    "clock_skew_tolerance": 300, // optional, receivers whose timestamps are off
+                                    by more seconds than this are logged
+    "clock_skew_action": "log", "replace" or "correct", // optional, what to do with
+                                    the timestamps off by more than clock_skew_tolerance:
+                                    keep, replace with the gateway's time or shift by
+                                    the receiver's clock offset
+    "node_offline_after": 1800, // optional, a node which sent nothing for this
+                                   many seconds is reported offline
     "publisher": "web" or "mqtt",
 
     // web-only options
@@ -38,7 +88,14 @@ var Format string = `{
     "mqtt_broker": "...",
     "mqtt_user": "...", // optional,
     "mqtt_password": "...", // optional
-    "mqtt_topic": "..." // measurements are posted to <mqtt_topic>/<device_id>/<type>
+    "mqtt_topic": "...", // measurements are posted to <mqtt_topic>/<device_id>/<type>,
+                            the node states to <mqtt_topic>/<device_id>/status
+
+    // live stream options
+    "stream_listen": ":8080", // optional, serves measurements via SSE on /events
+                                 and WebSocket on /ws
+    "stream_client_buffer": 64 // optional, how many measurements may be queued
+                                  for a client before it is dropped
 }`
 
 func ParseFromFile(path string) (*Config, error) {
@@ -59,8 +116,30 @@ func ParseFromFile(path string) (*Config, error) {
 		return nil, fmt.Errorf("unable to parse json: %v", err)
 	}
 
-	if config.ZMQEndpoint == "" {
-		return nil, fmt.Errorf("zmq_endpoint must be set")
+	if err = validateZMQSources(&config); err != nil {
+		return nil, err
+	}
+
+	if config.ZMQMaxMessageSize < -1 {
+		return nil, fmt.Errorf("invalid value for zmq_max_message_size: %d",
+			config.ZMQMaxMessageSize)
+	}
+
+	if err = validateCurveConfig(&config); err != nil {
+		return nil, err
+	}
+
+	if err = validateClockSkewConfig(&config); err != nil {
+		return nil, err
+	}
+
+	if config.NodeOfflineAfter < 0 {
+		return nil, fmt.Errorf("invalid value for node_offline_after: %d",
+			config.NodeOfflineAfter)
+	}
+
+	if config.NodeOfflineAfter == 0 {
+		config.NodeOfflineAfter = DefaultNodeOfflineAfter
 	}
 
 	switch config.Publisher {
@@ -76,9 +155,91 @@ func ParseFromFile(path string) (*Config, error) {
 		return nil, err
 	}
 
+	if err = validateStreamConfig(&config); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
 
+func validateZMQSources(config *Config) error {
+	if (config.ZMQEndpoint == "") && (len(config.ZMQSources) == 0) {
+		return fmt.Errorf("zmq_endpoint or zmq_sources must be set")
+	}
+
+	if (config.ZMQEndpoint != "") && (len(config.ZMQSources) != 0) {
+		return fmt.Errorf("zmq_endpoint and zmq_sources must not be set together")
+	}
+
+	names := make(map[string]bool)
+	for i, source := range config.ZMQSources {
+		if (source.Name == "") || (source.Endpoint == "") {
+			return fmt.Errorf("zmq_sources[%d]: name and endpoint must be set", i)
+		}
+
+		if names[source.Name] {
+			return fmt.Errorf("zmq_sources[%d]: duplicate name '%s'", i, source.Name)
+		}
+		names[source.Name] = true
+	}
+
+	return nil
+}
+
+func validateCurveConfig(config *Config) error {
+	keys := []struct {
+		name  string
+		value string
+	}{
+		{"zmq_curve_server_key", config.ZMQCurveServerKey},
+		{"zmq_curve_public_key", config.ZMQCurvePublicKey},
+		{"zmq_curve_secret_key", config.ZMQCurveSecretKey},
+	}
+
+	set := 0
+	for _, key := range keys {
+		if key.value != "" {
+			set += 1
+		}
+	}
+
+	if set == 0 {
+		return nil
+	}
+
+	if set != len(keys) {
+		return fmt.Errorf("zmq_curve_server_key, zmq_curve_public_key and " +
+			"zmq_curve_secret_key must be set together")
+	}
+
+	for _, key := range keys {
+		if len(key.value) != 40 {
+			return fmt.Errorf("%s must be a 40 character Z85 encoded key", key.name)
+		}
+	}
+
+	return nil
+}
+
+func validateClockSkewConfig(config *Config) error {
+	if config.ClockSkewTolerance < 0 {
+		return fmt.Errorf("invalid value for clock_skew_tolerance: %d",
+			config.ClockSkewTolerance)
+	}
+
+	switch config.ClockSkewAction {
+	case "", "log", "replace", "correct":
+	default:
+		return fmt.Errorf("invalid value for clock_skew_action: '%s'", config.ClockSkewAction)
+	}
+
+	if (config.ClockSkewTolerance == 0) && (config.ClockSkewAction != "") {
+		return fmt.Errorf("clock_skew_action is set for a zero clock_skew_tolerance")
+	}
+
+	return nil
+}
+
 func validateWebConfig(config *Config) error {
 	if config.WebURL == "" {
 		return fmt.Errorf("web_url must be set")
@@ -107,3 +268,16 @@ func validateMQTTConfig(config *Config) error {
 
 	return nil
 }
+
+func validateStreamConfig(config *Config) error {
+	if config.StreamClientBuffer < 0 {
+		return fmt.Errorf("invalid value for stream_client_buffer: %d",
+			config.StreamClientBuffer)
+	}
+
+	if (config.StreamListen == "") && (config.StreamClientBuffer != 0) {
+		return fmt.Errorf("stream_client_buffer is set for an empty stream_listen")
+	}
+
+	return nil
+}
@@ -67,7 +67,8 @@ func TestParseFromFileWebPublisher(t *testing.T) {
 	}
 
 	if (config.ZMQEndpoint != "endpoint") || (!config.Debug) || (config.Publisher != "web") ||
-		(config.WebURL != "url") || (config.WebUpdateTypesInterval != 99) {
+		(config.WebURL != "url") || (config.WebUpdateTypesInterval != 99) ||
+		(config.NodeOfflineAfter != DefaultNodeOfflineAfter) {
 		t.Fatalf("fields are set incorrectly")
 	}
 }
@@ -124,7 +125,27 @@ func TestParseFromFileNoZMQEndpoint(t *testing.T) {
 	defer tf.Destroy()
 
 	_, err := ParseFromFile(tf.Name())
-	if err := checkError(err, "zmq_endpoint must be set"); err != nil {
+	if err := checkError(err, "zmq_endpoint or zmq_sources must be set"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseFromFileInvalidZMQMaxMessageSize(t *testing.T) {
+	tf := createTestFileOrFail(t, `{"zmq_endpoint": "endpoint", "zmq_max_message_size": -2}`)
+	defer tf.Destroy()
+
+	_, err := ParseFromFile(tf.Name())
+	if err := checkError(err, "invalid value for zmq_max_message_size: -2"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseFromFileInvalidNodeOfflineAfter(t *testing.T) {
+	tf := createTestFileOrFail(t, `{"zmq_endpoint": "endpoint", "node_offline_after": -1}`)
+	defer tf.Destroy()
+
+	_, err := ParseFromFile(tf.Name())
+	if err := checkError(err, "invalid value for node_offline_after: -1"); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -184,3 +205,110 @@ func TestValidateMQTTConfig(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestValidateClockSkewConfig(t *testing.T) {
+	config0 := Config{}
+	if err := validateClockSkewConfig(&config0); err != nil {
+		t.Fatalf("unexpected error for a valid config: %v", err)
+	}
+
+	config1 := Config{ClockSkewTolerance: 300, ClockSkewAction: "correct"}
+	if err := validateClockSkewConfig(&config1); err != nil {
+		t.Fatalf("unexpected error for a valid config: %v", err)
+	}
+
+	config2 := Config{ClockSkewTolerance: -1}
+	if err := checkError(validateClockSkewConfig(&config2), "invalid value for clock_skew_tolerance: -1"); err != nil {
+		t.Fatal(err)
+	}
+
+	config3 := Config{ClockSkewTolerance: 300, ClockSkewAction: "fix"}
+	if err := checkError(validateClockSkewConfig(&config3), "invalid value for clock_skew_action: 'fix'"); err != nil {
+		t.Fatal(err)
+	}
+
+	config4 := Config{ClockSkewAction: "replace"}
+	if err := checkError(validateClockSkewConfig(&config4), "clock_skew_action is set for a zero clock_skew_tolerance"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateStreamConfig(t *testing.T) {
+	config0 := Config{}
+	if err := validateStreamConfig(&config0); err != nil {
+		t.Fatalf("unexpected error for a valid config: %v", err)
+	}
+
+	config1 := Config{StreamListen: ":8080", StreamClientBuffer: 16}
+	if err := validateStreamConfig(&config1); err != nil {
+		t.Fatalf("unexpected error for a valid config: %v", err)
+	}
+
+	config2 := Config{StreamListen: ":8080", StreamClientBuffer: -1}
+	if err := checkError(validateStreamConfig(&config2), "invalid value for stream_client_buffer: -1"); err != nil {
+		t.Fatal(err)
+	}
+
+	config3 := Config{StreamClientBuffer: 16}
+	if err := checkError(validateStreamConfig(&config3), "stream_client_buffer is set for an empty stream_listen"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateCurveConfig(t *testing.T) {
+	key := strings.Repeat("a", 40)
+
+	config0 := Config{}
+	if err := validateCurveConfig(&config0); err != nil {
+		t.Fatalf("unexpected error for a valid config: %v", err)
+	}
+
+	config1 := Config{ZMQCurveServerKey: key, ZMQCurvePublicKey: key, ZMQCurveSecretKey: key}
+	if err := validateCurveConfig(&config1); err != nil {
+		t.Fatalf("unexpected error for a valid config: %v", err)
+	}
+
+	config2 := Config{ZMQCurveServerKey: key}
+	if err := checkError(validateCurveConfig(&config2),
+		"zmq_curve_server_key, zmq_curve_public_key and zmq_curve_secret_key must be set together"); err != nil {
+		t.Fatal(err)
+	}
+
+	config3 := Config{ZMQCurveServerKey: key, ZMQCurvePublicKey: "short", ZMQCurveSecretKey: key}
+	if err := checkError(validateCurveConfig(&config3),
+		"zmq_curve_public_key must be a 40 character Z85 encoded key"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateZMQSources(t *testing.T) {
+	config0 := Config{ZMQEndpoint: "tcp://1.2.3.4:5555"}
+	if err := validateZMQSources(&config0); err != nil {
+		t.Fatalf("unexpected error for a valid config: %v", err)
+	}
+
+	config1 := Config{ZMQSources: []ZMQSource{{"upstairs", "tcp://1.2.3.4:5555"},
+		{"shed", "tcp://1.2.3.5:5555"}}}
+	if err := validateZMQSources(&config1); err != nil {
+		t.Fatalf("unexpected error for a valid config: %v", err)
+	}
+
+	config2 := Config{ZMQEndpoint: "tcp://1.2.3.4:5555", ZMQSources: config1.ZMQSources}
+	if err := checkError(validateZMQSources(&config2),
+		"zmq_endpoint and zmq_sources must not be set together"); err != nil {
+		t.Fatal(err)
+	}
+
+	config3 := Config{ZMQSources: []ZMQSource{{"upstairs", ""}}}
+	if err := checkError(validateZMQSources(&config3),
+		"zmq_sources[0]: name and endpoint must be set"); err != nil {
+		t.Fatal(err)
+	}
+
+	config4 := Config{ZMQSources: []ZMQSource{{"upstairs", "tcp://1.2.3.4:5555"},
+		{"upstairs", "tcp://1.2.3.5:5555"}}}
+	if err := checkError(validateZMQSources(&config4),
+		"zmq_sources[1]: duplicate name 'upstairs'"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,56 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+)
+
+// FileSink appends messages which could not be decoded to a file,
+// one JSON object per line. The payload is base64 encoded.
+type FileSink struct {
+	Path string
+
+	file    *os.File
+	fileMux *sync.Mutex
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileSink{Path: path, file: file, fileMux: &sync.Mutex{}}, nil
+}
+
+func (sink *FileSink) Store(e *zmq_api.DecodeError, receivedAt time.Time) error {
+	type DeadLetter struct {
+		ReceivedAt time.Time `json:"received_at"`
+		Source     string    `json:"source,omitempty"`
+		Reason     string    `json:"reason"`
+		Payload    []byte    `json:"payload"`
+	}
+
+	data, err := json.Marshal(DeadLetter{ReceivedAt: receivedAt,
+		Source:  e.Source,
+		Reason:  e.Reason,
+		Payload: e.Payload})
+	if err != nil {
+		return fmt.Errorf("failed to marshal the data: %v", err)
+	}
+
+	sink.fileMux.Lock()
+	defer sink.fileMux.Unlock()
+
+	_, err = sink.file.Write(append(data, '\n'))
+	return err
+}
+
+func (sink *FileSink) Destroy() error {
+	return sink.file.Close()
+}
@@ -0,0 +1,74 @@
+package deadletter
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+)
+
+func TestFileSink(t *testing.T) {
+	dir, err := os.MkdirTemp("", "*")
+	if err != nil {
+		t.Fatalf("unable to create a temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "dead_letters.json")
+
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatalf("NewFileSink() failed: %v", err)
+	}
+
+	receivedAt := time.Unix(100, 0).UTC()
+	errs := []*zmq_api.DecodeError{
+		{Payload: []byte("not a json"), Reason: "reason 1"},
+		{Payload: []byte(`{"device_id": "x"}`), Reason: "reason 2", Source: "shed"},
+		{Payload: []byte{0xff, 0x00, 0x80}, Reason: "reason 3"},
+	}
+
+	for _, e := range errs {
+		if err := sink.Store(e, receivedAt); err != nil {
+			t.Fatalf("Store() failed: %v", err)
+		}
+	}
+
+	if err := sink.Destroy(); err != nil {
+		t.Fatalf("Destroy() failed: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read the sink file: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != len(errs) {
+		t.Fatalf("the sink file contains %d lines, expected %d", len(lines), len(errs))
+	}
+
+	for i, line := range lines {
+		var stored struct {
+			ReceivedAt time.Time `json:"received_at"`
+			Source     string    `json:"source"`
+			Reason     string    `json:"reason"`
+			Payload    []byte    `json:"payload"`
+		}
+
+		if err := json.Unmarshal([]byte(line), &stored); err != nil {
+			t.Fatalf("unable to parse line '%s': %v", line, err)
+		}
+
+		if (!stored.ReceivedAt.Equal(receivedAt)) || (stored.Source != errs[i].Source) ||
+			(stored.Reason != errs[i].Reason) ||
+			(!bytes.Equal(stored.Payload, errs[i].Payload)) {
+			t.Fatalf("line '%s' does not match %#v", line, *errs[i])
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package health
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+)
+
+// the message rate is averaged over this period
+const RateWindow = 10 * time.Minute
+
+// error codes, see ErrorCode()
+const (
+	ErrorLowPower           = "low_power"
+	ErrorTemperatureFailure = "temperature_failure"
+	ErrorHumidityFailure    = "humidity_failure"
+	ErrorInvalidTemperature = "invalid_temperature"
+	ErrorInvalidHumidity    = "invalid_humidity"
+	ErrorOther              = "other"
+)
+
+// ErrorCode maps the error descriptions set by radio_receiver
+// to the codes the nodes sent
+func ErrorCode(description string) string {
+	switch {
+	case description == "Low power":
+		return ErrorLowPower
+	case description == "Temperature measurement error":
+		return ErrorTemperatureFailure
+	case description == "Humidity measurement error":
+		return ErrorHumidityFailure
+	case strings.HasPrefix(description, "Temperature value"):
+		return ErrorInvalidTemperature
+	case strings.HasPrefix(description, "Humidity value"):
+		return ErrorInvalidHumidity
+	}
+
+	return ErrorOther
+}
+
+// NodeState is the health of a node, as seen by the gateway
+type NodeState struct {
+	DeviceId int       `json:"device_id"`
+	Online   bool      `json:"online"`
+	LastSeen time.Time `json:"last_seen"`
+
+	// all messages, including errors
+	Messages uint64 `json:"messages"`
+	// messages per minute over the last RateWindow
+	Rate float64 `json:"rate_per_minute"`
+	// the number of errors by their code
+	Errors map[string]uint64 `json:"errors,omitempty"`
+
+	// the node reported low power within the last OfflineAfter, it is
+	// kept when the node goes offline as the likely reason
+	LowPower bool `json:"low_power"`
+}
+
+type node struct {
+	state NodeState
+
+	// when the messages within RateWindow were received
+	recent       []time.Time
+	firstSeen    time.Time
+	lastLowPower time.Time
+}
+
+// Tracker is safe for concurrent use
+type Tracker struct {
+	// a node is offline if it sent nothing for this long
+	OfflineAfter time.Duration
+
+	mux   sync.Mutex
+	nodes map[int]*node
+}
+
+func NewTracker(offlineAfter time.Duration) (*Tracker, error) {
+	if offlineAfter <= 0 {
+		return nil, fmt.Errorf("invalid offline period %v", offlineAfter)
+	}
+
+	return &Tracker{OfflineAfter: offlineAfter, nodes: make(map[int]*node)}, nil
+}
+
+// called on each message too, not to keep them all for the nodes
+// nobody asks the state of
+func (n *node) trimRecent(now time.Time) {
+	for (len(n.recent) != 0) && (now.Sub(n.recent[0]) > RateWindow) {
+		n.recent = n.recent[1:]
+	}
+}
+
+func (n *node) snapshot(now time.Time) NodeState {
+	n.trimRecent(now)
+
+	// shorter for the nodes seen recently, but at least a minute
+	// not to report a huge rate after the first message
+	window := now.Sub(n.firstSeen)
+	if window > RateWindow {
+		window = RateWindow
+	}
+	if window < time.Minute {
+		window = time.Minute
+	}
+
+	state := n.state
+	state.Rate = float64(len(n.recent)) / window.Minutes()
+
+	state.Errors = make(map[string]uint64)
+	for code, count := range n.state.Errors {
+		state.Errors[code] = count
+	}
+
+	return state
+}
+
+// Record accounts the measurement received at now. The state of the node
+// is returned if it came online or started reporting low power, nil
+// otherwise.
+func (t *Tracker) Record(m zmq_api.Measurement, now time.Time) *NodeState {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	n, found := t.nodes[m.DeviceId]
+	if !found {
+		n = &node{state: NodeState{DeviceId: m.DeviceId, Errors: make(map[string]uint64)},
+			firstSeen: now}
+		t.nodes[m.DeviceId] = n
+	}
+
+	changed := !n.state.Online
+
+	// kept while the node was offline, e.g. the batteries were replaced since
+	if changed && n.state.LowPower && (now.Sub(n.lastLowPower) > t.OfflineAfter) {
+		n.state.LowPower = false
+	}
+
+	n.state.Online = true
+	n.state.LastSeen = now
+	n.state.Messages += 1
+	n.trimRecent(now)
+	n.recent = append(n.recent, now)
+
+	if m.Type == zmq_api.KindError {
+		code := ErrorCode(m.Error)
+		n.state.Errors[code] += 1
+
+		if code == ErrorLowPower {
+			n.lastLowPower = now
+
+			if !n.state.LowPower {
+				n.state.LowPower = true
+				changed = true
+			}
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	state := n.snapshot(now)
+	return &state
+}
+
+// Check returns the states of the nodes which went offline or
+// stopped reporting low power while online since the previous check
+func (t *Tracker) Check(now time.Time) []NodeState {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	changed := make([]NodeState, 0)
+
+	for _, id := range t.sortedIds() {
+		n := t.nodes[id]
+		stateChanged := false
+
+		if n.state.Online && (now.Sub(n.state.LastSeen) > t.OfflineAfter) {
+			n.state.Online = false
+			stateChanged = true
+		}
+
+		if n.state.Online && n.state.LowPower && (now.Sub(n.lastLowPower) > t.OfflineAfter) {
+			n.state.LowPower = false
+			stateChanged = true
+		}
+
+		if stateChanged {
+			changed = append(changed, n.snapshot(now))
+		}
+	}
+
+	return changed
+}
+
+// Nodes returns the states of all nodes sorted by the device id
+func (t *Tracker) Nodes(now time.Time) []NodeState {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	ret := make([]NodeState, 0, len(t.nodes))
+	for _, id := range t.sortedIds() {
+		ret = append(ret, t.nodes[id].snapshot(now))
+	}
+
+	return ret
+}
+
+func (t *Tracker) sortedIds() []int {
+	ids := make([]int, 0, len(t.nodes))
+	for id := range t.nodes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	return ids
+}
@@ -0,0 +1,132 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+)
+
+func createTrackerOrFail(t *testing.T) *Tracker {
+	tracker, err := NewTracker(30 * time.Minute)
+	if err != nil {
+		t.Fatalf("NewTracker() failed: %v", err)
+	}
+
+	return tracker
+}
+
+func TestErrorCode(t *testing.T) {
+	checks := map[string]string{
+		"Low power":                             ErrorLowPower,
+		"Temperature measurement error":         ErrorTemperatureFailure,
+		"Humidity measurement error":            ErrorHumidityFailure,
+		"Temperature value (1, 200) is invalid": ErrorInvalidTemperature,
+		"Humidity value (0, 0) is invalid":      ErrorInvalidHumidity,
+		"Other error":                           ErrorOther,
+	}
+
+	for description, expected := range checks {
+		if code := ErrorCode(description); code != expected {
+			t.Fatalf("ErrorCode('%s') returned '%s', expected '%s'", description, code, expected)
+		}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	if _, err := NewTracker(0); err == nil {
+		t.Fatalf("NewTracker() did not fail for a zero period")
+	}
+
+	tracker := createTrackerOrFail(t)
+	start := time.Unix(1600000000, 0)
+
+	temperature := zmq_api.Measurement{DeviceId: 1, Type: zmq_api.KindTemperature, Value: 20}
+	lowPower := zmq_api.Measurement{DeviceId: 1, Type: zmq_api.KindError, Error: "Low power"}
+
+	// a new node is online
+	state := tracker.Record(temperature, start)
+	if (state == nil) || (!state.Online) || (state.LowPower) {
+		t.Fatalf("Record() returned %v", state)
+	}
+
+	// one message a minute
+	for i := 1; i < 20; i++ {
+		if state := tracker.Record(temperature, start.Add(time.Duration(i)*time.Minute)); state != nil {
+			t.Fatalf("Record() returned %v", state)
+		}
+	}
+
+	now := start.Add(20 * time.Minute)
+
+	state = tracker.Record(lowPower, now)
+	if (state == nil) || (!state.LowPower) || (state.Errors[ErrorLowPower] != 1) {
+		t.Fatalf("Record() returned %v", state)
+	}
+
+	nodes := tracker.Nodes(now)
+	if (len(nodes) != 1) || (nodes[0].Messages != 21) || (nodes[0].Rate < 1) || (nodes[0].Rate > 1.2) {
+		t.Fatalf("Unexpected nodes %v", nodes)
+	}
+
+	if changed := tracker.Check(now.Add(time.Minute)); len(changed) != 0 {
+		t.Fatalf("Check() returned %v", changed)
+	}
+
+	// the batteries died, low power is kept as the reason
+	changed := tracker.Check(now.Add(31 * time.Minute))
+	if (len(changed) != 1) || (changed[0].Online) || (!changed[0].LowPower) ||
+		(!changed[0].LastSeen.Equal(now)) || (changed[0].Rate != 0) {
+		t.Fatalf("Check() returned %v", changed)
+	}
+
+	if changed := tracker.Check(now.Add(32 * time.Minute)); len(changed) != 0 {
+		t.Fatalf("Check() returned %v", changed)
+	}
+
+	if nodes := tracker.Nodes(now.Add(33 * time.Minute)); (len(nodes) != 1) || (!nodes[0].LowPower) {
+		t.Fatalf("Unexpected nodes %v", nodes)
+	}
+
+	// and were replaced
+	state = tracker.Record(temperature, now.Add(40*time.Minute))
+	if (state == nil) || (!state.Online) || (state.LowPower) {
+		t.Fatalf("Record() returned %v", state)
+	}
+}
+
+func TestTrackerLowPowerWhileOnline(t *testing.T) {
+	tracker := createTrackerOrFail(t)
+	start := time.Unix(1600000000, 0)
+
+	temperature := zmq_api.Measurement{DeviceId: 1, Type: zmq_api.KindTemperature, Value: 20}
+	lowPower := zmq_api.Measurement{DeviceId: 1, Type: zmq_api.KindError, Error: "Low power"}
+
+	tracker.Record(lowPower, start)
+
+	// the node keeps sending, but no more low power errors
+	for i := 1; i <= 31; i++ {
+		tracker.Record(temperature, start.Add(time.Duration(i)*time.Minute))
+	}
+
+	changed := tracker.Check(start.Add(31 * time.Minute))
+	if (len(changed) != 1) || (!changed[0].Online) || (changed[0].LowPower) {
+		t.Fatalf("Check() returned %v", changed)
+	}
+}
+
+func TestTrackerRecentTrimmed(t *testing.T) {
+	tracker := createTrackerOrFail(t)
+	start := time.Unix(1600000000, 0)
+
+	temperature := zmq_api.Measurement{DeviceId: 1, Type: zmq_api.KindTemperature, Value: 20}
+
+	// a message a second for an hour, no snapshots in between
+	for i := 0; i < 3600; i++ {
+		tracker.Record(temperature, start.Add(time.Duration(i)*time.Second))
+	}
+
+	if n := len(tracker.nodes[1].recent); n > int(RateWindow/time.Second)+1 {
+		t.Fatalf("%d recent messages kept", n)
+	}
+}
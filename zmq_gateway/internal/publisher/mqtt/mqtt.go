@@ -3,11 +3,12 @@ package mqtt
 import (
 	"encoding/json"
 	"fmt"
-	"strings"
 
 	MQTT "github.com/eclipse/paho.mqtt.golang"
 
 	"github.com/kholmanskikh/home_sensors/zmq_api"
+
+	"zmq_gateway/internal/health"
 )
 
 type MQTTPublisher struct {
@@ -46,19 +47,29 @@ func (publisher *MQTTPublisher) Destroy() error {
 }
 
 func (publisher *MQTTPublisher) PublishMeasurement(m zmq_api.Measurement) error {
-	path := fmt.Sprintf("%s/%d/%s", publisher.BaseTopic, m.DeviceId, strings.ToLower(m.Type))
+	path := fmt.Sprintf("%s/%d/%s", publisher.BaseTopic, m.DeviceId, m.Type.Name())
 
-	type MeasurementToPost struct {
-		Timestamp int     `json:"timestamp"`
-		Value     float64 `json:"value"`
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the data: %v", err)
 	}
 
-	data, err := json.Marshal(MeasurementToPost{Timestamp: m.Timestamp, Value: m.Value})
+	t := publisher.client.Publish(path, 0, false, data)
+	t.Wait()
+	return t.Error()
+}
+
+// PublishNodeState posts the retained state to <topic>/<device_id>/status,
+// so the subscribers get the latest state on connect
+func (publisher *MQTTPublisher) PublishNodeState(state health.NodeState) error {
+	path := fmt.Sprintf("%s/%d/status", publisher.BaseTopic, state.DeviceId)
+
+	data, err := json.Marshal(state)
 	if err != nil {
 		return fmt.Errorf("failed to marshal the data: %v", err)
 	}
 
-	t := publisher.client.Publish(path, 0, false, data)
+	t := publisher.client.Publish(path, 1, true, data)
 	t.Wait()
 	return t.Error()
 }
@@ -2,6 +2,8 @@ package publisher
 
 import (
 	"github.com/kholmanskikh/home_sensors/zmq_api"
+
+	"zmq_gateway/internal/health"
 )
 
 type Publisher interface {
@@ -9,3 +11,9 @@ type Publisher interface {
 	Description() string
 	Destroy() error
 }
+
+// NodeStatePublisher is implemented by the publishers which also
+// report the health of the nodes
+type NodeStatePublisher interface {
+	PublishNodeState(health.NodeState) error
+}
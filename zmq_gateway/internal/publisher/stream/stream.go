@@ -0,0 +1,369 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+)
+
+const (
+	DefaultClientBufferSize = 64
+
+	writeTimeout    = 10 * time.Second
+	shutdownTimeout = 5 * time.Second
+)
+
+// the key of the client's net.Conn in the request context
+type connContextKey struct{}
+
+// StreamPublisher serves every published measurement to the connected
+// HTTP clients via Server-Sent Events (/events) and WebSocket (/ws).
+// The gateway status is served as JSON on /status, see SetStatusFunc().
+//
+// Each client has a bounded queue. If a client does not keep up and
+// its queue is full, the client is dropped, so a slow browser never
+// stalls the gateway.
+type StreamPublisher struct {
+	ListenAddr       string
+	ClientBufferSize int
+
+	server   *http.Server
+	listener net.Listener
+	upgrader websocket.Upgrader
+
+	clients    map[*client]struct{}
+	clientsMux *sync.Mutex
+
+	statusFunc func() interface{}
+	statusMux  sync.Mutex
+}
+
+type client struct {
+	filter filter
+	queue  chan []byte
+}
+
+type filter struct {
+	deviceIds map[int]bool
+	types     map[string]bool
+}
+
+// The values of a query parameter may be given either by repeating
+// the parameter or as a comma separated list.
+func splitQueryValues(values []string) []string {
+	ret := make([]string, 0)
+
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			item = strings.TrimSpace(item)
+			if item != "" {
+				ret = append(ret, item)
+			}
+		}
+	}
+
+	return ret
+}
+
+func parseFilter(r *http.Request) (filter, error) {
+	f := filter{}
+	query := r.URL.Query()
+
+	deviceIds := splitQueryValues(query["device_id"])
+	if len(deviceIds) != 0 {
+		f.deviceIds = make(map[int]bool)
+		for _, v := range deviceIds {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				return f, fmt.Errorf("invalid device_id '%s'", v)
+			}
+
+			f.deviceIds[id] = true
+		}
+	}
+
+	types := splitQueryValues(query["type"])
+	if len(types) != 0 {
+		f.types = make(map[string]bool)
+		for _, v := range types {
+			f.types[zmq_api.ParseKind(v).Name()] = true
+		}
+	}
+
+	return f, nil
+}
+
+func (f filter) matches(m zmq_api.Measurement) bool {
+	if (f.deviceIds != nil) && (!f.deviceIds[m.DeviceId]) {
+		return false
+	}
+
+	if (f.types != nil) && (!f.types[m.Type.Name()]) {
+		return false
+	}
+
+	return true
+}
+
+func NewStreamPublisher(listenAddr string, clientBufferSize int) (*StreamPublisher, error) {
+	if clientBufferSize <= 0 {
+		clientBufferSize = DefaultClientBufferSize
+	}
+
+	publisher := StreamPublisher{ListenAddr: listenAddr,
+		ClientBufferSize: clientBufferSize}
+
+	publisher.clients = make(map[*client]struct{})
+	publisher.clientsMux = &sync.Mutex{}
+
+	// dashboards are usually served from a different origin
+	publisher.upgrader = websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+
+	var mux http.ServeMux
+	mux.HandleFunc("/events", publisher.handleSSE)
+	mux.HandleFunc("/ws", publisher.handleWebSocket)
+	mux.HandleFunc("/status", publisher.handleStatus)
+
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("Listen('%s') failed: %v", listenAddr, err)
+	}
+
+	publisher.listener = listener
+	publisher.server = &http.Server{Handler: &mux,
+		// the SSE handler sets the write deadlines on the connection
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, connContextKey{}, c)
+		}}
+
+	go func() {
+		err := publisher.server.Serve(listener)
+		if (err != nil) && (err != http.ErrServerClosed) {
+			log.Printf("Stream HTTP server failed: %v", err)
+		}
+	}()
+
+	return &publisher, nil
+}
+
+// Addr returns the address the HTTP server is listening on.
+func (publisher *StreamPublisher) Addr() string {
+	return publisher.listener.Addr().String()
+}
+
+func (publisher *StreamPublisher) Description() string {
+	return fmt.Sprintf("Stream Publisher (listen '%s')", publisher.Addr())
+}
+
+func (publisher *StreamPublisher) Destroy() error {
+	publisher.clientsMux.Lock()
+	for c := range publisher.clients {
+		publisher.dropClientLocked(c)
+	}
+	publisher.clientsMux.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return publisher.server.Shutdown(ctx)
+}
+
+// PublishMeasurement never blocks: clients whose queue is full are dropped.
+func (publisher *StreamPublisher) PublishMeasurement(m zmq_api.Measurement) error {
+	var data []byte
+
+	publisher.clientsMux.Lock()
+	defer publisher.clientsMux.Unlock()
+
+	for c := range publisher.clients {
+		if !c.filter.matches(m) {
+			continue
+		}
+
+		if data == nil {
+			var err error
+
+			data, err = json.Marshal(m)
+			if err != nil {
+				return fmt.Errorf("failed to marshal the data: %v", err)
+			}
+		}
+
+		select {
+		case c.queue <- data:
+		default:
+			log.Printf("Stream client is too slow, dropping it")
+			publisher.dropClientLocked(c)
+		}
+	}
+
+	return nil
+}
+
+func (publisher *StreamPublisher) addClient(f filter) *client {
+	c := &client{filter: f, queue: make(chan []byte, publisher.ClientBufferSize)}
+
+	publisher.clientsMux.Lock()
+	publisher.clients[c] = struct{}{}
+	publisher.clientsMux.Unlock()
+
+	return c
+}
+
+func (publisher *StreamPublisher) removeClient(c *client) {
+	publisher.clientsMux.Lock()
+	publisher.dropClientLocked(c)
+	publisher.clientsMux.Unlock()
+}
+
+// closing the queue tells the client's handler to return
+func (publisher *StreamPublisher) dropClientLocked(c *client) {
+	if _, found := publisher.clients[c]; !found {
+		return
+	}
+
+	delete(publisher.clients, c)
+	close(c.queue)
+}
+
+// SetStatusFunc sets the function returning the status served on /status,
+// the returned value is marshalled to JSON.
+func (publisher *StreamPublisher) SetStatusFunc(statusFunc func() interface{}) {
+	publisher.statusMux.Lock()
+	defer publisher.statusMux.Unlock()
+
+	publisher.statusFunc = statusFunc
+}
+
+func (publisher *StreamPublisher) handleStatus(w http.ResponseWriter, r *http.Request) {
+	publisher.statusMux.Lock()
+	statusFunc := publisher.statusFunc
+	publisher.statusMux.Unlock()
+
+	if statusFunc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	data, err := json.Marshal(statusFunc())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func (publisher *StreamPublisher) handleSSE(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// a dropped client must not block the handler in a write forever
+	conn, ok := r.Context().Value(connContextKey{}).(net.Conn)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	defer conn.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	flusher.Flush()
+
+	c := publisher.addClient(f)
+	defer publisher.removeClient(c)
+
+	for {
+		select {
+		case data, ok := <-c.queue:
+			if !ok {
+				return
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (publisher *StreamPublisher) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conn, err := publisher.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade() has already replied to the client
+		return
+	}
+	defer conn.Close()
+
+	c := publisher.addClient(f)
+	defer publisher.removeClient(c)
+
+	// we do not expect anything from the client, but have to read
+	// to process control frames and to notice when it goes away
+	closedChan := make(chan struct{})
+	go func() {
+		defer close(closedChan)
+
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case data, ok := <-c.queue:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, ""),
+					time.Now().Add(writeTimeout))
+				return
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-closedChan:
+			return
+		}
+	}
+}
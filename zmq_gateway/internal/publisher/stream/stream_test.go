@@ -0,0 +1,205 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+)
+
+type streamedMeasurement struct {
+	DeviceId  int     `json:"device_id"`
+	Type      string  `json:"type"`
+	Value     float64 `json:"value"`
+	Timestamp int     `json:"timestamp"`
+	Error     string  `json:"error"`
+}
+
+func createPublisherOrFail(t *testing.T, clientBufferSize int) *StreamPublisher {
+	publisher, err := NewStreamPublisher("127.0.0.1:0", clientBufferSize)
+	if err != nil {
+		t.Fatalf("NewStreamPublisher() failed: %v", err)
+	}
+
+	return publisher
+}
+
+// waits until the expected number of clients is registered
+func waitForClients(t *testing.T, publisher *StreamPublisher, n int) {
+	for i := 0; i < 100; i++ {
+		publisher.clientsMux.Lock()
+		l := len(publisher.clients)
+		publisher.clientsMux.Unlock()
+
+		if l == n {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("Expected %d connected clients", n)
+}
+
+func TestFilter(t *testing.T) {
+	r := httptest.NewRequest("GET", "/events?device_id=1,2&type=temperature", nil)
+	f, err := parseFilter(r)
+	if err != nil {
+		t.Fatalf("parseFilter() failed: %v", err)
+	}
+
+	checks := []struct {
+		m        zmq_api.Measurement
+		expected bool
+	}{
+		{zmq_api.Measurement{DeviceId: 1, Type: "Temperature"}, true},
+		{zmq_api.Measurement{DeviceId: 2, Type: "Temperature"}, true},
+		{zmq_api.Measurement{DeviceId: 3, Type: "Temperature"}, false},
+		{zmq_api.Measurement{DeviceId: 1, Type: "Humidity"}, false},
+	}
+
+	for _, check := range checks {
+		if got := f.matches(check.m); got != check.expected {
+			t.Fatalf("matches(%#v) returned %v, expected %v", check.m, got, check.expected)
+		}
+	}
+
+	r = httptest.NewRequest("GET", "/events?device_id=abc", nil)
+	if _, err := parseFilter(r); (err == nil) || (!strings.Contains(err.Error(), "device_id")) {
+		t.Fatalf("Unexpected error from parseFilter(): %v", err)
+	}
+}
+
+func TestSlowClientIsDropped(t *testing.T) {
+	publisher := createPublisherOrFail(t, 1)
+	defer publisher.Destroy()
+
+	c := publisher.addClient(filter{})
+
+	m := zmq_api.Measurement{DeviceId: 1, Type: "Temperature", Value: 20.5, Timestamp: 1}
+	for i := 0; i < 2; i++ {
+		if err := publisher.PublishMeasurement(m); err != nil {
+			t.Fatalf("PublishMeasurement() failed: %v", err)
+		}
+	}
+
+	waitForClients(t, publisher, 0)
+
+	// the queued measurement is still delivered, then the queue is closed
+	if _, ok := <-c.queue; !ok {
+		t.Fatalf("The queued measurement was lost")
+	}
+
+	if _, ok := <-c.queue; ok {
+		t.Fatalf("The queue of the dropped client is not closed")
+	}
+}
+
+func TestSSE(t *testing.T) {
+	publisher := createPublisherOrFail(t, 0)
+	defer publisher.Destroy()
+
+	resp, err := http.Get("http://" + publisher.Addr() + "/events?type=error")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("Unexpected Content-Type '%s'", contentType)
+	}
+
+	waitForClients(t, publisher, 1)
+
+	publisher.PublishMeasurement(zmq_api.Measurement{DeviceId: 1, Type: "Temperature", Value: 20.5, Timestamp: 1})
+	publisher.PublishMeasurement(zmq_api.Measurement{DeviceId: 2, Type: "Error", Timestamp: 2, Error: "Low power"})
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("ReadString() failed: %v", err)
+	}
+
+	if !strings.HasPrefix(line, "data: ") {
+		t.Fatalf("Unexpected line '%s'", line)
+	}
+
+	var received streamedMeasurement
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &received); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+
+	expected := streamedMeasurement{DeviceId: 2, Type: "Error", Timestamp: 2, Error: "Low power"}
+	if received != expected {
+		t.Fatalf("Received '%#v', expected '%#v'", received, expected)
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	publisher := createPublisherOrFail(t, 0)
+	defer publisher.Destroy()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+publisher.Addr()+"/ws?device_id=5", nil)
+	if err != nil {
+		t.Fatalf("Dial() failed: %v", err)
+	}
+	defer conn.Close()
+
+	waitForClients(t, publisher, 1)
+
+	publisher.PublishMeasurement(zmq_api.Measurement{DeviceId: 4, Type: "Humidity", Value: 40.0, Timestamp: 1})
+	publisher.PublishMeasurement(zmq_api.Measurement{DeviceId: 5, Type: "Humidity", Value: 45.5, Timestamp: 2})
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var received streamedMeasurement
+	if err := conn.ReadJSON(&received); err != nil {
+		t.Fatalf("ReadJSON() failed: %v", err)
+	}
+
+	expected := streamedMeasurement{DeviceId: 5, Type: "Humidity", Value: 45.5, Timestamp: 2}
+	if received != expected {
+		t.Fatalf("Received '%#v', expected '%#v'", received, expected)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	publisher := createPublisherOrFail(t, 0)
+	defer publisher.Destroy()
+
+	resp, err := http.Get("http://" + publisher.Addr() + "/status")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Got status %d without a status function", resp.StatusCode)
+	}
+
+	publisher.SetStatusFunc(func() interface{} {
+		return map[string]bool{"connected": true}
+	})
+
+	resp, err = http.Get("http://" + publisher.Addr() + "/status")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var status map[string]bool
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("Decode() failed: %v", err)
+	}
+
+	if !status["connected"] {
+		t.Fatalf("Unexpected status %v", status)
+	}
+}
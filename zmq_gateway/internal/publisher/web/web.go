@@ -21,7 +21,7 @@ type WebPublisher struct {
 	mtypesUrl       string
 	measurementsUrl string
 
-	mtypeToId    map[string]int
+	mtypeToId    map[zmq_api.Kind]int
 	mtypeToIdMux *sync.Mutex
 }
 
@@ -30,7 +30,7 @@ func NewWebPublisher(baseUrl string, updateTypesInterval time.Duration) (*WebPub
 		mtypesUrl:       baseUrl + "/mtypes/",
 		measurementsUrl: baseUrl + "/measurements/"}
 
-	publisher.mtypeToId = make(map[string]int)
+	publisher.mtypeToId = make(map[zmq_api.Kind]int)
 	publisher.mtypeToIdMux = &sync.Mutex{}
 
 	err := publisher.updateMtypeIds()
@@ -48,7 +48,7 @@ func (publisher *WebPublisher) SupportedTypes() []string {
 
 	publisher.mtypeToIdMux.Lock()
 	for mtype, _ := range publisher.mtypeToId {
-		ret = append(ret, mtype)
+		ret = append(ret, string(mtype))
 	}
 	publisher.mtypeToIdMux.Unlock()
 
@@ -82,9 +82,9 @@ func (publisher *WebPublisher) updateMtypeIds() error {
 	}
 
 	publisher.mtypeToIdMux.Lock()
-	publisher.mtypeToId = make(map[string]int)
+	publisher.mtypeToId = make(map[zmq_api.Kind]int)
 	for _, mtype := range mtypes.Mtypes {
-		publisher.mtypeToId[mtype.Name] = mtype.Id
+		publisher.mtypeToId[zmq_api.ParseKind(mtype.Name)] = mtype.Id
 	}
 	publisher.mtypeToIdMux.Unlock()
 
@@ -40,7 +40,7 @@ func TestWebPublisherPublishMeasurementUnsupportedType(t *testing.T) {
 		t.Fatalf("NewWebPublisher() failed: %v", err)
 	}
 
-	unsupportedTypeName := "Unsupported type"
+	const unsupportedTypeName = "Unsupported type"
 
 	m := zmq_api.Measurement{DeviceId: 1,
 		Type:      unsupportedTypeName,
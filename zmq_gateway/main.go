@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -9,15 +11,18 @@ import (
 	"time"
 
 	"github.com/kholmanskikh/home_sensors/zmq_api"
+	"github.com/kholmanskikh/home_sensors/zmq_api/zmq"
 
+	"zmq_gateway/internal/clockskew"
 	"zmq_gateway/internal/config"
+	"zmq_gateway/internal/deadletter"
+	"zmq_gateway/internal/health"
 	"zmq_gateway/internal/publisher"
 	"zmq_gateway/internal/publisher/mqtt"
+	"zmq_gateway/internal/publisher/stream"
 	"zmq_gateway/internal/publisher/web"
 )
 
-var zmqPollTimeout = time.Second * 5
-
 func main() {
 	ret := 1
 	defer func() {
@@ -50,21 +55,116 @@ The config file is a JSON file of form:
 		return
 	}
 
-	subscriber, err := zmq_api.NewSubscriber(config.ZMQEndpoint)
+	subscriberOpts := []zmq_api.SubscriberOption{}
+	switch config.ZMQMaxMessageSize {
+	case 0: // the default
+	case -1:
+		subscriberOpts = append(subscriberOpts, zmq_api.WithMaxMessageSize(0))
+	default:
+		subscriberOpts = append(subscriberOpts, zmq_api.WithMaxMessageSize(config.ZMQMaxMessageSize))
+	}
+
+	if len(config.ZMQTopicFilters) != 0 {
+		subscriberOpts = append(subscriberOpts, zmq_api.WithTopicFilters(config.ZMQTopicFilters...))
+	}
+
+	if config.ZMQCurveServerKey != "" {
+		subscriberOpts = append(subscriberOpts, zmq_api.WithCurve(config.ZMQCurveServerKey,
+			config.ZMQCurvePublicKey, config.ZMQCurveSecretKey))
+	}
+
+	subscriberOpts = append(subscriberOpts, zmq_api.WithEventHandler(func(e zmq.Event) {
+		// reconnection attempts are too noisy for the non-debug mode
+		if (!config.Debug) &&
+			((e.Type == zmq.EventConnectDelayed) || (e.Type == zmq.EventConnectRetried)) {
+			return
+		}
+
+		log.Printf("ZMQ event: %v", e)
+	}))
+
+	subscriberOpts = append(subscriberOpts, zmq_api.WithGapHandler(func(g zmq_api.Gap) {
+		publisher := g.Source
+		if g.ReceiverId != "" {
+			publisher = fmt.Sprintf("%s (receiver '%s')", g.Source, g.ReceiverId)
+		}
+
+		if g.Restart {
+			log.Printf("Publisher %s restarted, lost %d messages", publisher, g.Lost)
+		} else {
+			log.Printf("Lost %d messages from %s, sequence number %d after %d",
+				g.Lost, publisher, g.Seq, g.LastSeq)
+		}
+	}))
+
+	sources := []zmq_api.Source{}
+	if config.ZMQEndpoint != "" {
+		sources = append(sources, zmq_api.Source{Endpoint: config.ZMQEndpoint})
+	}
+
+	for _, source := range config.ZMQSources {
+		sources = append(sources, zmq_api.Source{Name: source.Name, Endpoint: source.Endpoint})
+	}
+
+	subscriber, err := zmq_api.NewMultiSubscriber(sources, subscriberOpts...)
 	if err != nil {
-		log.Printf("NewSubscriber() failed: %v", err)
+		log.Printf("NewMultiSubscriber() failed: %v", err)
 		return
 	}
 	defer subscriber.Destroy()
-	log.Printf("ZMQ Endpoint: %s", subscriber.Endpoint)
 
-	var publisher publisher.Publisher
+	for _, source := range subscriber.Sources {
+		if source.Name == source.Endpoint {
+			log.Printf("ZMQ Endpoint: %s", source.Endpoint)
+		} else {
+			log.Printf("ZMQ Endpoint: %s (%s)", source.Endpoint, source.Name)
+		}
+	}
+
+	var deadLetterSink *deadletter.FileSink
+	if config.DeadLetterFile != "" {
+		deadLetterSink, err = deadletter.NewFileSink(config.DeadLetterFile)
+		if err != nil {
+			log.Printf("unable to open the dead letter file: %v", err)
+			return
+		}
+		defer deadLetterSink.Destroy()
+
+		log.Printf("Dead letter file: %s", deadLetterSink.Path)
+	}
+
+	var clockChecker *clockskew.Checker
+	if config.ClockSkewTolerance != 0 {
+		action, err := clockskew.ParseAction(config.ClockSkewAction)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		clockChecker, err = clockskew.NewChecker(time.Duration(config.ClockSkewTolerance)*time.Second, action)
+		if err != nil {
+			log.Printf("NewChecker() failed: %v", err)
+			return
+		}
+
+		log.Printf("Clock skew tolerance: %v (action: %v)", clockChecker.Tolerance, clockChecker.Action)
+	}
+
+	nodes, err := health.NewTracker(time.Duration(config.NodeOfflineAfter) * time.Second)
+	if err != nil {
+		log.Printf("NewTracker() failed: %v", err)
+		return
+	}
+
+	log.Printf("Nodes are offline after %v of silence", nodes.OfflineAfter)
+
+	var mainPublisher publisher.Publisher
 	switch config.Publisher {
 	case "web":
-		publisher, err = web.NewWebPublisher(config.WebURL,
+		mainPublisher, err = web.NewWebPublisher(config.WebURL,
 			time.Duration(config.WebUpdateTypesInterval)*time.Second)
 	case "mqtt":
-		publisher, err = mqtt.NewMQTTPublisher(config.MQTTBroker,
+		mainPublisher, err = mqtt.NewMQTTPublisher(config.MQTTBroker,
 			config.MQTTUser, config.MQTTPassword,
 			config.MQTTTopic)
 	default:
@@ -75,47 +175,148 @@ The config file is a JSON file of form:
 		log.Printf("unable to create a publisher: %v", err)
 		return
 	}
+
+	publishers := []publisher.Publisher{mainPublisher}
 	defer func() {
-		if err := publisher.Destroy(); err != nil {
-			log.Printf("Error while destroying the publisher: %v", err)
-			ret = 1
+		for _, p := range publishers {
+			if err := p.Destroy(); err != nil {
+				log.Printf("Error while destroying the publisher: %v", err)
+				ret = 1
+			}
 		}
 	}()
 
-	log.Printf("Publisher: %s", publisher.Description())
+	if config.StreamListen != "" {
+		streamPublisher, err := stream.NewStreamPublisher(config.StreamListen,
+			config.StreamClientBuffer)
+		if err != nil {
+			log.Printf("unable to create the stream publisher: %v", err)
+			return
+		}
+
+		streamPublisher.SetStatusFunc(func() interface{} {
+			return newStatus(subscriber, clockChecker, nodes)
+		})
+
+		publishers = append(publishers, streamPublisher)
+	}
+
+	for _, p := range publishers {
+		log.Printf("Publisher: %s", p.Description())
+	}
 
 	stopChan := make(chan os.Signal, 1)
 	signal.Notify(stopChan, os.Interrupt)
 
-	log.Printf("Begin operating")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	ret = 0
-	for {
-		if len(stopChan) != 0 {
-			break
+	go func() {
+		select {
+		case <-stopChan:
+			cancel()
+		case <-ctx.Done():
 		}
+	}()
 
-		measurements, err := subscriber.RecvMeasurement(zmqPollTimeout)
-		if err != nil {
-			log.Printf("RecvMeasurement() failed: %v", err)
-			continue
-		}
+	log.Printf("Begin operating")
 
-		if len(measurements) == 0 {
-			continue
-		}
+	measurementChan, errorChan := subscriber.Stream(ctx)
+
+	healthTicker := time.NewTicker(healthCheckInterval)
+	defer healthTicker.Stop()
+
+	ret = 0
+	for {
+		select {
+		case m, ok := <-measurementChan:
+			if !ok {
+				log.Printf("Exiting")
+				return
+			}
 
-		for _, m := range measurements {
 			if config.Debug {
 				log.Printf("Received %#v", *m)
 			}
 
-			err = publisher.PublishMeasurement(*m)
-			if err != nil {
-				log.Printf("PublishMeasurement() failed: %v", err)
+			if clockChecker != nil {
+				if clock := clockChecker.Check(m); clock != nil {
+					logClockChange(clock, clockChecker)
+				}
+			}
+
+			for _, p := range publishers {
+				err = p.PublishMeasurement(*m)
+				if err != nil {
+					log.Printf("PublishMeasurement() failed: %v", err)
+				}
+			}
+
+			if state := nodes.Record(*m, time.Now()); state != nil {
+				reportNodeState(*state, publishers)
+			}
+		case now := <-healthTicker.C:
+			for _, state := range nodes.Check(now) {
+				reportNodeState(state, publishers)
+			}
+		case err, ok := <-errorChan:
+			if !ok {
+				errorChan = nil
+				continue
+			}
+
+			var decodeErrors zmq_api.DecodeErrors
+			if !errors.As(err, &decodeErrors) {
+				log.Printf("RecvMeasurement() failed: %v", err)
+				continue
+			}
+
+			for _, e := range decodeErrors {
+				log.Printf("Dropping a message: %v", e)
+
+				if deadLetterSink != nil {
+					if err := deadLetterSink.Store(e, time.Now()); err != nil {
+						log.Printf("Unable to store the dead letter: %v", err)
+					}
+				}
 			}
 		}
 	}
+}
 
-	log.Printf("Exiting")
+// how often the nodes are checked for going offline
+const healthCheckInterval = 10 * time.Second
+
+func reportNodeState(state health.NodeState, publishers []publisher.Publisher) {
+	switch {
+	case !state.Online:
+		log.Printf("Node %d is offline, last seen at %v", state.DeviceId,
+			state.LastSeen.Format(time.RFC3339))
+	case state.LowPower:
+		log.Printf("Node %d reports low power", state.DeviceId)
+	default:
+		log.Printf("Node %d is online", state.DeviceId)
+	}
+
+	for _, p := range publishers {
+		if statePublisher, ok := p.(publisher.NodeStatePublisher); ok {
+			if err := statePublisher.PublishNodeState(state); err != nil {
+				log.Printf("PublishNodeState() failed: %v", err)
+			}
+		}
+	}
+}
+
+func logClockChange(clock *clockskew.ReceiverClock, checker *clockskew.Checker) {
+	receiver := clock.Source
+	if clock.ReceiverId != "" {
+		receiver = fmt.Sprintf("%s (receiver '%s')", clock.Source, clock.ReceiverId)
+	}
+
+	if clock.Skewed {
+		log.Printf("Clock of %s is off by %v, the timestamps are handled with '%v'",
+			receiver, clock.Offset, checker.Action)
+	} else {
+		log.Printf("Clock of %s is back within %v", receiver, checker.Tolerance)
+	}
 }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"time"
+
+	"github.com/kholmanskikh/home_sensors/zmq_api"
+
+	"zmq_gateway/internal/clockskew"
+	"zmq_gateway/internal/health"
+)
+
+// served by the stream publisher on /status
+type status struct {
+	// true if connected to all sources
+	ZMQConnected bool                    `json:"zmq_connected"`
+	ZMQSources   map[string]sourceStatus `json:"zmq_sources"`
+
+	// only if the clock skew check is enabled
+	Clocks []clockStatus `json:"clocks,omitempty"`
+
+	Nodes []health.NodeState `json:"nodes"`
+}
+
+type sourceStatus struct {
+	Endpoint  string     `json:"endpoint"`
+	Connected bool       `json:"connected"`
+	Since     *time.Time `json:"since,omitempty"`
+	LastEvent string     `json:"last_event,omitempty"`
+
+	// summed over the publishers behind the source which
+	// number their messages
+	LostMessages      uint64 `json:"lost_messages"`
+	PublisherRestarts uint64 `json:"publisher_restarts"`
+}
+
+type clockStatus struct {
+	Source     string `json:"source"`
+	ReceiverId string `json:"receiver_id,omitempty"`
+	// the receiver's time minus the gateway's one
+	OffsetSeconds   float64    `json:"offset_seconds"`
+	Skewed          bool       `json:"skewed"`
+	Since           *time.Time `json:"since,omitempty"`
+	FixedTimestamps uint64     `json:"fixed_timestamps"`
+}
+
+func newStatus(subscriber *zmq_api.Subscriber, clockChecker *clockskew.Checker,
+	nodes *health.Tracker) status {
+	ret := status{ZMQConnected: subscriber.ConnectionState().Connected,
+		ZMQSources: make(map[string]sourceStatus),
+		Nodes:      nodes.Nodes(time.Now())}
+
+	states := subscriber.ConnectionStates()
+
+	for _, source := range subscriber.Sources {
+		state := states[source.Name]
+
+		s := sourceStatus{Endpoint: source.Endpoint, Connected: state.Connected}
+
+		if !state.Since.IsZero() {
+			s.Since = &state.Since
+		}
+
+		if state.LastEvent != nil {
+			s.LastEvent = state.LastEvent.Type.String()
+		}
+
+		ret.ZMQSources[source.Name] = s
+	}
+
+	for _, stats := range subscriber.SequenceStats() {
+		s, found := ret.ZMQSources[stats.Source]
+		if !found {
+			continue
+		}
+
+		s.LostMessages += stats.Lost
+		s.PublisherRestarts += stats.Restarts
+		ret.ZMQSources[stats.Source] = s
+	}
+
+	if clockChecker != nil {
+		for _, clock := range clockChecker.Clocks() {
+			c := clockStatus{Source: clock.Source,
+				ReceiverId:      clock.ReceiverId,
+				OffsetSeconds:   clock.Offset.Seconds(),
+				Skewed:          clock.Skewed,
+				FixedTimestamps: clock.Fixed}
+
+			if !clock.Since.IsZero() {
+				since := clock.Since
+				c.Since = &since
+			}
+
+			ret.Clocks = append(ret.Clocks, c)
+		}
+	}
+
+	return ret
+}